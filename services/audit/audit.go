@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+	"go.uber.org/zap"
+)
+
+// PermRead is the permission required to read the audit log.
+const PermRead = "audit:read"
+
+const (
+	defaultRowsPerPage = 20
+	maxRowsPerPage     = 100
+)
+
+// ErrSessionRevoked is returned when the token of a revoked session is used.
+var ErrSessionRevoked = errs.New(errs.Unauthenticated, "session revoked")
+
+// AuditService is an API for reading the audit log.
+type AuditService interface {
+	// QueryAuditLog retrieves a page of audit log entries
+	QueryAuditLog(QueryAuditLogRequest, server.GenericRequest) QueryAuditLogResponse
+}
+
+// Recorder records entries to the audit log.
+type Recorder interface {
+	Record(ctx context.Context, e Entry) error
+}
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve audit log entries. Entries are never updated or deleted.
+type Storer interface {
+	Create(ctx context.Context, e Entry) error
+	Query(ctx context.Context, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Entry, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+}
+
+// SessionChecker reports whether the session a token was issued for has
+// been revoked. The user store implements it.
+type SessionChecker interface {
+	SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error)
+}
+
+// Required to register endpoints with the Server
+type AuditRpcService interface {
+	AuditService
+	Recorder
+	// Registers RPCService with Server
+	Register(s *server.Server)
+}
+
+// Implements interface
+type AuditServicer struct {
+	log      *zap.SugaredLogger
+	storer   Storer
+	sessions SessionChecker
+	auth     auth.Auth
+}
+
+// Record implements Recorder. The entry is given an ID, and the current
+// time when it has none.
+func (a AuditServicer) Record(ctx context.Context, e Entry) error {
+	e.ID = uuid.New()
+	if e.Date.IsZero() {
+		e.Date = time.Now()
+	}
+	e.Date = e.Date.UTC()
+
+	if err := a.storer.Create(ctx, e); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	return nil
+}
+
+// QueryAuditLog implements AuditRpcService. Entries are returned newest
+// first.
+func (a AuditServicer) QueryAuditLog(req QueryAuditLogRequest, gr server.GenericRequest) QueryAuditLogResponse {
+	if err := req.Validate(); err != nil {
+		return QueryAuditLogResponse{Fault: errs.From(err)}
+	}
+
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.RowsPerPage == 0 {
+		req.RowsPerPage = defaultRowsPerPage
+	}
+
+	entries, err := a.storer.Query(gr.Ctx, req.Filter, req.Page, req.RowsPerPage)
+	if err != nil {
+		return QueryAuditLogResponse{Fault: errs.From(fmt.Errorf("query: %w", err))}
+	}
+
+	total, err := a.storer.Count(gr.Ctx, req.Filter)
+	if err != nil {
+		return QueryAuditLogResponse{Fault: errs.From(fmt.Errorf("count: %w", err))}
+	}
+
+	return QueryAuditLogResponse{
+		Entries:     entries,
+		Total:       total,
+		Page:        req.Page,
+		RowsPerPage: req.RowsPerPage,
+	}
+}
+
+// Register implements AuditRpcService
+func (a AuditServicer) Register(s *server.Server) {
+	s.Register("AuditService", "QueryAuditLog", server.RPCEndpoint{Roles: []string{PermRead}, Handler: a.checkSession(a.QueryAuditLogHandler)})
+}
+
+// checkSession wraps the handler of an endpoint so requests made with the
+// token of a revoked session are refused, as the user endpoints do.
+// Refusals are responses carrying a fault, so they are served with the
+// status of its code.
+func (a AuditServicer) checkSession(h func(server.GenericRequest, []byte) (any, error)) func(server.GenericRequest, []byte) (any, error) {
+	return func(gr server.GenericRequest, b []byte) (any, error) {
+		sessionID, err := uuid.Parse(gr.Claims.ID)
+		if err != nil {
+			return errs.From(ErrSessionRevoked), nil
+		}
+
+		revoked, err := a.sessions.SessionRevoked(gr.Ctx, sessionID)
+		if err != nil {
+			return errs.From(fmt.Errorf("sessionrevoked: %w", err)), nil
+		}
+		if revoked {
+			return errs.From(ErrSessionRevoked), nil
+		}
+
+		return h(gr, b)
+	}
+}
+
+// Create new AuditServicer. Sessions tells which tokens were revoked.
+func NewAuditServicer(log *zap.SugaredLogger, storer Storer, sessions SessionChecker, a auth.Auth) AuditRpcService {
+	return AuditServicer{
+		log:      log,
+		storer:   storer,
+		sessions: sessions,
+		auth:     a,
+	}
+}
+
+// QueryAuditLogRequest is the request object for AuditService.QueryAuditLog.
+type QueryAuditLogRequest struct {
+	Filter      QueryFilter `json:"filter"`
+	Page        int         `json:"page"`
+	RowsPerPage int         `json:"rowsPerPage"`
+}
+
+// Validate checks the fields of the request hold usable values. Zero
+// values of Page and RowsPerPage stand for their defaults.
+func (r QueryAuditLogRequest) Validate() error {
+	var fe errs.FieldErrors
+	if r.Page < 0 {
+		fe.Add("page", "must be positive")
+	}
+	if r.RowsPerPage < 0 || r.RowsPerPage > maxRowsPerPage {
+		fe.Addf("rowsPerPage", "must be between 1 and %d", maxRowsPerPage)
+	}
+	r.Filter.check(&fe)
+
+	if len(fe) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidQuery, fe)
+	}
+	return nil
+}
+
+// QueryAuditLogResponse is the response object for
+// AuditService.QueryAuditLog.
+type QueryAuditLogResponse struct {
+	Entries     []Entry `json:"entries"`
+	Total       int     `json:"total"`
+	Page        int     `json:"page"`
+	RowsPerPage int     `json:"rowsPerPage"`
+	errs.Fault
+}
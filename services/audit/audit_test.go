@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+	"go.uber.org/zap"
+)
+
+// revokedSessions reports the sessions it holds as revoked.
+type revokedSessions map[uuid.UUID]bool
+
+func (r revokedSessions) SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	return r[sessionID], nil
+}
+
+func Test_CheckSession(t *testing.T) {
+	revoked := uuid.New()
+	a := AuditServicer{log: zap.NewNop().Sugar(), sessions: revokedSessions{revoked: true}}
+
+	var called bool
+	h := a.checkSession(func(gr server.GenericRequest, b []byte) (any, error) {
+		called = true
+		return nil, nil
+	})
+
+	claims := func(id string) auth.Claims {
+		return auth.Claims{RegisteredClaims: jwt.RegisteredClaims{ID: id}, Roles: []string{PermRead}}
+	}
+
+	t.Log("Given the need to refuse tokens of revoked sessions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen reading the audit log.", testID)
+		{
+			for _, id := range []string{revoked.String(), "not a session"} {
+				resp, err := h(server.GenericRequest{Ctx: context.Background(), Claims: claims(id)}, nil)
+				f, _ := resp.(errs.Fault)
+				if err != nil || called || f.Code != errs.Unauthenticated {
+					t.Fatalf("\t%s\tTest %d:\tShould refuse the token of session %q : got %+v, %v.", failed, testID, id, resp, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse the token of a revoked session.", success, testID)
+
+			if _, err := h(server.GenericRequest{Ctx: context.Background(), Claims: claims(uuid.NewString())}, nil); err != nil || !called {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the token of a live session : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the token of a live session.", success, testID)
+		}
+	}
+}
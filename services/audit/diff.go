@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Redacted replaces the values of redacted fields in a diff.
+const Redacted = "[REDACTED]"
+
+// Diff returns the fields that differ between before and after, as they
+// marshal to JSON. Either may be nil, for an action that creates or removes
+// its target. The values of the fields named in redact are replaced by
+// Redacted so secrets never reach the audit log, while still recording that
+// they changed.
+func Diff(before any, after any, redact ...string) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for k, v := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(v, av) {
+			changes[k] = Change{Before: v, After: av}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = Change{After: v}
+		}
+	}
+
+	for _, k := range redact {
+		c, ok := changes[k]
+		if !ok {
+			continue
+		}
+		if c.Before != nil {
+			c.Before = Redacted
+		}
+		if c.After != nil {
+			c.After = Redacted
+		}
+		changes[k] = c
+	}
+
+	return changes, nil
+}
+
+// fields returns the top level fields of v as it marshals to JSON.
+func fields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type record struct {
+	Name     string   `json:"name"`
+	Password []byte   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+func Test_Diff(t *testing.T) {
+	before := record{Name: "John", Password: []byte("old"), Roles: []string{"USER"}}
+	after := record{Name: "Jane", Password: []byte("new"), Roles: []string{"USER"}}
+
+	t.Log("Given the need to record changes without secrets.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen diffing an update.", testID)
+		{
+			changes, err := Diff(before, after, "password")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould diff : %s.", failed, testID, err)
+			}
+			if len(changes) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould only hold changed fields : got %+v.", failed, testID, changes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only hold changed fields.", success, testID)
+
+			if c := changes["name"]; c.Before != "John" || c.After != "Jane" {
+				t.Fatalf("\t%s\tTest %d:\tShould hold the old and new values : got %+v.", failed, testID, c)
+			}
+			t.Logf("\t%s\tTest %d:\tShould hold the old and new values.", success, testID)
+
+			if c := changes["password"]; c.Before != Redacted || c.After != Redacted {
+				t.Fatalf("\t%s\tTest %d:\tShould redact secrets : got %+v.", failed, testID, c)
+			}
+			t.Logf("\t%s\tTest %d:\tShould redact secrets.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen diffing a creation.", testID)
+		{
+			changes, err := Diff(nil, after, "password")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould diff : %s.", failed, testID, err)
+			}
+			if len(changes) != 3 || changes["roles"].Before != nil || changes["password"].After != Redacted {
+				t.Fatalf("\t%s\tTest %d:\tShould hold every field : got %+v.", failed, testID, changes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould hold every field.", success, testID)
+		}
+	}
+}
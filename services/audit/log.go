@@ -0,0 +1,24 @@
+package audit
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// logRecorder writes audit entries to the log. It stands in when no audit
+// log is configured.
+type logRecorder struct {
+	log *zap.SugaredLogger
+}
+
+// NewLogRecorder returns a Recorder that only logs entries.
+func NewLogRecorder(log *zap.SugaredLogger) Recorder {
+	return logRecorder{log: log}
+}
+
+// Record implements Recorder.
+func (r logRecorder) Record(ctx context.Context, e Entry) error {
+	r.log.Infow("audit", "actor", e.Actor, "action", e.Action, "target", e.Target, "request_id", e.RequestID, "changes", e.Changes)
+	return nil
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+)
+
+// maxFilterLength is the longest value a query can filter on.
+const maxFilterLength = 128
+
+// ErrInvalidQuery is returned when an audit log query is malformed.
+var ErrInvalidQuery = errs.New(errs.InvalidArgument, "invalid query")
+
+// Entry records a single action taken by an actor on a target.
+type Entry struct {
+	ID        uuid.UUID         `json:"id"`
+	Actor     string            `json:"actor"`
+	Action    string            `json:"action"`
+	Target    string            `json:"target"`
+	Changes   map[string]Change `json:"changes"`
+	RequestID string            `json:"request_id"`
+	Date      time.Time         `json:"date"`
+}
+
+// Change holds the value of a field before and after an action. A field
+// that did not exist on one side is nil there.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// QueryFilter holds the available fields the audit log can be filtered on.
+// Nil fields are not filtered on.
+type QueryFilter struct {
+	Actor     *string    `json:"actor"`
+	Target    *string    `json:"target"`
+	Action    *string    `json:"action"`
+	StartDate *time.Time `json:"startDate"`
+	EndDate   *time.Time `json:"endDate"`
+}
+
+// check adds the errors of the fields of the filter that do not hold
+// usable values.
+func (qf QueryFilter) check(fe *errs.FieldErrors) {
+	values := []struct {
+		field string
+		value *string
+	}{
+		{"filter.actor", qf.Actor},
+		{"filter.target", qf.Target},
+		{"filter.action", qf.Action},
+	}
+	for _, v := range values {
+		if v.value != nil && len(*v.value) > maxFilterLength {
+			fe.Addf(v.field, "must be at most %d bytes", maxFilterLength)
+		}
+	}
+
+	if qf.StartDate != nil && qf.EndDate != nil && qf.EndDate.Before(*qf.StartDate) {
+		fe.Add("filter.endDate", "must not be before startDate")
+	}
+}
@@ -0,0 +1,76 @@
+// Package memory implements audit.Storer in process, for tests and for user
+// stores other than ArangoDB. Entries are lost when the process exits. It
+// follows the ordering of the ArangoDB store.
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/kjvonly/service/services/audit"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	log     *zap.SugaredLogger
+	mu      sync.RWMutex
+	entries []audit.Entry
+}
+
+// NewStore constructs an empty audit log.
+func NewStore(log *zap.SugaredLogger) *Store {
+	return &Store{log: log}
+}
+
+// Create appends a new entry to the audit log.
+func (s *Store) Create(ctx context.Context, e audit.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Query retrieves a page of entries matching filter, newest first.
+func (s *Store) Query(ctx context.Context, filter audit.QueryFilter, pageNumber int, rowsPerPage int) ([]audit.Entry, error) {
+	matched := s.match(filter)
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].Date.Equal(matched[j].Date) {
+			return matched[i].Date.After(matched[j].Date)
+		}
+		return matched[i].ID.String() < matched[j].ID.String()
+	})
+
+	entries := []audit.Entry{}
+	offset := (pageNumber - 1) * rowsPerPage
+	for i := offset; i >= 0 && i < len(matched) && i < offset+rowsPerPage; i++ {
+		entries = append(entries, matched[i])
+	}
+	return entries, nil
+}
+
+// Count returns the total number of entries matching filter.
+func (s *Store) Count(ctx context.Context, filter audit.QueryFilter) (int, error) {
+	return len(s.match(filter)), nil
+}
+
+// match returns the entries set fields of filter hold for.
+func (s *Store) match(filter audit.QueryFilter) []audit.Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []audit.Entry
+	for _, e := range s.entries {
+		switch {
+		case filter.Actor != nil && e.Actor != *filter.Actor,
+			filter.Target != nil && e.Target != *filter.Target,
+			filter.Action != nil && e.Action != *filter.Action,
+			filter.StartDate != nil && e.Date.Before(*filter.StartDate),
+			filter.EndDate != nil && e.Date.After(*filter.EndDate):
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,143 @@
+// Package nosql implements audit.Storer over an append-only ArangoDB
+// collection.
+package nosql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/arangodb/go-driver"
+	"github.com/kjvonly/service/services/audit"
+	"go.uber.org/zap"
+)
+
+const collectionName = "audit_log"
+
+type Store struct {
+	db  driver.Database
+	col driver.Collection
+	log *zap.SugaredLogger
+}
+
+// NewStore ensures the indexes the audit log is filtered by.
+func NewStore(log *zap.SugaredLogger, db driver.Database) *Store {
+	ctx := context.Background()
+
+	col, err := db.Collection(ctx, collectionName)
+	if err != nil {
+		log.Panicf("error accessing collection: %s", err)
+	}
+
+	for _, field := range []string{"actor", "target", "action", "date"} {
+		if _, _, err := col.EnsurePersistentIndex(ctx, []string{field}, nil); err != nil {
+			log.Panicf("error creating %s index: %s", field, err)
+		}
+	}
+
+	return &Store{
+		log: log,
+		db:  db,
+		col: col,
+	}
+}
+
+// Create inserts a new entry into the audit log.
+func (s *Store) Create(ctx context.Context, e audit.Entry) error {
+	_, err := s.col.CreateDocument(ctx, toDBEntry(e))
+	return err
+}
+
+// Query retrieves a page of entries matching filter, newest first.
+func (s *Store) Query(ctx context.Context, filter audit.QueryFilter, pageNumber int, rowsPerPage int) ([]audit.Entry, error) {
+	bindvars := map[string]interface{}{
+		"@coll":         collectionName,
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("FOR e IN @@coll")
+	applyFilter(filter, bindvars, &buf)
+	buf.WriteString("\n\tSORT e.date DESC, e._key ASC")
+	buf.WriteString("\n\tLIMIT @offset, @rows_per_page")
+	buf.WriteString("\n\tRETURN e")
+
+	c, err := s.db.Query(ctx, buf.String(), bindvars)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	entries := []audit.Entry{}
+	for c.HasMore() {
+		var result dbEntry
+		if _, err := c.ReadDocument(ctx, &result); err != nil {
+			return nil, err
+		}
+		entries = append(entries, toCoreEntry(result))
+	}
+
+	return entries, nil
+}
+
+// Count returns the total number of entries matching filter.
+func (s *Store) Count(ctx context.Context, filter audit.QueryFilter) (int, error) {
+	bindvars := map[string]interface{}{
+		"@coll": collectionName,
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("FOR e IN @@coll")
+	applyFilter(filter, bindvars, &buf)
+	buf.WriteString("\n\tCOLLECT WITH COUNT INTO count")
+	buf.WriteString("\n\tRETURN count")
+
+	c, err := s.db.Query(ctx, buf.String(), bindvars)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	var count int
+	if _, err := c.ReadDocument(ctx, &count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// applyFilter adds a FILTER statement to the AQL query for every field set
+// in filter, with the values passed as bind variables.
+func applyFilter(filter audit.QueryFilter, bindvars map[string]interface{}, buf *strings.Builder) {
+	var wc []string
+
+	if filter.Actor != nil {
+		bindvars["actor"] = *filter.Actor
+		wc = append(wc, "e.actor == @actor")
+	}
+
+	if filter.Target != nil {
+		bindvars["target"] = *filter.Target
+		wc = append(wc, "e.target == @target")
+	}
+
+	if filter.Action != nil {
+		bindvars["action"] = *filter.Action
+		wc = append(wc, "e.action == @action")
+	}
+
+	if filter.StartDate != nil {
+		bindvars["start_date"] = filter.StartDate.UTC().UnixMilli()
+		wc = append(wc, "DATE_TIMESTAMP(e.date) >= @start_date")
+	}
+
+	if filter.EndDate != nil {
+		bindvars["end_date"] = filter.EndDate.UTC().UnixMilli()
+		wc = append(wc, "DATE_TIMESTAMP(e.date) <= @end_date")
+	}
+
+	for _, w := range wc {
+		buf.WriteString("\n\tFILTER ")
+		buf.WriteString(w)
+	}
+}
@@ -0,0 +1,44 @@
+package nosql
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/audit"
+)
+
+// dbEntry represent the structure we need for moving audit log entries
+// between the app and the database.
+type dbEntry struct {
+	ID        uuid.UUID               `json:"_key"`
+	Actor     string                  `json:"actor"`
+	Action    string                  `json:"action"`
+	Target    string                  `json:"target"`
+	Changes   map[string]audit.Change `json:"changes"`
+	RequestID string                  `json:"request_id"`
+	Date      time.Time               `json:"date"`
+}
+
+func toDBEntry(e audit.Entry) dbEntry {
+	return dbEntry{
+		ID:        e.ID,
+		Actor:     e.Actor,
+		Action:    e.Action,
+		Target:    e.Target,
+		Changes:   e.Changes,
+		RequestID: e.RequestID,
+		Date:      e.Date.UTC(),
+	}
+}
+
+func toCoreEntry(dbE dbEntry) audit.Entry {
+	return audit.Entry{
+		ID:        dbE.ID,
+		Actor:     dbE.Actor,
+		Action:    dbE.Action,
+		Target:    dbE.Target,
+		Changes:   dbE.Changes,
+		RequestID: dbE.RequestID,
+		Date:      dbE.Date.In(time.Local),
+	}
+}
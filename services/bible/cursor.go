@@ -0,0 +1,71 @@
+package bible
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kjvonly/service/services/errs"
+)
+
+// ErrInvalidCursor is returned when a continuation token cannot be decoded
+// or was issued for a different search.
+var ErrInvalidCursor = errs.New(errs.InvalidArgument, "invalid cursor")
+
+// cursor wraps the paging state of a store with a fingerprint of the search
+// it continues.
+type cursor struct {
+	Search string          `json:"s"`
+	State  json.RawMessage `json:"p"`
+}
+
+// EncodeCursor returns an opaque continuation token for s carrying the
+// store specific paging state.
+func EncodeCursor(s Search, state any) (string, error) {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+
+	b, err = json.Marshal(cursor{Search: fingerprint(s), State: b})
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor decodes the cursor of s into state.
+func DecodeCursor(s Search, state any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s.Cursor)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	if c.Search != fingerprint(s) {
+		return fmt.Errorf("%w: issued for a different search", ErrInvalidCursor)
+	}
+
+	if err := json.Unmarshal(c.State, state); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	return nil
+}
+
+// fingerprint identifies the hits a search matches, ignoring how they are
+// paged or highlighted.
+func fingerprint(s Search) string {
+	s.Cursor = ""
+	s.Offset = 0
+	s.Limit = 0
+	s.Highlight = nil
+
+	b, _ := json.Marshal(s)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -20,4 +20,17 @@ func (h BibleSearchServicer) SearchHandler(r server.GenericRequest, b []byte) (a
 	}
 
 	return h.Search(hr, r), nil
+} 
+// GetPassageHandler validates input data prior to calling GetPassage
+func (h PassageServicer) GetPassageHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr GetPassageRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.GetPassage(hr, r), nil
 }
@@ -0,0 +1,91 @@
+// Package kjv reads the text of the King James Version from a tab separated
+// source file with one verse per line:
+//
+//	Genesis	1	1	In the beginning God created the heaven and the earth.
+//
+// Blank lines and lines starting with # are ignored. Book names may be any
+// name or abbreviation known to the reference package and are returned in
+// their canonical form.
+package kjv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/bible/reference"
+)
+
+// Load reads every verse from the source file at path.
+func Load(path string) ([]bible.Verse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	verses, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return verses, nil
+}
+
+// Read reads every verse from r.
+func Read(r io.Reader) ([]bible.Verse, error) {
+	var verses []bible.Verse
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		v, err := parseLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		verses = append(verses, v)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return verses, nil
+}
+
+// parseLine parses a single book, chapter, verse and text line.
+func parseLine(line string) (bible.Verse, error) {
+	fields := strings.SplitN(line, "\t", 4)
+	if len(fields) != 4 {
+		return bible.Verse{}, fmt.Errorf("expected 4 tab separated fields, got %d", len(fields))
+	}
+
+	book, err := reference.LookupBook(fields[0])
+	if err != nil {
+		return bible.Verse{}, err
+	}
+
+	chapter, err := strconv.Atoi(fields[1])
+	if err != nil || chapter < 1 || chapter > book.Chapters {
+		return bible.Verse{}, fmt.Errorf("%w: %s %s", reference.ErrInvalidChapter, book.Name, fields[1])
+	}
+
+	verse, err := strconv.Atoi(fields[2])
+	if err != nil || verse < 1 {
+		return bible.Verse{}, fmt.Errorf("%w: %s %d:%s", reference.ErrInvalidVerse, book.Name, chapter, fields[2])
+	}
+
+	return bible.Verse{
+		Book:    book.Name,
+		Chapter: chapter,
+		Verse:   verse,
+		Text:    strings.TrimSpace(fields[3]),
+	}, nil
+}
@@ -1,10 +1,73 @@
 package bible
 
-import "github.com/kjvonly/service/services/bible/stores/elasticsearch"
+// Set of match modes controlling how search terms are combined.
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
 
+// Set of testaments a search can be restricted to.
+const (
+	TestamentOld = "old"
+	TestamentNew = "new"
+)
+
+// Range is an inclusive range of numbers. A zero bound leaves that side of
+// the range open.
+type Range struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Search describes a structured query against the text of the bible.
 type Search struct {
-	Query string `json:"query"`
+	Terms     []string   `json:"terms"`
+	Phrase    string     `json:"phrase"`
+	Books     []string   `json:"books"`
+	Chapters  Range      `json:"chapters"`
+	Testament string     `json:"testament"`
+	Mode      string     `json:"mode"`
+	Limit     int        `json:"limit"`
+	Offset    int        `json:"offset"`
+	Cursor    string     `json:"cursor"`
+	Highlight *Highlight `json:"highlight"`
+}
+
+// Highlight asks for the matched words of each hit to be wrapped in tags.
+// Without WholeVerse, hits carry fragments of about FragmentSize characters
+// around the matches instead of the whole verse.
+type Highlight struct {
+	PreTag       string `json:"pre_tag"`
+	PostTag      string `json:"post_tag"`
+	FragmentSize int    `json:"fragment_size"`
+	WholeVerse   bool   `json:"whole_verse"`
+}
+
+// Verse represents a single verse of the bible.
+type Verse struct {
+	Book    string `json:"book"`
+	Chapter int    `json:"chapter"`
+	Verse   int    `json:"verse"`
+	Text    string `json:"text"`
+}
+
+// Hit is a verse matching a search along with its relevance score.
+type Hit struct {
+	Verse
+	Score      float64  `json:"score"`
+	Highlights []string `json:"highlights,omitempty"`
 }
+
+// Passage is the run of verses for one range of a reference.
+type Passage struct {
+	Reference string  `json:"reference"`
+	Verses    []Verse `json:"verses"`
+}
+
+// SearchResults contains a page of the verses matching a search ordered by
+// score. NextCursor is empty on the last page.
 type SearchResults struct {
-	SqlResults elasticsearch.SqlResult
+	Hits       []Hit  `json:"hits"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,129 @@
+package bible
+
+import (
+	"fmt"
+	"strings"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/kjvonly/service/services/bible/reference"
+	"github.com/kjvonly/service/services/errs"
+	"go.uber.org/zap"
+)
+
+// maxReferenceLength is the longest reference GetPassage parses.
+const maxReferenceLength = 256
+
+// ErrVerseNotFound is returned when a reference names a verse beyond the end
+// of its chapter.
+var ErrVerseNotFound = errs.New(errs.NotFound, "verse not found")
+
+// PassageService is an API for reading passages of the bible by reference.
+type PassageService interface {
+	// GetPassage returns the verses of a reference such as "John 3:16-18".
+	GetPassage(req GetPassageRequest, gr server.GenericRequest) GetPassageResponse
+}
+
+// Required to register endpoints with the Server
+type PassageRpcService interface {
+	PassageService
+	// Registers RPCService with Server
+	Register(s *server.Server)
+}
+
+// Implements interface
+type PassageServicer struct {
+	log    *zap.SugaredLogger
+	storer Storer
+	auth   auth.Auth
+}
+
+// GetPassage implements PassageRpcService
+func (p PassageServicer) GetPassage(req GetPassageRequest, gr server.GenericRequest) GetPassageResponse {
+	if err := req.Validate(); err != nil {
+		return GetPassageResponse{Fault: errs.From(err)}
+	}
+
+	ref, err := reference.Parse(req.Reference)
+	if err != nil {
+		return GetPassageResponse{Fault: errs.From(err)}
+	}
+
+	passages := make([]Passage, len(ref))
+	for i, r := range ref {
+		verses, err := p.storer.QueryRange(gr.Ctx, r)
+		if err != nil {
+			return GetPassageResponse{Fault: errs.From(err)}
+		}
+
+		if err := checkRange(r, verses); err != nil {
+			return GetPassageResponse{Fault: errs.From(err)}
+		}
+
+		passages[i] = Passage{
+			Reference: r.String(),
+			Verses:    verses,
+		}
+	}
+
+	return GetPassageResponse{Passages: passages}
+}
+
+// checkRange verifies the verses returned for a range begin and end where
+// the range says they should. The parser only knows how many chapters a book
+// has, so verse numbers past the end of a chapter are caught here.
+func checkRange(r reference.Range, verses []Verse) error {
+	if len(verses) == 0 {
+		return fmt.Errorf("%w: %s", ErrVerseNotFound, r)
+	}
+
+	first := verses[0]
+	if first.Chapter != r.Start.Chapter || first.Verse != r.Start.Verse {
+		return fmt.Errorf("%w: %s %d:%d", ErrVerseNotFound, r.Book.Name, r.Start.Chapter, r.Start.Verse)
+	}
+
+	last := verses[len(verses)-1]
+	if r.End.Verse != 0 && (last.Chapter != r.End.Chapter || last.Verse != r.End.Verse) {
+		return fmt.Errorf("%w: %s %d:%d", ErrVerseNotFound, r.Book.Name, r.End.Chapter, r.End.Verse)
+	}
+
+	return nil
+}
+
+// Register implements PassageRpcService
+func (p PassageServicer) Register(s *server.Server) {
+	s.Register("PassageService", "GetPassage", server.RPCEndpoint{Roles: []string{}, Handler: p.GetPassageHandler})
+}
+
+// Create new PassageServicer
+func NewPassageServicer(log *zap.SugaredLogger, storer Storer, a auth.Auth) PassageRpcService {
+	return PassageServicer{
+		log:    log,
+		storer: storer,
+		auth:   a,
+	}
+}
+
+// GetPassageRequest is the request object for PassageService.GetPassage.
+type GetPassageRequest struct {
+	Reference string `json:"reference"`
+}
+
+// Validate checks the fields of the request hold usable values. Whether
+// the reference names verses is checked when it is parsed.
+func (r GetPassageRequest) Validate() error {
+	var fe errs.FieldErrors
+	switch ref := strings.TrimSpace(r.Reference); {
+	case ref == "":
+		fe.Add("reference", "is required")
+	case len(ref) > maxReferenceLength:
+		fe.Addf("reference", "must be at most %d characters", maxReferenceLength)
+	}
+	return fe.Err()
+}
+
+// GetPassageResponse is the response object for PassageService.GetPassage.
+type GetPassageResponse struct {
+	Passages []Passage `json:"passages"`
+	errs.Fault
+}
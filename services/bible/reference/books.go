@@ -0,0 +1,166 @@
+package reference
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Set of testaments a book can belong to.
+const (
+	TestamentOld = "old"
+	TestamentNew = "new"
+)
+
+// Book describes a book of the bible and how many chapters it contains.
+type Book struct {
+	Name      string
+	Order     int
+	Testament string
+	Chapters  int
+	abbrevs   []string
+}
+
+// Books lists every book of the bible in canonical order.
+var Books = []Book{
+	{Name: "Genesis", Chapters: 50, abbrevs: []string{"gen", "ge", "gn"}},
+	{Name: "Exodus", Chapters: 40, abbrevs: []string{"ex", "exo", "exod"}},
+	{Name: "Leviticus", Chapters: 27, abbrevs: []string{"lev", "le", "lv"}},
+	{Name: "Numbers", Chapters: 36, abbrevs: []string{"num", "nu", "nm", "nb"}},
+	{Name: "Deuteronomy", Chapters: 34, abbrevs: []string{"deut", "de", "dt"}},
+	{Name: "Joshua", Chapters: 24, abbrevs: []string{"josh", "jos", "jsh"}},
+	{Name: "Judges", Chapters: 21, abbrevs: []string{"judg", "jdg", "jg", "jdgs"}},
+	{Name: "Ruth", Chapters: 4, abbrevs: []string{"rth", "ru"}},
+	{Name: "1 Samuel", Chapters: 31, abbrevs: []string{"1sam", "1sa", "1sm", "1s"}},
+	{Name: "2 Samuel", Chapters: 24, abbrevs: []string{"2sam", "2sa", "2sm", "2s"}},
+	{Name: "1 Kings", Chapters: 22, abbrevs: []string{"1kgs", "1ki", "1kin", "1k"}},
+	{Name: "2 Kings", Chapters: 25, abbrevs: []string{"2kgs", "2ki", "2kin", "2k"}},
+	{Name: "1 Chronicles", Chapters: 29, abbrevs: []string{"1chron", "1chr", "1ch"}},
+	{Name: "2 Chronicles", Chapters: 36, abbrevs: []string{"2chron", "2chr", "2ch"}},
+	{Name: "Ezra", Chapters: 10, abbrevs: []string{"ezr", "ez"}},
+	{Name: "Nehemiah", Chapters: 13, abbrevs: []string{"neh", "ne"}},
+	{Name: "Esther", Chapters: 10, abbrevs: []string{"esth", "est", "es"}},
+	{Name: "Job", Chapters: 42, abbrevs: []string{"jb"}},
+	{Name: "Psalms", Chapters: 150, abbrevs: []string{"ps", "psa", "psalm", "pss", "psm"}},
+	{Name: "Proverbs", Chapters: 31, abbrevs: []string{"prov", "pro", "prv", "pr"}},
+	{Name: "Ecclesiastes", Chapters: 12, abbrevs: []string{"eccles", "eccl", "ecc", "ec", "qoh"}},
+	{Name: "Song of Solomon", Chapters: 8, abbrevs: []string{"song", "sos", "so", "songofsongs", "canticles"}},
+	{Name: "Isaiah", Chapters: 66, abbrevs: []string{"isa", "is"}},
+	{Name: "Jeremiah", Chapters: 52, abbrevs: []string{"jer", "je", "jr"}},
+	{Name: "Lamentations", Chapters: 5, abbrevs: []string{"lam", "la"}},
+	{Name: "Ezekiel", Chapters: 48, abbrevs: []string{"ezek", "eze", "ezk"}},
+	{Name: "Daniel", Chapters: 12, abbrevs: []string{"dan", "da", "dn"}},
+	{Name: "Hosea", Chapters: 14, abbrevs: []string{"hos", "ho"}},
+	{Name: "Joel", Chapters: 3, abbrevs: []string{"jl"}},
+	{Name: "Amos", Chapters: 9, abbrevs: []string{"am"}},
+	{Name: "Obadiah", Chapters: 1, abbrevs: []string{"obad", "ob"}},
+	{Name: "Jonah", Chapters: 4, abbrevs: []string{"jnh", "jon"}},
+	{Name: "Micah", Chapters: 7, abbrevs: []string{"mic", "mc"}},
+	{Name: "Nahum", Chapters: 3, abbrevs: []string{"nah", "na"}},
+	{Name: "Habakkuk", Chapters: 3, abbrevs: []string{"hab", "hb"}},
+	{Name: "Zephaniah", Chapters: 3, abbrevs: []string{"zeph", "zep", "zp"}},
+	{Name: "Haggai", Chapters: 2, abbrevs: []string{"hag", "hg"}},
+	{Name: "Zechariah", Chapters: 14, abbrevs: []string{"zech", "zec", "zc"}},
+	{Name: "Malachi", Chapters: 4, abbrevs: []string{"mal", "ml"}},
+	{Name: "Matthew", Chapters: 28, abbrevs: []string{"matt", "mat", "mt"}},
+	{Name: "Mark", Chapters: 16, abbrevs: []string{"mrk", "mar", "mk", "mr"}},
+	{Name: "Luke", Chapters: 24, abbrevs: []string{"luk", "lk"}},
+	{Name: "John", Chapters: 21, abbrevs: []string{"joh", "jhn", "jn"}},
+	{Name: "Acts", Chapters: 28, abbrevs: []string{"act", "ac"}},
+	{Name: "Romans", Chapters: 16, abbrevs: []string{"rom", "ro", "rm"}},
+	{Name: "1 Corinthians", Chapters: 16, abbrevs: []string{"1cor", "1co"}},
+	{Name: "2 Corinthians", Chapters: 13, abbrevs: []string{"2cor", "2co"}},
+	{Name: "Galatians", Chapters: 6, abbrevs: []string{"gal", "ga"}},
+	{Name: "Ephesians", Chapters: 6, abbrevs: []string{"eph", "ephes"}},
+	{Name: "Philippians", Chapters: 4, abbrevs: []string{"phil", "php", "pp"}},
+	{Name: "Colossians", Chapters: 4, abbrevs: []string{"col", "co"}},
+	{Name: "1 Thessalonians", Chapters: 5, abbrevs: []string{"1thess", "1thes", "1th"}},
+	{Name: "2 Thessalonians", Chapters: 3, abbrevs: []string{"2thess", "2thes", "2th"}},
+	{Name: "1 Timothy", Chapters: 6, abbrevs: []string{"1tim", "1ti"}},
+	{Name: "2 Timothy", Chapters: 4, abbrevs: []string{"2tim", "2ti"}},
+	{Name: "Titus", Chapters: 3, abbrevs: []string{"tit", "ti"}},
+	{Name: "Philemon", Chapters: 1, abbrevs: []string{"philem", "phm", "pm"}},
+	{Name: "Hebrews", Chapters: 13, abbrevs: []string{"heb"}},
+	{Name: "James", Chapters: 5, abbrevs: []string{"jas", "jm"}},
+	{Name: "1 Peter", Chapters: 5, abbrevs: []string{"1pet", "1pe", "1pt", "1p"}},
+	{Name: "2 Peter", Chapters: 3, abbrevs: []string{"2pet", "2pe", "2pt", "2p"}},
+	{Name: "1 John", Chapters: 5, abbrevs: []string{"1john", "1jhn", "1jn", "1j"}},
+	{Name: "2 John", Chapters: 1, abbrevs: []string{"2john", "2jhn", "2jn", "2j"}},
+	{Name: "3 John", Chapters: 1, abbrevs: []string{"3john", "3jhn", "3jn", "3j"}},
+	{Name: "Jude", Chapters: 1, abbrevs: []string{"jud", "jde"}},
+	{Name: "Revelation", Chapters: 22, abbrevs: []string{"rev", "re", "rv", "revelations"}},
+}
+
+// firstNewTestamentBook is the index of Matthew in Books.
+const firstNewTestamentBook = 39
+
+// Set of known book names and abbreviations keyed by their normalized form.
+var books = map[string]int{}
+
+func init() {
+	for i := range Books {
+		Books[i].Order = i + 1
+		Books[i].Testament = TestamentOld
+		if i >= firstNewTestamentBook {
+			Books[i].Testament = TestamentNew
+		}
+
+		books[normalize(Books[i].Name)] = i
+		for _, abbrev := range Books[i].abbrevs {
+			books[abbrev] = i
+		}
+	}
+}
+
+// LookupBook finds a book by its name or a common abbreviation. Ordinal
+// prefixes may be written as digits, roman numerals or words, so "1 Cor",
+// "I Corinthians" and "First Corinthians" all resolve to 1 Corinthians.
+func LookupBook(name string) (Book, error) {
+	key := normalize(name)
+	if key == "" {
+		return Book{}, fmt.Errorf("%w: %q", ErrInvalidBook, name)
+	}
+
+	if i, exists := books[key]; exists {
+		return Books[i], nil
+	}
+
+	// Fall back to an unambiguous prefix of a full book name so spellings
+	// like "Genes" or "Philipp" still resolve.
+	const minPrefix = 3
+	match := -1
+	if len(key) >= minPrefix {
+		for i, b := range Books {
+			if !strings.HasPrefix(normalize(b.Name), key) {
+				continue
+			}
+			if match != -1 {
+				return Book{}, fmt.Errorf("%w: %q is ambiguous", ErrInvalidBook, name)
+			}
+			match = i
+		}
+	}
+	if match == -1 {
+		return Book{}, fmt.Errorf("%w: %q", ErrInvalidBook, name)
+	}
+
+	return Books[match], nil
+}
+
+// Set of ordinal prefixes mapped to their digit.
+var ordinals = map[string]string{
+	"1": "1", "i": "1", "1st": "1", "first": "1",
+	"2": "2", "ii": "2", "2nd": "2", "second": "2",
+	"3": "3", "iii": "3", "3rd": "3", "third": "3",
+}
+
+// normalize lower cases a book name, converts any ordinal prefix to a digit
+// and strips spaces and periods.
+func normalize(name string) string {
+	fields := strings.Fields(strings.ToLower(strings.ReplaceAll(name, ".", " ")))
+	if len(fields) > 1 {
+		if digit, exists := ordinals[fields[0]]; exists {
+			fields[0] = digit
+		}
+	}
+	return strings.Join(fields, "")
+}
@@ -0,0 +1,249 @@
+// Package reference parses scripture references such as "John 3:16-18",
+// "Gen 1" or "1 Cor 13:4-7; Rom 8:28" into ranges of verses.
+package reference
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kjvonly/service/services/errs"
+)
+
+// maxVerse is the verse count of the longest chapter, Psalm 119. Verse
+// numbers beyond it are rejected without asking a store.
+const maxVerse = 176
+
+// Set of errors returned when a reference cannot be parsed.
+var (
+	ErrSyntax         = errs.New(errs.InvalidArgument, "invalid reference")
+	ErrInvalidBook    = errs.New(errs.InvalidArgument, "invalid book")
+	ErrInvalidChapter = errs.New(errs.InvalidArgument, "invalid chapter")
+	ErrInvalidVerse   = errs.New(errs.InvalidArgument, "invalid verse")
+)
+
+// Location identifies a verse within a book. A zero Verse in the end of a
+// Range means the end of the chapter.
+type Location struct {
+	Chapter int `json:"chapter"`
+	Verse   int `json:"verse"`
+}
+
+// Range is a contiguous run of verses within a single book.
+type Range struct {
+	Book  Book     `json:"-"`
+	Start Location `json:"start"`
+	End   Location `json:"end"`
+}
+
+// String returns the range in canonical form, e.g. "John 3:16-18".
+func (r Range) String() string {
+	switch {
+	case r.Start.Verse == 1 && r.End.Verse == 0 && r.Start.Chapter == r.End.Chapter:
+		return fmt.Sprintf("%s %d", r.Book.Name, r.Start.Chapter)
+	case r.Start.Verse == 1 && r.End.Verse == 0:
+		return fmt.Sprintf("%s %d-%d", r.Book.Name, r.Start.Chapter, r.End.Chapter)
+	case r.Start == r.End:
+		return fmt.Sprintf("%s %d:%d", r.Book.Name, r.Start.Chapter, r.Start.Verse)
+	case r.Start.Chapter == r.End.Chapter:
+		return fmt.Sprintf("%s %d:%d-%d", r.Book.Name, r.Start.Chapter, r.Start.Verse, r.End.Verse)
+	}
+	return fmt.Sprintf("%s %d:%d-%d:%d", r.Book.Name, r.Start.Chapter, r.Start.Verse, r.End.Chapter, r.End.Verse)
+}
+
+// Reference is an ordered list of verse ranges.
+type Reference []Range
+
+// String returns the reference in canonical form.
+func (ref Reference) String() string {
+	parts := make([]string, len(ref))
+	for i, r := range ref {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Parse parses a scripture reference. Semicolons separate references that
+// may name a new book, commas continue in the same book and chapter, and
+// ranges may cross chapters as in "John 3:16-4:2".
+func Parse(s string) (Reference, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("%w: empty reference", ErrSyntax)
+	}
+
+	var ref Reference
+	var book *Book
+	for _, segment := range strings.Split(s, ";") {
+		name, loc := splitSegment(segment)
+		if name != "" {
+			b, err := LookupBook(name)
+			if err != nil {
+				return nil, err
+			}
+			book = &b
+		}
+		if book == nil {
+			return nil, fmt.Errorf("%w: %q does not name a book", ErrSyntax, strings.TrimSpace(segment))
+		}
+
+		ranges, err := parseLocations(*book, loc)
+		if err != nil {
+			return nil, err
+		}
+		ref = append(ref, ranges...)
+	}
+
+	return ref, nil
+}
+
+// splitSegment splits a segment such as "1 Cor 13:4-7" into the book name
+// and the chapter and verse locations following it. The dot ending an
+// abbreviated name, as in "Rom. 8:28", is dropped.
+func splitSegment(segment string) (string, string) {
+	last := strings.LastIndexFunc(segment, unicode.IsLetter)
+	if last == -1 {
+		return "", strings.TrimSpace(segment)
+	}
+	loc := strings.TrimPrefix(strings.TrimSpace(segment[last+1:]), ".")
+	return strings.TrimSpace(segment[:last+1]), strings.TrimSpace(loc)
+}
+
+// parseLocations parses a comma separated list of locations in book.
+func parseLocations(book Book, loc string) ([]Range, error) {
+	loc = chapterDots(strings.NewReplacer(" ", "", "–", "-", "—", "-").Replace(loc))
+	if loc == "" {
+		return []Range{{
+			Book:  book,
+			Start: Location{Chapter: 1, Verse: 1},
+			End:   Location{Chapter: book.Chapters},
+		}}, nil
+	}
+
+	var ranges []Range
+	var chapter int
+	verses := book.Chapters == 1
+	for _, item := range strings.Split(loc, ",") {
+		sides := strings.Split(item, "-")
+		if len(sides) > 2 {
+			return nil, fmt.Errorf("%w: %q", ErrSyntax, item)
+		}
+
+		start, startHasVerse, err := parseLocation(sides[0], chapter, verses)
+		if err != nil {
+			return nil, err
+		}
+
+		r := Range{Book: book, Start: start, End: start}
+		if !startHasVerse {
+			r.Start.Verse = 1
+			r.End.Verse = 0
+		}
+
+		if len(sides) == 2 {
+			end, endHasVerse, err := parseLocation(sides[1], start.Chapter, startHasVerse)
+			if err != nil {
+				return nil, err
+			}
+			r.End = end
+			startHasVerse = startHasVerse || endHasVerse
+		}
+
+		if err := validate(r); err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, r)
+		chapter = r.End.Chapter
+		verses = startHasVerse
+	}
+
+	return ranges, nil
+}
+
+// chapterDots turns the dots separating a chapter from a verse, as in
+// "3.16", into colons. Other dots are left to be refused.
+func chapterDots(loc string) string {
+	b := []byte(loc)
+	for i := 1; i < len(b)-1; i++ {
+		if b[i] == '.' && isDigit(b[i-1]) && isDigit(b[i+1]) {
+			b[i] = ':'
+		}
+	}
+	return string(b)
+}
+
+// isDigit reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseLocation parses "C:V" or a lone number. A lone number is a verse in
+// chapter when verses is set and a chapter otherwise.
+func parseLocation(s string, chapter int, verses bool) (Location, bool, error) {
+	c, v, found := strings.Cut(s, ":")
+	if found {
+		ch, err := parseNumber(c, ErrInvalidChapter)
+		if err != nil {
+			return Location{}, false, err
+		}
+		vs, err := parseNumber(v, ErrInvalidVerse)
+		if err != nil {
+			return Location{}, false, err
+		}
+		return Location{Chapter: ch, Verse: vs}, true, nil
+	}
+
+	if verses {
+		vs, err := parseNumber(c, ErrInvalidVerse)
+		if err != nil {
+			return Location{}, false, err
+		}
+		if chapter == 0 {
+			chapter = 1
+		}
+		return Location{Chapter: chapter, Verse: vs}, true, nil
+	}
+
+	ch, err := parseNumber(c, ErrInvalidChapter)
+	if err != nil {
+		return Location{}, false, err
+	}
+	return Location{Chapter: ch}, false, nil
+}
+
+// parseNumber parses a positive chapter or verse number.
+func parseNumber(s string, kind error) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("%w: missing number", ErrSyntax)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("%w: %d", kind, n)
+	}
+	return n, nil
+}
+
+// validate checks the range against the chapters of its book.
+func validate(r Range) error {
+	for _, l := range []Location{r.Start, r.End} {
+		if l.Chapter > r.Book.Chapters {
+			return fmt.Errorf("%w: %s has %d chapters, not %d", ErrInvalidChapter, r.Book.Name, r.Book.Chapters, l.Chapter)
+		}
+		if l.Verse > maxVerse {
+			return fmt.Errorf("%w: %s %d:%d", ErrInvalidVerse, r.Book.Name, l.Chapter, l.Verse)
+		}
+	}
+
+	switch {
+	case r.End.Chapter < r.Start.Chapter:
+		return fmt.Errorf("%w: chapter %d is before chapter %d", ErrSyntax, r.End.Chapter, r.Start.Chapter)
+	case r.End.Chapter == r.Start.Chapter && r.End.Verse != 0 && r.End.Verse < r.Start.Verse:
+		return fmt.Errorf("%w: verse %d is before verse %d", ErrSyntax, r.End.Verse, r.Start.Verse)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package reference_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kjvonly/service/services/bible/reference"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Books(t *testing.T) {
+	t.Log("Given the need to know the books of the bible.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen counting books and chapters.", testID)
+		{
+			var chapters int
+			for _, b := range reference.Books {
+				chapters += b.Chapters
+			}
+			if len(reference.Books) != 66 || chapters != 1189 {
+				t.Fatalf("\t%s\tTest %d:\tShould have 66 books and 1189 chapters : got %d and %d.", failed, testID, len(reference.Books), chapters)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have 66 books and 1189 chapters.", success, testID)
+
+			if reference.Books[38].Testament != reference.TestamentOld || reference.Books[39].Testament != reference.TestamentNew {
+				t.Fatalf("\t%s\tTest %d:\tShould start the new testament at Matthew.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould start the new testament at Matthew.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen looking up books by name.", testID)
+		{
+			names := map[string]string{
+				"Genesis":         "Genesis",
+				"gen":             "Genesis",
+				"Gen.":            "Genesis",
+				"Genes":           "Genesis",
+				"1 Cor":           "1 Corinthians",
+				"1Cor":            "1 Corinthians",
+				"I Corinthians":   "1 Corinthians",
+				"First Cor":       "1 Corinthians",
+				"2nd Kings":       "2 Kings",
+				"III John":        "3 John",
+				"Isaiah":          "Isaiah",
+				"Song of Solomon": "Song of Solomon",
+				"Song of Songs":   "Song of Solomon",
+				"Ps":              "Psalms",
+				"Psalm":           "Psalms",
+				"Rev":             "Revelation",
+			}
+			for name, want := range names {
+				b, err := reference.LookupBook(name)
+				if err != nil || b.Name != want {
+					t.Fatalf("\t%s\tTest %d:\tShould resolve %q to %s : got %q, %v.", failed, testID, name, want, b.Name, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould resolve names and abbreviations.", success, testID)
+
+			for _, name := range []string{"Phi", "Hezekiah", "4 John", ""} {
+				if _, err := reference.LookupBook(name); !errors.Is(err, reference.ErrInvalidBook) {
+					t.Fatalf("\t%s\tTest %d:\tShould reject %q : got %v.", failed, testID, name, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject unknown and ambiguous names.", success, testID)
+		}
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	tt := []struct {
+		in   string
+		want string
+	}{
+		{"John 3:16", "John 3:16"},
+		{"John 3:16-18", "John 3:16-18"},
+		{"jn 3.16–18", "John 3:16-18"},
+		{"Gen 1", "Genesis 1"},
+		{"Gen 1-3", "Genesis 1-3"},
+		{"Gen. 1", "Genesis 1"},
+		{"Rom. 8:28", "Romans 8:28"},
+		{"1 Cor. 13", "1 Corinthians 13"},
+		{"John 3.16", "John 3:16"},
+		{"John 3:16-4:2", "John 3:16-4:2"},
+		{"John 3-4:2", "John 3:1-4:2"},
+		{"1 Cor 13:4-7; Rom 8:28", "1 Corinthians 13:4-7; Romans 8:28"},
+		{"John 3:16, 18; 4:2", "John 3:16; John 3:18; John 4:2"},
+		{"Jude 3", "Jude 1:3"},
+		{"Obadiah", "Obadiah 1"},
+		{"Ruth", "Ruth 1-4"},
+	}
+
+	t.Log("Given the need to parse scripture references.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen parsing %q.", testID, tc.in)
+			{
+				ref, err := reference.Parse(tc.in)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould parse the reference : %s.", failed, testID, err)
+				}
+				if got := ref.String(); got != tc.want {
+					t.Fatalf("\t%s\tTest %d:\tShould parse to %q : got %q.", failed, testID, tc.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould parse to %q.", success, testID, tc.want)
+			}
+		}
+	}
+
+	invalid := []struct {
+		in  string
+		err error
+	}{
+		{"", reference.ErrSyntax},
+		{"3:16", reference.ErrSyntax},
+		{"Hezekiah 1:1", reference.ErrInvalidBook},
+		{"Gen 51", reference.ErrInvalidChapter},
+		{"Gen 0:1", reference.ErrInvalidChapter},
+		{"John 3:0", reference.ErrInvalidVerse},
+		{"Ps 119:177", reference.ErrInvalidVerse},
+		{"John 3:18-16", reference.ErrSyntax},
+		{"John 4-3", reference.ErrSyntax},
+		{"John 3:16-17-18", reference.ErrSyntax},
+	}
+
+	t.Log("Given the need to reject invalid scripture references.")
+	{
+		for testID, tc := range invalid {
+			t.Logf("\tTest %d:\tWhen parsing %q.", testID, tc.in)
+			{
+				if _, err := reference.Parse(tc.in); !errors.Is(err, tc.err) {
+					t.Fatalf("\t%s\tTest %d:\tShould fail with %v : got %v.", failed, testID, tc.err, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould fail with %v.", success, testID, tc.err)
+			}
+		}
+	}
+}
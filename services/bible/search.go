@@ -2,19 +2,55 @@ package bible
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"git.launchpad.net/~man4christ/+git/seed/auth"
 	"git.launchpad.net/~man4christ/+git/seed/server"
-	"github.com/kjvonly/service/services/bible/stores/elasticsearch"
+	"github.com/kjvonly/service/services/bible/reference"
+	"github.com/kjvonly/service/services/errs"
 	"go.uber.org/zap"
 )
 
+// PermSearch is the permission to search the bible. Searching is open to
+// anonymous callers too; roles and API keys carry it so they can be scoped
+// to searching.
+const PermSearch = "bible:search"
+
+// Limits applied to a search before it reaches the store.
+const (
+	defaultLimit    = 20
+	maxLimit        = 100
+	maxResultWindow = 10000
+	maxTerms        = 16
+	maxTermLength   = 64
+	maxPhraseLength = 256
+	maxCursorLength = 1024
+
+	// MaxFragments is the most highlighted fragments a hit carries.
+	MaxFragments = 5
+
+	defaultPreTag       = "<em>"
+	defaultPostTag      = "</em>"
+	maxTagLength        = 32
+	defaultFragmentSize = 100
+	minFragmentSize     = 18
+	maxFragmentSize     = 1000
+)
+
+// ErrInvalidSearch is returned when a search cannot be translated into a
+// query.
+var ErrInvalidSearch = errs.New(errs.InvalidArgument, "invalid search")
+
 type BibleSearchService interface {
 	Search(req BibleSearchRequest, gr server.GenericRequest) BibleSearchResponse
 }
 
+// Storer interface declares the behavior this package needs to query the
+// bible text.
 type Storer interface {
-	Sql(ctx context.Context, sql string) (*elasticsearch.SqlResult, error)
+	Search(ctx context.Context, s Search) (SearchResults, error)
+	QueryRange(ctx context.Context, r reference.Range) ([]Verse, error)
 }
 
 // Required to register endpoints with the Server
@@ -30,25 +66,144 @@ type BibleSearchServicer struct {
 	auth   auth.Auth
 }
 
+// Search implements BibleSearchRpcService
 func (b BibleSearchServicer) Search(req BibleSearchRequest, gr server.GenericRequest) BibleSearchResponse {
-	res, err := b.storer.Sql(gr.Ctx, req.Query)
+	s, err := normalizeSearch(req.Search)
+	if err != nil {
+		return BibleSearchResponse{
+			Fault: errs.From(err),
+		}
+	}
+
+	res, err := b.storer.Search(gr.Ctx, s)
 	if err != nil {
 		return BibleSearchResponse{
-			Error: err.Error(),
+			Fault: errs.From(err),
 		}
 	}
 	return BibleSearchResponse{
-		SearchResults: SearchResults{SqlResults: *res},
+		SearchResults: res,
+	}
+}
+
+// normalizeSearch applies defaults to a search and rejects any search the
+// stores should not be asked to run. Every field in error is listed with
+// ErrInvalidSearch.
+func normalizeSearch(s Search) (Search, error) {
+	var fe errs.FieldErrors
+
+	terms := make([]string, 0, len(s.Terms))
+	for i, term := range s.Terms {
+		term = strings.TrimSpace(term)
+		switch {
+		case term == "":
+			continue
+		case len(term) > maxTermLength:
+			fe.Addf(fmt.Sprintf("terms[%d]", i), "must be at most %d characters", maxTermLength)
+		}
+		terms = append(terms, term)
+	}
+	s.Terms = terms
+	s.Phrase = strings.TrimSpace(s.Phrase)
+
+	switch {
+	case len(s.Terms) == 0 && s.Phrase == "":
+		fe.Add("terms", "terms or phrase required")
+	case len(s.Terms) > maxTerms:
+		fe.Addf("terms", "at most %d terms allowed", maxTerms)
+	}
+	if len(s.Phrase) > maxPhraseLength {
+		fe.Addf("phrase", "must be at most %d characters", maxPhraseLength)
+	}
+
+	books := make([]string, len(s.Books))
+	for i, name := range s.Books {
+		b, err := reference.LookupBook(name)
+		if err != nil {
+			fe.Addf(fmt.Sprintf("books[%d]", i), "unknown book %q", name)
+			continue
+		}
+		books[i] = b.Name
 	}
+	s.Books = books
+
+	switch s.Mode {
+	case "":
+		s.Mode = MatchAll
+	case MatchAll, MatchAny:
+	default:
+		fe.Addf("mode", "must be %s or %s", MatchAll, MatchAny)
+	}
+
+	switch s.Testament {
+	case "", TestamentOld, TestamentNew:
+	default:
+		fe.Addf("testament", "must be %s or %s", TestamentOld, TestamentNew)
+	}
+
+	if s.Chapters.From < 0 || s.Chapters.To < 0 || (s.Chapters.To != 0 && s.Chapters.From > s.Chapters.To) {
+		fe.Addf("chapters", "invalid chapter range %d-%d", s.Chapters.From, s.Chapters.To)
+	}
+
+	switch {
+	case s.Limit == 0:
+		s.Limit = defaultLimit
+	case s.Limit < 0 || s.Limit > maxLimit:
+		fe.Addf("limit", "must be between 1 and %d", maxLimit)
+	}
+
+	// Deep pages are reached with cursors, which the stores can follow
+	// without the cost of skipping every earlier hit.
+	switch {
+	case len(s.Cursor) > maxCursorLength:
+		fe.Add("cursor", "too long")
+	case s.Cursor != "" && s.Offset != 0:
+		fe.Add("offset", "cannot be combined with a cursor")
+	case s.Offset < 0 || s.Offset+s.Limit > maxResultWindow:
+		fe.Addf("offset", "must be between 0 and %d", maxResultWindow-s.Limit)
+	}
+
+	if s.Highlight != nil {
+		h := normalizeHighlight(&fe, *s.Highlight)
+		s.Highlight = &h
+	}
+
+	if len(fe) > 0 {
+		return Search{}, fmt.Errorf("%w: %w", ErrInvalidSearch, fe)
+	}
+	return s, nil
+}
+
+// normalizeHighlight applies default tags and fragment size to h, adding
+// the errors of its fields to fe.
+func normalizeHighlight(fe *errs.FieldErrors, h Highlight) Highlight {
+	if h.PreTag == "" && h.PostTag == "" {
+		h.PreTag = defaultPreTag
+		h.PostTag = defaultPostTag
+	}
+	if len(h.PreTag) > maxTagLength || len(h.PostTag) > maxTagLength {
+		fe.Addf("highlight", "tags must be at most %d characters", maxTagLength)
+	}
+
+	switch {
+	case h.FragmentSize == 0:
+		h.FragmentSize = defaultFragmentSize
+	case h.FragmentSize < minFragmentSize || h.FragmentSize > maxFragmentSize:
+		fe.Addf("highlight.fragment_size", "must be between %d and %d", minFragmentSize, maxFragmentSize)
+	}
+
+	return h
 }
 
+// BibleSearchRequest is the request object for BibleSearchService.Search.
 type BibleSearchRequest struct {
-	Query string `json:"query"`
+	Search Search `json:"search"`
 }
 
+// BibleSearchResponse is the response object for BibleSearchService.Search.
 type BibleSearchResponse struct {
 	SearchResults SearchResults `json:"search_results"`
-	Error         string        `json:"error,omitempty"`
+	errs.Fault
 }
 
 func (b BibleSearchServicer) Register(s *server.Server) {
@@ -1,2 +1,87 @@
 package bible
 
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kjvonly/service/services/errs"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_NormalizeSearch(t *testing.T) {
+	tt := []struct {
+		name  string
+		in    Search
+		valid bool
+		field string
+	}{
+		{"terms", Search{Terms: []string{" love ", ""}}, true, ""},
+		{"phrase", Search{Phrase: "love of money"}, true, ""},
+		{"empty", Search{Terms: []string{" "}}, false, "terms"},
+		{"long term", Search{Terms: []string{strings.Repeat("a", maxTermLength+1)}}, false, "terms[0]"},
+		{"mode", Search{Terms: []string{"love"}, Mode: "some"}, false, "mode"},
+		{"testament", Search{Terms: []string{"love"}, Testament: "apocrypha"}, false, "testament"},
+		{"chapters", Search{Terms: []string{"love"}, Chapters: Range{From: 5, To: 2}}, false, "chapters"},
+		{"limit", Search{Terms: []string{"love"}, Limit: maxLimit + 1}, false, "limit"},
+		{"offset", Search{Terms: []string{"love"}, Offset: maxResultWindow}, false, "offset"},
+	}
+
+	t.Log("Given the need to validate searches.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen handling a %s search.", testID, tc.name)
+			{
+				s, err := normalizeSearch(tc.in)
+				if tc.valid {
+					if err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould accept the search : %s.", failed, testID, err)
+					}
+					if s.Mode != MatchAll || s.Limit != defaultLimit {
+						t.Fatalf("\t%s\tTest %d:\tShould apply defaults : got %+v.", failed, testID, s)
+					}
+					for _, term := range s.Terms {
+						if term != "love" {
+							t.Fatalf("\t%s\tTest %d:\tShould trim terms : got %q.", failed, testID, s.Terms)
+						}
+					}
+					t.Logf("\t%s\tTest %d:\tShould accept the search.", success, testID)
+					continue
+				}
+
+				var fe errs.FieldErrors
+				if !errors.Is(err, ErrInvalidSearch) || !errors.As(err, &fe) || fe[0].Field != tc.field {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the %s field : got %v.", failed, testID, tc.field, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould reject the %s field.", success, testID, tc.field)
+			}
+		}
+	}
+}
+
+func Test_NormalizeSearchBooks(t *testing.T) {
+	t.Log("Given the need to filter searches by book.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen books are abbreviated.", testID)
+		{
+			s, err := normalizeSearch(Search{Terms: []string{"love"}, Books: []string{"1 Cor", "jn"}})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the search : %s.", failed, testID, err)
+			}
+			if s.Books[0] != "1 Corinthians" || s.Books[1] != "John" {
+				t.Fatalf("\t%s\tTest %d:\tShould resolve book names : got %q.", failed, testID, s.Books)
+			}
+			t.Logf("\t%s\tTest %d:\tShould resolve book names.", success, testID)
+
+			if _, err := normalizeSearch(Search{Terms: []string{"love"}, Books: []string{"Hezekiah"}}); !errors.Is(err, ErrInvalidSearch) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject unknown books : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject unknown books.", success, testID)
+		}
+	}
+}
@@ -7,71 +7,221 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/bible/reference"
+	"github.com/kjvonly/service/services/errs"
 	"go.uber.org/zap"
 )
 
+const indexName = "kjvonly"
+
 type Store struct {
 	log              *zap.SugaredLogger
 	elasticSearchUrl string
+	client           *http.Client
+}
+
+// Search runs a structured search against the verse index. Pages are
+// continued with search_after, so paging stays cheap however deep it goes.
+func (s Store) Search(ctx context.Context, search bible.Search) (bible.SearchResults, error) {
+	var after []any
+	if search.Cursor != "" {
+		if err := bible.DecodeCursor(search, &after); err != nil {
+			return bible.SearchResults{}, err
+		}
+	}
+
+	var res searchResponse
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("/%s/_search", indexName), buildSearch(search, after), &res); err != nil {
+		return bible.SearchResults{}, err
+	}
+
+	results := bible.SearchResults{
+		Hits:  make([]bible.Hit, 0, search.Limit),
+		Total: res.Hits.Total.Value,
+	}
+
+	page := res.Hits.Hits
+	if len(page) > search.Limit {
+		page = page[:search.Limit]
+		next, err := bible.EncodeCursor(search, page[len(page)-1].Sort)
+		if err != nil {
+			return bible.SearchResults{}, err
+		}
+		results.NextCursor = next
+	}
+
+	for _, h := range page {
+		results.Hits = append(results.Hits, bible.Hit{
+			Verse:      toCoreVerse(h.Source),
+			Score:      h.Score,
+			Highlights: h.Highlight.Text,
+		})
+	}
+	return results, nil
 }
 
+// QueryRange returns the verses of r in order.
+func (s Store) QueryRange(ctx context.Context, r reference.Range) ([]bible.Verse, error) {
+	var res searchResponse
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("/%s/_search", indexName), buildRange(r), &res); err != nil {
+		return nil, err
+	}
+
+	verses := make([]bible.Verse, len(res.Hits.Hits))
+	for i, h := range res.Hits.Hits {
+		verses[i] = toCoreVerse(h.Source)
+	}
+	return verses, nil
+}
+
+// maxSqlRows is the most rows Sql collects before giving up on a query.
+const maxSqlRows = 10000
+
+// ErrTooManyRows is returned when an SQL query matches more than maxSqlRows
+// rows.
+var ErrTooManyRows = fmt.Errorf("sql query returned more than %d rows", maxSqlRows)
+
+// Sql runs an Elasticsearch SQL query, following the cursor ES returns
+// until every row has been read. A query matching more than maxSqlRows rows
+// fails rather than being truncated, and its cursor is closed.
 func (s Store) Sql(ctx context.Context, sql string) (*SqlResult, error) {
-	requestURL := fmt.Sprintf("%s/_sql?format=json", s.elasticSearchUrl)
-	b, err := json.Marshal(struct {
+	var sqlResult SqlResult
+	body := struct {
 		Query string `json:"query"`
-	}{Query: sql})
+	}{Query: sql}
 
-	if err != nil {
+	if err := s.do(ctx, http.MethodPost, "/_sql?format=json", body, &sqlResult); err != nil {
+		return nil, err
+	}
+
+	for sqlResult.Cursor != "" {
+		if len(sqlResult.Rows) > maxSqlRows {
+			s.closeCursor(sqlResult.Cursor)
+			return nil, ErrTooManyRows
+		}
+
+		var page SqlResult
+		body := struct {
+			Cursor string `json:"cursor"`
+		}{Cursor: sqlResult.Cursor}
 
+		if err := s.do(ctx, http.MethodPost, "/_sql?format=json", body, &page); err != nil {
+			s.closeCursor(sqlResult.Cursor)
+			return nil, err
+		}
+
+		sqlResult.Rows = append(sqlResult.Rows, page.Rows...)
+		sqlResult.Cursor = page.Cursor
 	}
 
-	req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewBuffer(b))
+	if len(sqlResult.Rows) > maxSqlRows {
+		return nil, ErrTooManyRows
+	}
 
+	return &sqlResult, nil
+}
+
+// closeCursor releases an SQL cursor that will not be read to the end. It
+// runs on its own context since the query's context may be what failed.
+func (s Store) closeCursor(cursor string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	body := struct {
+		Cursor string `json:"cursor"`
+	}{Cursor: cursor}
+
+	var res struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/_sql/close", body, &res); err != nil {
+		s.log.Infof("client: closing sql cursor: %s", err)
+	}
+}
+
+// do sends body as JSON to the Elasticsearch endpoint at path and decodes the
+// response into v.
+func (s Store) do(ctx context.Context, method string, path string, body any, v any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("client: could not marshal request body: %w", err)
+	}
+	return s.doRaw(ctx, method, path, "application/json", bytes.NewBuffer(b), v)
+}
+
+// doRaw sends body to the Elasticsearch endpoint at path and decodes the
+// response into v. A v of nil discards the response.
+func (s Store) doRaw(ctx context.Context, method string, path string, contentType string, body io.Reader, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.elasticSearchUrl+path, body)
 	if err != nil {
-		errMsg := fmt.Sprintf("client: could not create request: %s\n", err)
-		s.log.Infof(errMsg)
-		return nil, fmt.Errorf(errMsg)
+		s.log.Infof("client: could not create request: %s", err)
+		return fmt.Errorf("client: could not create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Cache-Control", "no-cache")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := s.client.Do(req)
 	if err != nil {
-		errMsg := fmt.Sprintf("client: error making http request: %s\n", err)
-		s.log.Infof(errMsg)
-		return nil, fmt.Errorf(errMsg)
+		s.log.Infof("client: error making http request: %s", err)
+		return errs.Wrap(errs.Unavailable, fmt.Errorf("client: error making http request: %w", err))
 	}
+	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		errMsg := fmt.Sprintf("client: %d http response\n", res.StatusCode)
-		s.log.Infof(errMsg)
-		return nil, fmt.Errorf(errMsg)
+	if res.StatusCode != http.StatusOK {
+		s.log.Infof("client: %d http response", res.StatusCode)
+		if res.StatusCode >= http.StatusInternalServerError {
+			return errs.Wrap(errs.Unavailable, &StatusError{StatusCode: res.StatusCode})
+		}
+		return &StatusError{StatusCode: res.StatusCode}
 	}
 
-	defer res.Body.Close()
 	resBody, err := io.ReadAll(res.Body)
-
 	if err != nil {
-		errMsg := fmt.Sprintf("client: could not read response body: %s\n", err)
-		s.log.Infof(errMsg)
-		return nil, fmt.Errorf(errMsg)
+		s.log.Infof("client: could not read response body: %s", err)
+		return fmt.Errorf("client: could not read response body: %w", err)
 	}
 
-	var sqlResult SqlResult
-	err = json.Unmarshal(resBody, &sqlResult)
-	if err != nil {
-		errMsg := fmt.Sprintf("client: could unmarshal response body: %s\n", err)
-		s.log.Infof(errMsg)
-		return nil, fmt.Errorf(errMsg)
+	if v == nil {
+		return nil
 	}
 
-	return &sqlResult, nil
+	if err := json.Unmarshal(resBody, v); err != nil {
+		s.log.Infof("client: could not unmarshal response body: %s", err)
+		return fmt.Errorf("client: could not unmarshal response body: %w", err)
+	}
+
+	return nil
 }
 
+// StatusError is returned when Elasticsearch answers with a status other
+// than 200.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("client: %d http response", e.StatusCode)
+}
+
+// NewStore returns a store querying the Elasticsearch cluster at
+// elasticSearchUrl. The store keeps its own connections, which Close
+// releases.
 func NewStore(log *zap.SugaredLogger, elasticSearchUrl string) *Store {
 	return &Store{
 		log:              log,
 		elasticSearchUrl: elasticSearchUrl,
+		client: &http.Client{
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
 	}
 }
+
+// Close releases the idle connections to the cluster. Requests still in
+// flight are left to finish.
+func (s Store) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,108 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kjvonly/service/services/bible"
+)
+
+// mapping holds the settings and mappings the verse index is created with.
+//
+//go:embed mapping.json
+var mapping []byte
+
+// CreateIndex creates the verse index with its analyzers and mappings.
+func (s Store) CreateIndex(ctx context.Context) error {
+	if err := s.doRaw(ctx, http.MethodPut, "/"+indexName, "application/json", bytes.NewReader(mapping), nil); err != nil {
+		return fmt.Errorf("create index %s: %w", indexName, err)
+	}
+	return nil
+}
+
+// DeleteIndex deletes the verse index. Deleting an index that does not
+// exist is not an error.
+func (s Store) DeleteIndex(ctx context.Context) error {
+	err := s.doRaw(ctx, http.MethodDelete, "/"+indexName, "application/json", nil, nil)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("delete index %s: %w", indexName, err)
+	}
+	return nil
+}
+
+// BulkIndex writes verses to the index with a single _bulk request. Verses
+// are keyed by book, chapter and verse so loading the same text twice
+// replaces documents instead of duplicating them.
+func (s Store) BulkIndex(ctx context.Context, verses []bible.Verse) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, v := range verses {
+		doc, err := toESVerse(v)
+		if err != nil {
+			return err
+		}
+
+		action := map[string]any{
+			"index": map[string]any{"_id": verseID(v)},
+		}
+		if err := enc.Encode(action); err != nil {
+			return fmt.Errorf("encode action: %w", err)
+		}
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("encode verse: %w", err)
+		}
+	}
+
+	var res bulkResponse
+	path := fmt.Sprintf("/%s/_bulk", indexName)
+	if err := s.doRaw(ctx, http.MethodPost, path, "application/x-ndjson", &buf, &res); err != nil {
+		return fmt.Errorf("bulk: %w", err)
+	}
+
+	if res.Errors {
+		for _, item := range res.Items {
+			if item.Index.Error != nil {
+				return fmt.Errorf("bulk: %s: %s: %s", item.Index.ID, item.Index.Error.Type, item.Index.Error.Reason)
+			}
+		}
+		return errors.New("bulk: request reported errors")
+	}
+
+	return nil
+}
+
+// Refresh makes every indexed verse visible to searches.
+func (s Store) Refresh(ctx context.Context) error {
+	if err := s.doRaw(ctx, http.MethodPost, fmt.Sprintf("/%s/_refresh", indexName), "application/json", nil, nil); err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of verses in the index.
+func (s Store) Count(ctx context.Context) (int, error) {
+	var res struct {
+		Count int `json:"count"`
+	}
+	if err := s.doRaw(ctx, http.MethodGet, fmt.Sprintf("/%s/_count", indexName), "application/json", nil, &res); err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+	return res.Count, nil
+}
+
+// verseID returns the document id of v, e.g. "1-corinthians.13.4".
+func verseID(v bible.Verse) string {
+	book := strings.ReplaceAll(strings.ToLower(v.Book), " ", "-")
+	return fmt.Sprintf("%s.%d.%d", book, v.Chapter, v.Verse)
+}
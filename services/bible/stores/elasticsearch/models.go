@@ -1,10 +1,82 @@
 package elasticsearch
 
+import (
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/bible/reference"
+)
+
 type SqlResult struct {
 	Columns []Column `json:"columns"`
 	Rows    [][]any  `json:"rows"`
+	Cursor  string   `json:"cursor,omitempty"`
 }
 type Column struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
 }
+
+// esVerse is the document stored in the index for every verse.
+type esVerse struct {
+	Book      string `json:"book"`
+	BookOrder int    `json:"book_order"`
+	Chapter   int    `json:"chapter"`
+	Verse     int    `json:"verse"`
+	Text      string `json:"text"`
+	Testament string `json:"testament"`
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+type searchHit struct {
+	Score     float64 `json:"_score"`
+	Sort      []any   `json:"sort"`
+	Source    esVerse `json:"_source"`
+	Highlight struct {
+		Text []string `json:"text"`
+	} `json:"highlight"`
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []struct {
+		Index struct {
+			ID    string `json:"_id"`
+			Error *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"index"`
+	} `json:"items"`
+}
+
+func toESVerse(v bible.Verse) (esVerse, error) {
+	book, err := reference.LookupBook(v.Book)
+	if err != nil {
+		return esVerse{}, err
+	}
+
+	return esVerse{
+		Book:      book.Name,
+		BookOrder: book.Order,
+		Chapter:   v.Chapter,
+		Verse:     v.Verse,
+		Text:      v.Text,
+		Testament: book.Testament,
+	}, nil
+}
+
+func toCoreVerse(v esVerse) bible.Verse {
+	return bible.Verse{
+		Book:    v.Book,
+		Chapter: v.Chapter,
+		Verse:   v.Verse,
+		Text:    v.Text,
+	}
+}
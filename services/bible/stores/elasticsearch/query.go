@@ -0,0 +1,166 @@
+package elasticsearch
+
+import (
+	"strings"
+
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/bible/reference"
+)
+
+// buildSearch translates a search into an Elasticsearch query DSL body. User
+// input only ever ends up as query values, never as field or index names.
+// One hit more than the limit is asked for to learn whether another page
+// follows. Pages after the first continue from the sort values in after.
+func buildSearch(s bible.Search, after []any) map[string]any {
+	var must []any
+	if len(s.Terms) > 0 {
+		operator := "and"
+		if s.Mode == bible.MatchAny {
+			operator = "or"
+		}
+		must = append(must, map[string]any{
+			"match": map[string]any{
+				"text": map[string]any{
+					"query":    strings.Join(s.Terms, " "),
+					"operator": operator,
+				},
+			},
+		})
+	}
+	if s.Phrase != "" {
+		must = append(must, map[string]any{
+			"match_phrase": map[string]any{
+				"text": s.Phrase,
+			},
+		})
+	}
+
+	filter := []any{}
+	if len(s.Books) > 0 {
+		filter = append(filter, map[string]any{
+			"terms": map[string]any{"book": s.Books},
+		})
+	}
+	if r := rangeQuery(s.Chapters); r != nil {
+		filter = append(filter, map[string]any{
+			"range": map[string]any{"chapter": r},
+		})
+	}
+	if s.Testament != "" {
+		filter = append(filter, map[string]any{
+			"term": map[string]any{"testament": s.Testament},
+		})
+	}
+
+	body := map[string]any{
+		"size":             s.Limit + 1,
+		"track_total_hits": true,
+		"sort": []any{
+			map[string]any{"_score": "desc"},
+			map[string]any{"book_order": "asc"},
+			map[string]any{"chapter": "asc"},
+			map[string]any{"verse": "asc"},
+		},
+		"query": map[string]any{
+			"bool": map[string]any{
+				"must":   must,
+				"filter": filter,
+			},
+		},
+	}
+	if after != nil {
+		body["search_after"] = after
+	} else {
+		body["from"] = s.Offset
+	}
+	if s.Highlight != nil {
+		body["highlight"] = buildHighlight(*s.Highlight)
+	}
+	return body
+}
+
+// buildHighlight asks for the matches in the text of each hit to be wrapped
+// in the requested tags. Zero fragments returns the whole verse.
+func buildHighlight(h bible.Highlight) map[string]any {
+	fragments := bible.MaxFragments
+	if h.WholeVerse {
+		fragments = 0
+	}
+
+	return map[string]any{
+		"pre_tags":  []string{h.PreTag},
+		"post_tags": []string{h.PostTag},
+		"fields": map[string]any{
+			"text": map[string]any{
+				"fragment_size":       h.FragmentSize,
+				"number_of_fragments": fragments,
+			},
+		},
+	}
+}
+
+// maxRangeVerses is the most verses a range query returns. It covers the
+// whole of Psalms, the longest book.
+const maxRangeVerses = 10000
+
+// buildRange translates a reference range into a query returning its verses
+// in order. A range crossing chapters matches the tail of the first chapter,
+// any whole chapters between and the head of the last chapter.
+func buildRange(r reference.Range) map[string]any {
+	var should []any
+	if r.Start.Chapter == r.End.Chapter {
+		should = append(should, chapterQuery(r.Start.Chapter, bible.Range{From: r.Start.Verse, To: r.End.Verse}))
+	} else {
+		should = append(should, chapterQuery(r.Start.Chapter, bible.Range{From: r.Start.Verse}))
+		if r.End.Chapter-r.Start.Chapter > 1 {
+			should = append(should, map[string]any{
+				"range": map[string]any{"chapter": map[string]any{"gt": r.Start.Chapter, "lt": r.End.Chapter}},
+			})
+		}
+		should = append(should, chapterQuery(r.End.Chapter, bible.Range{To: r.End.Verse}))
+	}
+
+	return map[string]any{
+		"size": maxRangeVerses,
+		"sort": []any{
+			map[string]any{"chapter": "asc"},
+			map[string]any{"verse": "asc"},
+		},
+		"query": map[string]any{
+			"bool": map[string]any{
+				"filter": []any{
+					map[string]any{"term": map[string]any{"book": r.Book.Name}},
+					map[string]any{"bool": map[string]any{"should": should, "minimum_should_match": 1}},
+				},
+			},
+		},
+	}
+}
+
+// chapterQuery matches the verses of chapter within verses.
+func chapterQuery(chapter int, verses bible.Range) map[string]any {
+	filter := []any{
+		map[string]any{"term": map[string]any{"chapter": chapter}},
+	}
+	if r := rangeQuery(verses); r != nil {
+		filter = append(filter, map[string]any{"range": map[string]any{"verse": r}})
+	}
+	return map[string]any{"bool": map[string]any{"filter": filter}}
+}
+
+// rangeQuery returns the bounds of r as a range query, or nil when r is open
+// on both sides.
+func rangeQuery(r bible.Range) map[string]any {
+	if r.From == 0 && r.To == 0 {
+		return nil
+	}
+
+	q := map[string]any{}
+	if r.From != 0 {
+		q["gte"] = r.From
+	}
+	if r.To != 0 {
+		q["lte"] = r.To
+	}
+	return q
+}
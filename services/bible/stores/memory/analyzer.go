@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"strings"
+	"unicode"
+)
+
+// token is a single analyzed term of a text. Pos counts every word of the
+// text, including stop words, so phrases keep their gaps. Start and End are
+// byte offsets of the original word.
+type token struct {
+	term  string
+	pos   int
+	start int
+	end   int
+}
+
+// Set of words dropped from text and queries, matching Elasticsearch's
+// _english_ stop word list.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true,
+	"their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// analyze splits text into words, lower cases them, strips possessives,
+// drops stop words and stems what remains.
+func analyze(text string) []token {
+	var tokens []token
+	pos := 0
+	start := -1
+	for i, r := range text + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || ((r == '\'' || r == '’') && start != -1) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start == -1 {
+			continue
+		}
+
+		word := strings.ToLower(text[start:i])
+		word = strings.TrimSuffix(strings.ReplaceAll(word, "’", "'"), "'s")
+		word = strings.ReplaceAll(word, "'", "")
+		if word != "" && !stopWords[word] {
+			tokens = append(tokens, token{term: stem(word), pos: pos, start: start, end: i})
+		}
+		pos++
+		start = -1
+	}
+	return tokens
+}
+
+// terms returns the distinct terms of text in the order they first appear.
+func terms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, t := range analyze(text) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
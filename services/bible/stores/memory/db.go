@@ -0,0 +1,196 @@
+// Package memory implements bible.Storer over an inverted index held in
+// process, so the bible services can run without Elasticsearch.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/bible/reference"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	log    *zap.SugaredLogger
+	verses []bible.Verse
+	books  map[string]reference.Book
+	index  *index
+}
+
+// NewStore builds the index over verses. Verses are kept in canonical
+// order regardless of the order they are given in.
+func NewStore(log *zap.SugaredLogger, verses []bible.Verse) *Store {
+	books := map[string]reference.Book{}
+	for _, b := range reference.Books {
+		books[b.Name] = b
+	}
+
+	s := Store{
+		log:    log,
+		verses: make([]bible.Verse, len(verses)),
+		books:  books,
+	}
+	copy(s.verses, verses)
+	sort.SliceStable(s.verses, func(i, j int) bool { return s.before(s.verses[i], s.verses[j]) })
+
+	texts := make([]string, len(s.verses))
+	for i, v := range s.verses {
+		texts[i] = v.Text
+	}
+	s.index = newIndex(texts)
+
+	log.Infof("indexed %d verses", len(s.verses))
+
+	return &s
+}
+
+// Search runs a structured search against the index with the same
+// semantics as the Elasticsearch store. Cursors carry the offset of the
+// next page.
+func (s *Store) Search(ctx context.Context, search bible.Search) (bible.SearchResults, error) {
+	offset := search.Offset
+	if search.Cursor != "" {
+		if err := bible.DecodeCursor(search, &offset); err != nil {
+			return bible.SearchResults{}, err
+		}
+		if offset < 0 {
+			return bible.SearchResults{}, fmt.Errorf("%w: negative offset", bible.ErrInvalidCursor)
+		}
+	}
+
+	docs, scoreTerms := s.match(search)
+
+	books := map[string]bool{}
+	for _, name := range search.Books {
+		books[name] = true
+	}
+
+	hits := []bible.Hit{}
+	for doc := range docs {
+		v := s.verses[doc]
+		switch {
+		case len(books) > 0 && !books[v.Book]:
+			continue
+		case search.Chapters.From != 0 && v.Chapter < search.Chapters.From:
+			continue
+		case search.Chapters.To != 0 && v.Chapter > search.Chapters.To:
+			continue
+		case search.Testament != "" && s.books[v.Book].Testament != search.Testament:
+			continue
+		}
+
+		hits = append(hits, bible.Hit{
+			Verse: v,
+			Score: s.index.score(doc, scoreTerms),
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return s.before(hits[i].Verse, hits[j].Verse)
+	})
+
+	results := bible.SearchResults{
+		Hits:  []bible.Hit{},
+		Total: len(hits),
+	}
+	if offset >= len(hits) {
+		return results, nil
+	}
+
+	hits = hits[offset:]
+	if search.Limit > 0 && len(hits) > search.Limit {
+		hits = hits[:search.Limit]
+		next, err := bible.EncodeCursor(search, offset+search.Limit)
+		if err != nil {
+			return bible.SearchResults{}, err
+		}
+		results.NextCursor = next
+	}
+
+	if search.Highlight != nil {
+		matched := map[string]bool{}
+		for _, term := range scoreTerms {
+			matched[term] = true
+		}
+		for i := range hits {
+			hits[i].Highlights = highlight(hits[i].Text, matched, *search.Highlight)
+		}
+	}
+
+	results.Hits = hits
+	return results, nil
+}
+
+// match returns the documents matching the terms and phrase of search along
+// with the terms to score them by. Like Elasticsearch, terms or a phrase
+// made up only of stop words match nothing.
+func (s *Store) match(search bible.Search) (map[int]bool, []string) {
+	var queryTerms []string
+	for _, term := range search.Terms {
+		queryTerms = append(queryTerms, terms(term)...)
+	}
+	phrase := analyze(search.Phrase)
+
+	if (len(search.Terms) > 0 && len(queryTerms) == 0) || (search.Phrase != "" && len(phrase) == 0) {
+		return map[int]bool{}, nil
+	}
+
+	var docs map[int]bool
+	if len(queryTerms) > 0 {
+		if search.Mode == bible.MatchAny {
+			docs = s.index.any(queryTerms)
+		} else {
+			docs = s.index.all(queryTerms)
+		}
+	}
+
+	if len(phrase) > 0 {
+		phraseDocs := s.index.phrase(phrase)
+		if docs == nil {
+			docs = phraseDocs
+		} else {
+			for doc := range docs {
+				if !phraseDocs[doc] {
+					delete(docs, doc)
+				}
+			}
+		}
+		for _, t := range phrase {
+			queryTerms = append(queryTerms, t.term)
+		}
+	}
+
+	return docs, queryTerms
+}
+
+// QueryRange returns the verses of r in order.
+func (s *Store) QueryRange(ctx context.Context, r reference.Range) ([]bible.Verse, error) {
+	start := bible.Verse{Book: r.Book.Name, Chapter: r.Start.Chapter, Verse: r.Start.Verse}
+	i := sort.Search(len(s.verses), func(i int) bool { return !s.before(s.verses[i], start) })
+
+	verses := []bible.Verse{}
+	for ; i < len(s.verses); i++ {
+		v := s.verses[i]
+		if v.Book != r.Book.Name || v.Chapter > r.End.Chapter || (v.Chapter == r.End.Chapter && r.End.Verse != 0 && v.Verse > r.End.Verse) {
+			break
+		}
+		verses = append(verses, v)
+	}
+	return verses, nil
+}
+
+// before reports whether a comes before b in canonical order.
+func (s *Store) before(a, b bible.Verse) bool {
+	if a.Book != b.Book {
+		return s.books[a.Book].Order < s.books[b.Book].Order
+	}
+	if a.Chapter != b.Chapter {
+		return a.Chapter < b.Chapter
+	}
+	return a.Verse < b.Verse
+}
@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/bible/kjv"
+	"github.com/kjvonly/service/services/bible/reference"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Stem(t *testing.T) {
+	words := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"loved":          "love",
+		"loving":         "love",
+		"righteousness":  "righteous",
+	}
+
+	t.Log("Given the need to stem English words.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen stemming known words.", testID)
+		{
+			for word, want := range words {
+				if got := stem(word); got != want {
+					t.Fatalf("\t%s\tTest %d:\tShould stem %q to %q : got %q.", failed, testID, word, want, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould stem known words.", success, testID)
+		}
+	}
+}
+
+func Test_Store(t *testing.T) {
+	verses, err := kjv.Load("../../../../testdata/kjv_sample.tsv")
+	if err != nil {
+		t.Fatalf("loading sample: %s", err)
+	}
+	store := NewStore(zap.NewNop().Sugar(), verses)
+	ctx := context.Background()
+
+	t.Log("Given the need to search the bible without Elasticsearch.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen searching by terms.", testID)
+		{
+			res, err := store.Search(ctx, bible.Search{Terms: []string{"loving"}, Mode: bible.MatchAll, Limit: 10})
+			hits := res.Hits
+			if err != nil || len(hits) != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould match stemmed terms : got %d hits, %v.", failed, testID, len(hits), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould match stemmed terms.", success, testID)
+
+			res, _ = store.Search(ctx, bible.Search{Terms: []string{"love", "money"}, Mode: bible.MatchAll, Limit: 10})
+			hits = res.Hits
+			if len(hits) != 1 || hits[0].Book != "1 Timothy" {
+				t.Fatalf("\t%s\tTest %d:\tShould require all terms : got %+v.", failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require all terms.", success, testID)
+
+			res, _ = store.Search(ctx, bible.Search{Terms: []string{"money", "mammon"}, Mode: bible.MatchAny, Limit: 10})
+			hits = res.Hits
+			if len(hits) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould match any term : got %+v.", failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould match any term.", success, testID)
+
+			res, _ = store.Search(ctx, bible.Search{Terms: []string{"the"}, Mode: bible.MatchAll, Limit: 10})
+			hits = res.Hits
+			if len(hits) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould ignore stop words : got %d hits.", failed, testID, len(hits))
+			}
+			t.Logf("\t%s\tTest %d:\tShould ignore stop words.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen searching by phrase.", testID)
+		{
+			res, _ := store.Search(ctx, bible.Search{Phrase: "in the beginning", Limit: 10})
+			hits := res.Hits
+			if len(hits) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould match the phrase : got %+v.", failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould match the phrase.", success, testID)
+
+			res, _ = store.Search(ctx, bible.Search{Phrase: "begotten son", Testament: bible.TestamentNew, Books: []string{"John"}, Chapters: bible.Range{From: 3, To: 3}, Limit: 1, Offset: 1})
+			hits = res.Hits
+			if len(hits) != 1 || hits[0].Chapter != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould filter and page : got %+v.", failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould filter and page.", success, testID)
+
+			res, _ = store.Search(ctx, bible.Search{Phrase: "son begotten", Limit: 10})
+			hits = res.Hits
+			if len(hits) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould respect word order : got %+v.", failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respect word order.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen paging with a cursor.", testID)
+		{
+			search := bible.Search{Terms: []string{"loving"}, Mode: bible.MatchAll, Limit: 3}
+			first, err := store.Search(ctx, search)
+			if err != nil || len(first.Hits) != 3 || first.Total != 4 || first.NextCursor == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould return a cursor for the next page : got %+v, %v.", failed, testID, first, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return a cursor for the next page.", success, testID)
+
+			search.Cursor = first.NextCursor
+			second, err := store.Search(ctx, search)
+			if err != nil || len(second.Hits) != 1 || second.NextCursor != "" || second.Hits[0].Verse == first.Hits[2].Verse && second.Hits[0].Book == first.Hits[2].Book {
+				t.Fatalf("\t%s\tTest %d:\tShould continue from the cursor : got %+v, %v.", failed, testID, second, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould continue from the cursor.", success, testID)
+
+			search.Terms = []string{"money"}
+			if _, err := store.Search(ctx, search); !errors.Is(err, bible.ErrInvalidCursor) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a cursor from another search : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a cursor from another search.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen reading a range.", testID)
+		{
+			ref, _ := reference.Parse("John 3:16-18")
+			verses, err := store.QueryRange(ctx, ref[0])
+			if err != nil || len(verses) != 3 || verses[0].Verse != 16 || verses[2].Verse != 18 {
+				t.Fatalf("\t%s\tTest %d:\tShould return the verses in order : got %+v, %v.", failed, testID, verses, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return the verses in order.", success, testID)
+		}
+	}
+}
+
+func Test_Highlight(t *testing.T) {
+	verses, err := kjv.Load("../../../../testdata/kjv_sample.tsv")
+	if err != nil {
+		t.Fatalf("loading sample: %s", err)
+	}
+	store := NewStore(zap.NewNop().Sugar(), verses)
+	ctx := context.Background()
+
+	t.Log("Given the need to mark matched words in hits.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen highlighting the whole verse.", testID)
+		{
+			h := bible.Highlight{PreTag: "<b>", PostTag: "</b>", FragmentSize: 100, WholeVerse: true}
+			res, _ := store.Search(ctx, bible.Search{Terms: []string{"money"}, Limit: 1, Highlight: &h})
+			hits := res.Hits
+			want := "For the love of <b>money</b> is the root of all evil: which while some coveted after, they have erred from the faith, and pierced themselves through with many sorrows."
+			if len(hits) != 1 || len(hits[0].Highlights) != 1 || hits[0].Highlights[0] != want {
+				t.Fatalf("\t%s\tTest %d:\tShould mark the whole verse : got %+v.", failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mark the whole verse.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen highlighting fragments.", testID)
+		{
+			h := bible.Highlight{PreTag: "<em>", PostTag: "</em>", FragmentSize: 30}
+			res, _ := store.Search(ctx, bible.Search{Terms: []string{"charity"}, Limit: 1, Highlight: &h})
+			hits := res.Hits
+			if len(hits) != 1 || len(hits[0].Highlights) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould return fragments : got %+v.", failed, testID, hits)
+			}
+			for _, f := range hits[0].Highlights {
+				if len(f) > 30+len("<em></em>")*3 || !strings.Contains(f, "<em>Charity</em>") && !strings.Contains(f, "<em>charity</em>") {
+					t.Fatalf("\t%s\tTest %d:\tShould keep fragments short and marked : got %q.", failed, testID, f)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould return short marked fragments.", success, testID)
+		}
+	}
+}
+
+func Test_Fragments(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		size  int
+		want  []string
+	}{
+		{
+			name:  "short match",
+			text:  "Charity suffereth long, and is kind; charity envieth not.",
+			query: "kind",
+			size:  20,
+			want:  []string{"and is <em>kind</em>;"},
+		},
+		{
+			name:  "word longer than fragment",
+			text:  "Then said the LORD to me, Call his name Mahershalalhashbazzz.",
+			query: "mahershalalhashbazzz",
+			size:  18,
+			want:  []string{"<em>Mahershalalhashbazzz</em>"},
+		},
+		{
+			name:  "phrase longer than fragment",
+			text:  "Charity suffereth long, and is kind; charity envieth not; charity vaunteth not itself.",
+			query: "charity envieth not charity vaunteth",
+			size:  18,
+			want:  []string{"<em>Charity</em> suffereth", "<em>charity</em>", "<em>envieth</em> not;", "<em>charity</em>", "<em>vaunteth</em> not"},
+		},
+	}
+
+	t.Log("Given the need to cut highlighted hits into fragments.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen the case is %q.", testID, tt.name)
+			{
+				terms := map[string]bool{}
+				for _, tok := range analyze(tt.query) {
+					terms[tok.term] = true
+				}
+				h := bible.Highlight{PreTag: "<em>", PostTag: "</em>", FragmentSize: tt.size}
+				got := highlight(tt.text, terms, h)
+				if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+					t.Fatalf("\t%s\tTest %d:\tShould return the fragments %q : got %q.", failed, testID, tt.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould return the fragments.", success, testID)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"strings"
+
+	"github.com/kjvonly/service/services/bible"
+)
+
+// highlight wraps the words of text whose terms were searched for in the
+// tags of h. Unless h asks for the whole verse, the marked text is cut into
+// fragments of about h.FragmentSize characters around the matches.
+func highlight(text string, terms map[string]bool, h bible.Highlight) []string {
+	var matches []token
+	for _, t := range analyze(text) {
+		if terms[t.term] {
+			matches = append(matches, t)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	if h.WholeVerse {
+		return []string{mark(text, 0, len(text), matches, h)}
+	}
+
+	var fragments []string
+	for i := 0; i < len(matches) && len(fragments) < bible.MaxFragments; {
+		start, end := fragmentBounds(text, matches[i], h.FragmentSize)
+
+		j := i + 1
+		for j < len(matches) && matches[j].end <= end {
+			j++
+		}
+
+		fragments = append(fragments, mark(text, start, end, matches[i:j], h))
+		i = j
+	}
+	return fragments
+}
+
+// fragmentBounds returns the byte offsets of a fragment of about size
+// characters centred on match, widened or narrowed to whole words.
+func fragmentBounds(text string, match token, size int) (int, int) {
+	start := match.start - (size-(match.end-match.start))/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + size
+	if end > len(text) {
+		end = len(text)
+		start = end - size
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	// A match longer than size is returned whole.
+	if start > match.start {
+		start = match.start
+	}
+	if end < match.end {
+		end = match.end
+	}
+
+	if start > 0 {
+		if i := strings.IndexByte(text[start:match.start], ' '); i != -1 {
+			start += i + 1
+		} else {
+			start = match.start
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[match.end:end], ' '); i != -1 {
+			end = match.end + i
+		} else {
+			end = match.end
+		}
+	}
+
+	return start, end
+}
+
+// mark returns text[start:end] with every match wrapped in the tags of h.
+func mark(text string, start int, end int, matches []token, h bible.Highlight) string {
+	var b strings.Builder
+	pos := start
+	for _, m := range matches {
+		if m.start < pos || m.end > end {
+			continue
+		}
+		b.WriteString(text[pos:m.start])
+		b.WriteString(h.PreTag)
+		b.WriteString(text[m.start:m.end])
+		b.WriteString(h.PostTag)
+		pos = m.end
+	}
+	b.WriteString(text[pos:end])
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"math"
+	"sort"
+)
+
+// BM25 tuning parameters, using the Elasticsearch defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// posting records the positions of a term within one verse.
+type posting struct {
+	doc       int
+	positions []int
+}
+
+// index is an inverted index over the verses of a Store. Documents are
+// identified by their position in the store's verse slice. It is never
+// modified after it is built, so it is safe for concurrent use.
+type index struct {
+	postings map[string][]posting
+	lengths  []int
+	avgLen   float64
+}
+
+// newIndex builds an index over texts.
+func newIndex(texts []string) *index {
+	idx := index{
+		postings: map[string][]posting{},
+		lengths:  make([]int, len(texts)),
+	}
+
+	var total int
+	for doc, text := range texts {
+		tokens := analyze(text)
+		idx.lengths[doc] = len(tokens)
+		total += len(tokens)
+
+		positions := map[string][]int{}
+		var order []string
+		for _, t := range tokens {
+			if _, exists := positions[t.term]; !exists {
+				order = append(order, t.term)
+			}
+			positions[t.term] = append(positions[t.term], t.pos)
+		}
+		for _, term := range order {
+			idx.postings[term] = append(idx.postings[term], posting{doc: doc, positions: positions[term]})
+		}
+	}
+
+	if len(texts) > 0 {
+		idx.avgLen = float64(total) / float64(len(texts))
+	}
+	return &idx
+}
+
+// docs returns the set of documents containing term.
+func (idx *index) docs(term string) map[int]bool {
+	docs := map[int]bool{}
+	for _, p := range idx.postings[term] {
+		docs[p.doc] = true
+	}
+	return docs
+}
+
+// all returns the documents containing every term.
+func (idx *index) all(terms []string) map[int]bool {
+	if len(terms) == 0 {
+		return map[int]bool{}
+	}
+
+	docs := idx.docs(terms[0])
+	for _, term := range terms[1:] {
+		next := idx.docs(term)
+		for doc := range docs {
+			if !next[doc] {
+				delete(docs, doc)
+			}
+		}
+	}
+	return docs
+}
+
+// any returns the documents containing at least one term.
+func (idx *index) any(terms []string) map[int]bool {
+	docs := map[int]bool{}
+	for _, term := range terms {
+		for doc := range idx.docs(term) {
+			docs[doc] = true
+		}
+	}
+	return docs
+}
+
+// phrase returns the documents containing the tokens in order, keeping the
+// gaps left by stop words.
+func (idx *index) phrase(tokens []token) map[int]bool {
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.term
+	}
+
+	docs := idx.all(terms)
+	for doc := range docs {
+		if !idx.containsPhrase(doc, tokens) {
+			delete(docs, doc)
+		}
+	}
+	return docs
+}
+
+// containsPhrase reports whether doc holds every token at the same offset
+// from the first token as in the phrase.
+func (idx *index) containsPhrase(doc int, tokens []token) bool {
+	positions := make([]map[int]bool, len(tokens))
+	for i, t := range tokens {
+		positions[i] = map[int]bool{}
+		for _, pos := range idx.positions(t.term, doc) {
+			positions[i][pos] = true
+		}
+	}
+
+next:
+	for first := range positions[0] {
+		for i, t := range tokens[1:] {
+			if !positions[i+1][first+t.pos-tokens[0].pos] {
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// positions returns the positions of term within doc. Postings are built in
+// document order, so they can be binary searched.
+func (idx *index) positions(term string, doc int) []int {
+	postings := idx.postings[term]
+	i := sort.Search(len(postings), func(i int) bool { return postings[i].doc >= doc })
+	if i == len(postings) || postings[i].doc != doc {
+		return nil
+	}
+	return postings[i].positions
+}
+
+// score returns the BM25 score of doc for terms.
+func (idx *index) score(doc int, terms []string) float64 {
+	n := float64(len(idx.lengths))
+	norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.lengths[doc])/idx.avgLen)
+
+	var score float64
+	for _, term := range terms {
+		tf := float64(len(idx.positions(term, doc)))
+		if tf == 0 {
+			continue
+		}
+		df := float64(len(idx.postings[term]))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + norm)
+	}
+	return score
+}
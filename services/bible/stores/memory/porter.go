@@ -0,0 +1,216 @@
+package memory
+
+// stem reduces an English word to its stem using the Porter stemming
+// algorithm, the same algorithm Elasticsearch's english analyzer applies.
+// Words of two letters or fewer are returned unchanged.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	b := []byte(word)
+	b = step1a(b)
+	b = step1b(b)
+	b = step1c(b)
+	b = step2(b)
+	b = step3(b)
+	b = step4(b)
+	b = step5(b)
+	return string(b)
+}
+
+// isConsonant reports whether b[i] is a consonant. A y is a consonant unless
+// it follows one.
+func isConsonant(b []byte, i int) bool {
+	switch b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(b, i-1)
+	}
+	return true
+}
+
+// measure returns m for a word of the form [C](VC){m}[V].
+func measure(b []byte) int {
+	var m, i int
+	for i < len(b) && isConsonant(b, i) {
+		i++
+	}
+	for i < len(b) {
+		for i < len(b) && !isConsonant(b, i) {
+			i++
+		}
+		if i == len(b) {
+			break
+		}
+		for i < len(b) && isConsonant(b, i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+// hasVowel reports whether b contains a vowel.
+func hasVowel(b []byte) bool {
+	for i := range b {
+		if !isConsonant(b, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsDoubleConsonant reports whether b ends with a double consonant.
+func endsDoubleConsonant(b []byte) bool {
+	l := len(b)
+	return l >= 2 && b[l-1] == b[l-2] && isConsonant(b, l-1)
+}
+
+// endsCVC reports whether b ends consonant, vowel, consonant where the last
+// consonant is not w, x or y.
+func endsCVC(b []byte) bool {
+	l := len(b)
+	if l < 3 || !isConsonant(b, l-3) || isConsonant(b, l-2) || !isConsonant(b, l-1) {
+		return false
+	}
+	switch b[l-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func hasSuffix(b []byte, suffix string) bool {
+	return len(b) >= len(suffix) && string(b[len(b)-len(suffix):]) == suffix
+}
+
+// rule replaces a suffix of a word.
+type rule struct {
+	suffix      string
+	replacement string
+}
+
+// applyRules applies the first rule whose suffix matches b. Only that rule
+// is considered even when its measure condition fails.
+func applyRules(b []byte, rules []rule, minMeasure int) []byte {
+	for _, r := range rules {
+		if !hasSuffix(b, r.suffix) {
+			continue
+		}
+		stem := b[:len(b)-len(r.suffix)]
+		if measure(stem) > minMeasure {
+			return append(stem, r.replacement...)
+		}
+		return b
+	}
+	return b
+}
+
+func step1a(b []byte) []byte {
+	switch {
+	case hasSuffix(b, "sses"), hasSuffix(b, "ies"):
+		return b[:len(b)-2]
+	case hasSuffix(b, "ss"):
+		return b
+	case hasSuffix(b, "s"):
+		return b[:len(b)-1]
+	}
+	return b
+}
+
+func step1b(b []byte) []byte {
+	if hasSuffix(b, "eed") {
+		if measure(b[:len(b)-3]) > 0 {
+			return b[:len(b)-1]
+		}
+		return b
+	}
+
+	var stem []byte
+	switch {
+	case hasSuffix(b, "ed") && hasVowel(b[:len(b)-2]):
+		stem = b[:len(b)-2]
+	case hasSuffix(b, "ing") && hasVowel(b[:len(b)-3]):
+		stem = b[:len(b)-3]
+	default:
+		return b
+	}
+
+	switch {
+	case hasSuffix(stem, "at"), hasSuffix(stem, "bl"), hasSuffix(stem, "iz"):
+		return append(stem, 'e')
+	case endsDoubleConsonant(stem):
+		switch stem[len(stem)-1] {
+		case 'l', 's', 'z':
+			return stem
+		}
+		return stem[:len(stem)-1]
+	case measure(stem) == 1 && endsCVC(stem):
+		return append(stem, 'e')
+	}
+	return stem
+}
+
+func step1c(b []byte) []byte {
+	if hasSuffix(b, "y") && hasVowel(b[:len(b)-1]) {
+		b[len(b)-1] = 'i'
+	}
+	return b
+}
+
+var step2Rules = []rule{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+func step2(b []byte) []byte {
+	return applyRules(b, step2Rules, 0)
+}
+
+var step3Rules = []rule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func step3(b []byte) []byte {
+	return applyRules(b, step3Rules, 0)
+}
+
+var step4Rules = []rule{
+	{"ement", ""}, {"ment", ""}, {"ent", ""}, {"ance", ""}, {"ence", ""},
+	{"able", ""}, {"ible", ""}, {"ant", ""}, {"al", ""}, {"er", ""},
+	{"ic", ""}, {"ou", ""}, {"ism", ""}, {"ate", ""}, {"iti", ""},
+	{"ous", ""}, {"ive", ""}, {"ize", ""},
+}
+
+func step4(b []byte) []byte {
+	// The ion suffix is only removed after an s or t.
+	if hasSuffix(b, "ion") {
+		stem := b[:len(b)-3]
+		if len(stem) > 0 && (stem[len(stem)-1] == 's' || stem[len(stem)-1] == 't') && measure(stem) > 1 {
+			return stem
+		}
+		return b
+	}
+	return applyRules(b, step4Rules, 1)
+}
+
+func step5(b []byte) []byte {
+	if hasSuffix(b, "e") {
+		stem := b[:len(b)-1]
+		if m := measure(stem); m > 1 || (m == 1 && !endsCVC(stem)) {
+			b = stem
+		}
+	}
+
+	if measure(b) > 1 && endsDoubleConsonant(b) && b[len(b)-1] == 'l' {
+		b = b[:len(b)-1]
+	}
+	return b
+}
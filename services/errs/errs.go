@@ -0,0 +1,87 @@
+// Package errs defines the kinds of errors services report. Every kind has a
+// stable code callers can rely on instead of matching error messages, and
+// the HTTP status responses carrying it are served with.
+package errs
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code identifies the kind of an error.
+type Code string
+
+// Set of error codes.
+const (
+	NotFound          Code = "not_found"
+	Conflict          Code = "conflict"
+	InvalidArgument   Code = "invalid_argument"
+	Unauthenticated   Code = "unauthenticated"
+	PermissionDenied  Code = "permission_denied"
+	ResourceExhausted Code = "resource_exhausted"
+	Unavailable       Code = "unavailable"
+	Internal          Code = "internal"
+)
+
+// statuses maps every code to the HTTP status it is served with.
+var statuses = map[Code]int{
+	NotFound:          http.StatusNotFound,
+	Conflict:          http.StatusConflict,
+	InvalidArgument:   http.StatusBadRequest,
+	Unauthenticated:   http.StatusUnauthorized,
+	PermissionDenied:  http.StatusForbidden,
+	ResourceExhausted: http.StatusTooManyRequests,
+	Unavailable:       http.StatusServiceUnavailable,
+	Internal:          http.StatusInternalServerError,
+}
+
+// Error is an error of a known kind. Services declare their sentinel errors
+// with New, so wrapping them keeps their code.
+type Error struct {
+	Code Code
+	msg  string
+	err  error
+}
+
+// New returns an error of kind code.
+func New(code Code, msg string) error {
+	return &Error{Code: code, msg: msg}
+}
+
+// Wrap returns err as an error of kind code. It is for errors from outside
+// the services, such as those of database drivers.
+func Wrap(code Code, err error) error {
+	return &Error{Code: code, msg: err.Error(), err: err}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the error wrapped by Wrap.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// CodeOf returns the code of the first Error in the chain of err. Errors of
+// no known kind are Internal, and a nil error has no code.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Internal
+}
+
+// Status returns the HTTP status responses carrying code are served with.
+func Status(code Code) int {
+	if status, exists := statuses[code]; exists {
+		return status
+	}
+	return http.StatusOK
+}
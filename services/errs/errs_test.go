@@ -0,0 +1,119 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Errors(t *testing.T) {
+	errMissing := New(NotFound, "thing not found")
+
+	t.Log("Given the need to report errors by kind.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen finding the code of an error.", testID)
+		{
+			wrapped := fmt.Errorf("query: %w", errMissing)
+			if code := CodeOf(wrapped); code != NotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the code of a wrapped error : got %q.", failed, testID, code)
+			}
+			if !errors.Is(wrapped, errMissing) {
+				t.Fatalf("\t%s\tTest %d:\tShould still match the sentinel error.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the code of a wrapped error.", success, testID)
+
+			if code := CodeOf(errors.New("boom")); code != Internal {
+				t.Fatalf("\t%s\tTest %d:\tShould treat unknown errors as internal : got %q.", failed, testID, code)
+			}
+			if code := CodeOf(nil); code != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould give no code to a nil error : got %q.", failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould treat unknown errors as internal.", success, testID)
+
+			cause := errors.New("connection refused")
+			if err := Wrap(Unavailable, cause); CodeOf(err) != Unavailable || !errors.Is(err, cause) {
+				t.Fatalf("\t%s\tTest %d:\tShould wrap an error with a code : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wrap an error with a code.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen reporting invalid fields.", testID)
+		{
+			var fe FieldErrors
+			if err := fe.Err(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not report an error without fields : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not report an error without fields.", success, testID)
+
+			fe.Add("email", "is required")
+			fe.Addf("name", "must be at most %d bytes", 128)
+			errQuery := New(InvalidArgument, "invalid query")
+			f := From(fmt.Errorf("%w: %w", errQuery, fe))
+			if f.Code != InvalidArgument || len(f.Fields) != 2 || f.Fields[1] != (FieldError{Field: "name", Message: "must be at most 128 bytes"}) {
+				t.Fatalf("\t%s\tTest %d:\tShould list the fields in the fault : got %+v.", failed, testID, f)
+			}
+			if !errors.Is(fe, ErrInvalidFields) {
+				t.Fatalf("\t%s\tTest %d:\tShould match ErrInvalidFields.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the fields in the fault.", success, testID)
+
+			if f := From(errMissing); f.Fields != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list no fields for other errors : got %+v.", failed, testID, f)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list no fields for other errors.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen serving a response carrying a fault.", testID)
+		{
+			h := StatusMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(struct {
+					Fault
+					Name string `json:"name"`
+				}{Fault: From(fmt.Errorf("query: %w", errMissing))})
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/Service.Method", nil))
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould serve the status of the code : got %d.", failed, testID, w.Code)
+			}
+
+			var f Fault
+			if err := json.Unmarshal(w.Body.Bytes(), &f); err != nil || f.Code != NotFound || f.Error != "query: thing not found" {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the body : got %s.", failed, testID, w.Body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould serve the status of the code.", success, testID)
+
+			if status := Status(ResourceExhausted); status != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould serve exhausted resources as too many requests : got %d.", failed, testID, status)
+			}
+			t.Logf("\t%s\tTest %d:\tShould serve exhausted resources as too many requests.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen serving a response without a fault.", testID)
+		{
+			h := StatusMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"name":"ok"}`))
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/Service.Method", nil))
+			if w.Code != http.StatusOK || w.Body.String() != `{"name":"ok"}` {
+				t.Fatalf("\t%s\tTest %d:\tShould serve it unchanged : got %d %s.", failed, testID, w.Code, w.Body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould serve it unchanged.", success, testID)
+		}
+	}
+}
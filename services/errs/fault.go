@@ -0,0 +1,67 @@
+package errs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Fault is the error an RPC response carries. Responses embed it so every
+// service reports errors the same way. Fields lists what is wrong with the
+// request when the error is caused by invalid fields.
+type Fault struct {
+	Error  string      `json:"error,omitempty"`
+	Code   Code        `json:"code,omitempty"`
+	Fields FieldErrors `json:"fields,omitempty"`
+}
+
+// From returns the fault reporting err.
+func From(err error) Fault {
+	if err == nil {
+		return Fault{}
+	}
+
+	f := Fault{Error: err.Error(), Code: CodeOf(err)}
+	var fe FieldErrors
+	if errors.As(err, &fe) {
+		f.Fields = fe
+	}
+	return f
+}
+
+// StatusMiddleware serves responses carrying a fault with the HTTP status of
+// its code. The server writes every response it gets from a handler with
+// 200, so the body is held back until its code is known.
+func StatusMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(&rec, r)
+
+		status := rec.status
+		if status == http.StatusOK {
+			var f Fault
+			if err := json.Unmarshal(rec.body.Bytes(), &f); err == nil && f.Code != "" {
+				status = Status(f.Code)
+			}
+		}
+
+		w.WriteHeader(status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// recorder holds back the status and body written to a response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
@@ -0,0 +1,54 @@
+package errs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidFields is returned when fields of a request hold invalid
+// values. The fields and what is wrong with them are listed alongside it.
+var ErrInvalidFields = New(InvalidArgument, "invalid fields")
+
+// FieldError describes what is wrong with the value of a request field so
+// clients can show it next to the input it came from. Field is the JSON
+// name of the field in the object the client filled in; fields of nested
+// objects and lists are written as filter.email and roles[1].
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors lists every field error found in a request.
+type FieldErrors []FieldError
+
+// Add appends the error of field.
+func (fe *FieldErrors) Add(field string, message string) {
+	*fe = append(*fe, FieldError{Field: field, Message: message})
+}
+
+// Addf appends the error of field, formatting its message.
+func (fe *FieldErrors) Addf(field string, format string, args ...any) {
+	fe.Add(field, fmt.Sprintf(format, args...))
+}
+
+// Err returns the field errors as an error, or nil when there are none.
+func (fe FieldErrors) Err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return fe
+}
+
+// Error implements the error interface.
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, f := range fe {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return ErrInvalidFields.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// Unwrap lets errors.Is match ErrInvalidFields.
+func (fe FieldErrors) Unwrap() error {
+	return ErrInvalidFields
+}
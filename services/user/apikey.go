@@ -0,0 +1,339 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+	"go.uber.org/zap"
+)
+
+const (
+	// APIKeyHeader is the request header machine clients send their API key
+	// in.
+	APIKeyHeader = "X-API-Key"
+
+	apiKeyPrefix        = "kjv_"
+	apiKeyAudience      = "api-key"
+	apiKeyTokenTTL      = 5 * time.Minute
+	defaultAPIKeyTTL    = 90 * 24 * time.Hour
+	maxAPIKeyTTL        = 365 * 24 * time.Hour
+	maxAPIKeyNameLength = 64
+	maxAPIKeys          = 25
+)
+
+// Set of errors returned when handling API keys.
+var (
+	ErrInvalidAPIKey    = errs.New(errs.Unauthenticated, "invalid api key")
+	ErrAPIKeyNotFound   = errs.New(errs.NotFound, "api key not found")
+	ErrInvalidAPIKeyReq = errs.New(errs.InvalidArgument, "invalid api key request")
+)
+
+// CreateAPIKey implements UserRpcService. The key is returned only in this
+// response; just its hash is stored. Its scopes must be a subset of the
+// caller's roles and default to all of them.
+func (u UserServicer) CreateAPIKey(req CreateAPIKeyRequest, gr server.GenericRequest) CreateAPIKeyResponse {
+	if err := req.Validate(); err != nil {
+		return CreateAPIKeyResponse{Fault: errs.From(err)}
+	}
+
+	if isAPIKeyClaims(gr.Claims) {
+		return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("%w: api keys cannot create api keys", ErrForbidden))}
+	}
+
+	userID, err := uuid.Parse(gr.Claims.Subject)
+	if err != nil {
+		return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("parse user id: %w", err))}
+	}
+
+	name := strings.TrimSpace(req.Name)
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = gr.Claims.Roles
+	}
+	for _, scope := range scopes {
+		if !hasRole(gr.Claims, scope) {
+			return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("%w: scope %q is not one of your roles", ErrInvalidAPIKeyReq, scope))}
+		}
+	}
+
+	now := time.Now().UTC()
+	expires := now.Add(defaultAPIKeyTTL)
+	if req.ExpiresAt != nil {
+		expires = req.ExpiresAt.UTC()
+	}
+	if !expires.After(now) || expires.Sub(now) > maxAPIKeyTTL {
+		return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("%w: expiry must be within %s", ErrInvalidAPIKeyReq, maxAPIKeyTTL))}
+	}
+
+	keys, err := u.storer.QueryAPIKeys(gr.Ctx, userID)
+	if err != nil {
+		return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("queryapikeys: %w", err))}
+	}
+	var active int
+	for _, k := range keys {
+		if k.DateRevoked == nil && now.Before(k.DateExpires) {
+			active++
+		}
+	}
+	if active >= maxAPIKeys {
+		return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("%w: at most %d api keys are allowed", ErrInvalidAPIKeyReq, maxAPIKeys))}
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return CreateAPIKeyResponse{Fault: errs.From(err)}
+	}
+	key := apiKeyPrefix + secret
+
+	ak := APIKey{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Name:        name,
+		Prefix:      key[:len(apiKeyPrefix)+6],
+		Hash:        hashToken(key),
+		Scopes:      scopes,
+		DateCreated: now,
+		DateExpires: expires,
+	}
+	if err := u.storer.CreateAPIKey(gr.Ctx, ak); err != nil {
+		return CreateAPIKeyResponse{Fault: errs.From(fmt.Errorf("createapikey: %w", err))}
+	}
+	u.audit(gr, AuditCreateAPIKey, userID.String(), nil, ak)
+
+	return CreateAPIKeyResponse{Key: key, APIKey: ak}
+}
+
+// ListAPIKeys implements UserRpcService. It lists the API keys of the
+// caller.
+func (u UserServicer) ListAPIKeys(req ListAPIKeysRequest, gr server.GenericRequest) ListAPIKeysResponse {
+	userID, err := uuid.Parse(gr.Claims.Subject)
+	if err != nil {
+		return ListAPIKeysResponse{Fault: errs.From(fmt.Errorf("parse user id: %w", err))}
+	}
+
+	keys, err := u.storer.QueryAPIKeys(gr.Ctx, userID)
+	if err != nil {
+		return ListAPIKeysResponse{Fault: errs.From(fmt.Errorf("queryapikeys: %w", err))}
+	}
+
+	return ListAPIKeysResponse{APIKeys: keys}
+}
+
+// RevokeAPIKey implements UserRpcService. It revokes one of the caller's API
+// keys.
+func (u UserServicer) RevokeAPIKey(req RevokeAPIKeyRequest, gr server.GenericRequest) RevokeAPIKeyResponse {
+	userID, err := uuid.Parse(gr.Claims.Subject)
+	if err != nil {
+		return RevokeAPIKeyResponse{Fault: errs.From(fmt.Errorf("parse user id: %w", err))}
+	}
+
+	if err := req.Validate(); err != nil {
+		return RevokeAPIKeyResponse{Fault: errs.From(err)}
+	}
+
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		return RevokeAPIKeyResponse{Fault: errs.From(ErrAPIKeyNotFound)}
+	}
+
+	found, err := u.storer.RevokeAPIKey(gr.Ctx, userID, id, time.Now().UTC())
+	if err != nil {
+		return RevokeAPIKeyResponse{Fault: errs.From(fmt.Errorf("revokeapikey: %w", err))}
+	}
+	if !found {
+		return RevokeAPIKeyResponse{Fault: errs.From(ErrAPIKeyNotFound)}
+	}
+	u.audit(gr, AuditRevokeAPIKey, userID.String(), nil, map[string]string{"api_key_id": id.String()})
+
+	return RevokeAPIKeyResponse{}
+}
+
+// NewAPIKeyMiddleware returns middleware authenticating requests that carry
+// an API key in the APIKeyHeader. The key is exchanged for a short lived
+// token in the Authorization header carrying the roles the key is scoped
+// to, so the server authorizes the request as it would any other.
+func NewAPIKeyMiddleware(log *zap.SugaredLogger, storer Storer, a auth.Auth) func(http.Handler) http.Handler {
+	u := UserServicer{log: log, storer: storer, auth: a}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tkn, err := u.apiKeyToken(r, key)
+			if err != nil {
+				log.Infow("apikey: authenticate", "path", r.URL.Path, "ERROR", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(errs.From(ErrInvalidAPIKey))
+				return
+			}
+
+			r.Header.Del(APIKeyHeader)
+			r.Header.Set("Authorization", "Bearer "+tkn)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// apiKeyToken resolves key to its owner and returns a token for them with
+// the roles and permissions the key is scoped to that the owner still holds.
+func (u UserServicer) apiKeyToken(r *http.Request, key string) (string, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+
+	ak, err := u.storer.QueryAPIKey(r.Context(), hashToken(key))
+	if err != nil {
+		return "", fmt.Errorf("queryapikey: %w", err)
+	}
+	if ak.DateRevoked != nil || !now.Before(ak.DateExpires) {
+		return "", ErrInvalidAPIKey
+	}
+
+	usr, err := u.storer.QueryByID(r.Context(), ak.UserID.String())
+	if err != nil {
+		return "", fmt.Errorf("querybyid: %w", err)
+	}
+	if !usr.Enabled {
+		return "", ErrAccountDisabled
+	}
+
+	roles, err := u.scopedGrants(r.Context(), usr, ak.Scopes)
+	if err != nil {
+		return "", err
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ak.ID.String(),
+			Subject:   usr.ID.String(),
+			Issuer:    "kjvonly",
+			Audience:  jwt.ClaimStrings{apiKeyAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(apiKeyTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles: roles,
+	}
+
+	tkn, err := u.auth.GenerateToken(claims)
+	if err != nil {
+		return "", fmt.Errorf("generatetoken: %w", err)
+	}
+	return tkn, nil
+}
+
+// scopedGrants returns what the owner of a key grants limited to scopes. A
+// scope naming a role held by the owner grants its permissions, and a scope
+// naming a permission grants it if the roles of the owner do.
+func (u UserServicer) scopedGrants(ctx context.Context, usr User, scopes []string) ([]string, error) {
+	all, err := u.grants(ctx, usr.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []Role
+	var perms []string
+	for _, scope := range scopes {
+		if !contains(all, scope) {
+			continue
+		}
+
+		if _, err := ParseRole(scope); err == nil {
+			roles = append(roles, Role{scope})
+			continue
+		}
+		perms = append(perms, scope)
+	}
+
+	scoped, err := u.grants(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range perms {
+		if !contains(scoped, p) {
+			scoped = append(scoped, p)
+		}
+	}
+	return scoped, nil
+}
+
+// isAPIKeyClaims reports whether the claims were issued for an API key.
+func isAPIKeyClaims(claims auth.Claims) bool {
+	for _, aud := range claims.Audience {
+		if aud == apiKeyAudience {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKeyRequest is the request object for UserService.CreateAPIKey.
+// A nil ExpiresAt gives the key the default lifetime.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// Validate checks the fields of the request hold usable values. Whether
+// the scopes are held by the caller is checked by CreateAPIKey.
+func (r CreateAPIKeyRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "name", strings.TrimSpace(r.Name))
+	checkLength(&fe, "name", strings.TrimSpace(r.Name), maxAPIKeyNameLength)
+	for i, scope := range r.Scopes {
+		if scope == "" {
+			fe.Add(fmt.Sprintf("scopes[%d]", i), "is required")
+		}
+	}
+	return fe.Err()
+}
+
+// CreateAPIKeyResponse is the response object for UserService.CreateAPIKey.
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"apiKey"`
+	errs.Fault
+}
+
+// ListAPIKeysRequest is the request object for UserService.ListAPIKeys.
+type ListAPIKeysRequest struct{}
+
+// ListAPIKeysResponse is the response object for UserService.ListAPIKeys.
+type ListAPIKeysResponse struct {
+	APIKeys []APIKey `json:"apiKeys"`
+	errs.Fault
+}
+
+// RevokeAPIKeyRequest is the request object for UserService.RevokeAPIKey.
+type RevokeAPIKeyRequest struct {
+	ID string `json:"id"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r RevokeAPIKeyRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkID(&fe, "id", r.ID)
+	return fe.Err()
+}
+
+// RevokeAPIKeyResponse is the response object for UserService.RevokeAPIKey.
+type RevokeAPIKeyResponse struct {
+	errs.Fault
+}
@@ -0,0 +1,71 @@
+package user
+
+import (
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/kjvonly/service/services/audit"
+)
+
+// Set of actions recorded in the audit log.
+const (
+	AuditCreate             = "user.create"
+	AuditUpdate             = "user.update"
+	AuditDelete             = "user.delete"
+	AuditRestore            = "user.restore"
+	AuditPurge              = "user.purge"
+	AuditAuthenticate       = "user.authenticate"
+	AuditAuthenticateFailed = "user.authenticate_failed"
+	AuditUnlock             = "user.unlock"
+	AuditResetPassword      = "user.reset_password"
+	AuditChangePassword     = "user.change_password"
+	AuditVerifyEmail        = "user.verify_email"
+	AuditEnrollMFA          = "user.enroll_mfa"
+	AuditEnableMFA          = "user.enable_mfa"
+	AuditLogout             = "user.logout"
+	AuditRevokeSessions     = "user.revoke_sessions"
+	AuditCreateAPIKey       = "user.create_api_key"
+	AuditRevokeAPIKey       = "user.revoke_api_key"
+	AuditCreateRole         = "role.create"
+	AuditUpdateRole         = "role.update"
+	AuditDeleteRole         = "role.delete"
+)
+
+// redactedFields are never written to the audit log.
+var redactedFields = []string{"password_hash"}
+
+// audit records action by the caller on target, a user or a role, along
+// with the fields that changed from before to after. Calls to public endpoints are
+// recorded without an actor. The action has already happened, so a failure
+// to record it is logged rather than returned.
+func (u UserServicer) audit(gr server.GenericRequest, action string, target string, before any, after any) {
+	changes, err := audit.Diff(auditable(before), auditable(after), redactedFields...)
+	if err != nil {
+		u.log.Errorw("audit: diff", "trace_id", gr.Values.TraceID, "action", action, "ERROR", err)
+	}
+
+	e := audit.Entry{
+		Actor:     gr.Claims.Subject,
+		Action:    action,
+		Target:    target,
+		Changes:   changes,
+		RequestID: gr.Values.TraceID,
+	}
+	if err := u.cfg.Audit.Record(gr.Ctx, e); err != nil {
+		u.log.Errorw("audit: record", "trace_id", gr.Values.TraceID, "action", action, "ERROR", err)
+	}
+}
+
+// auditUser is a user as the audit log diffs it. The password hash is never
+// marshalled with a user, so it is added back for a change of password to
+// be recorded, redacted.
+type auditUser struct {
+	User
+	PasswordHash []byte `json:"password_hash"`
+}
+
+// auditable returns v as the audit log diffs it.
+func auditable(v any) any {
+	if usr, ok := v.(User); ok {
+		return auditUser{User: usr, PasswordHash: usr.PasswordHash}
+	}
+	return v
+}
@@ -0,0 +1,77 @@
+// Package breach checks passwords against a local copy of a breached
+// password list. The list is split into files by the first five characters
+// of the SHA-1 of each password, the layout used for k-anonymity lookups,
+// so a check only reads the one file its hash prefix names.
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const prefixLength = 5
+
+// Checker reports whether a password is known to have been breached.
+type Checker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// Dir checks passwords against a directory holding a file per hash prefix.
+// Each file is named by its upper case prefix and holds lines of the
+// remaining hash characters and the times the password was seen, separated
+// by a colon.
+type Dir struct {
+	path string
+}
+
+// NewDir constructs a Checker reading the breached password list in path.
+func NewDir(path string) *Dir {
+	return &Dir{path: path}
+}
+
+// Breached implements Checker. A prefix without a file has no breached
+// passwords.
+func (d *Dir) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	f, err := os.Open(filepath.Join(d.path, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("open prefix file: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		hashSuffix, count, _ := strings.Cut(strings.TrimSpace(s.Text()), ":")
+		if !strings.EqualFold(hashSuffix, suffix) {
+			continue
+		}
+
+		// Padding entries are listed with a count of zero.
+		n, err := strconv.Atoi(count)
+		return err != nil || n > 0, nil
+	}
+	if err := s.Err(); err != nil {
+		return false, fmt.Errorf("read prefix file: %w", err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,49 @@
+package breach
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Breached(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	dir := t.TempDir()
+	lines := "0018A45C4D1DEF81644B54AB7F969B88D65:0\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6"), []byte(lines), 0o644); err != nil {
+		t.Fatalf("writing prefix file: %s", err)
+	}
+	d := NewDir(dir)
+	ctx := context.Background()
+
+	t.Log("Given the need to refuse breached passwords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen checking a listed password.", testID)
+		{
+			breached, err := d.Breached(ctx, "password")
+			if err != nil || !breached {
+				t.Fatalf("\t%s\tTest %d:\tShould report it breached : got %t, %v.", failed, testID, breached, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report it breached.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen checking passwords that are not listed.", testID)
+		{
+			for _, pw := range []string{"Password", "correct horse battery staple"} {
+				breached, err := d.Breached(ctx, pw)
+				if err != nil || breached {
+					t.Fatalf("\t%s\tTest %d:\tShould not report %q breached : got %t, %v.", failed, testID, pw, breached, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould not report them breached.", success, testID)
+		}
+	}
+}
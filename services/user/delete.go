@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/kjvonly/service/services/errs"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDeletedRetention = 30 * 24 * time.Hour
+	defaultPurgeInterval    = time.Hour
+)
+
+// ErrUserNotDeleted is returned when restoring or purging a user that has
+// not been deleted.
+var ErrUserNotDeleted = errs.New(errs.Conflict, "user is not deleted")
+
+// RestoreUser implements UserRpcService. It undoes DeleteUser for a user
+// that has not been purged yet.
+func (u UserServicer) RestoreUser(req RestoreUserRequest, gr server.GenericRequest) RestoreUserResponse {
+	if err := req.Validate(); err != nil {
+		return RestoreUserResponse{Fault: errs.From(err)}
+	}
+
+	if req.Version == "" {
+		return RestoreUserResponse{Fault: errs.From(ErrVersionRequired)}
+	}
+
+	usr, err := u.storer.Restore(gr.Ctx, req.ID, req.Version)
+	if err != nil {
+		return RestoreUserResponse{Fault: errs.From(fmt.Errorf("restore: %w", err))}
+	}
+	u.audit(gr, AuditRestore, usr.ID.String(), nil, nil)
+
+	return RestoreUserResponse{User: usr}
+}
+
+// PurgeUser implements UserRpcService. It permanently removes a deleted
+// user ahead of the retention window.
+func (u UserServicer) PurgeUser(req PurgeUserRequest, gr server.GenericRequest) PurgeUserResponse {
+	if err := req.Validate(); err != nil {
+		return PurgeUserResponse{Fault: errs.From(err)}
+	}
+
+	if req.Version == "" {
+		return PurgeUserResponse{Fault: errs.From(ErrVersionRequired)}
+	}
+
+	usr, err := u.storer.Purge(gr.Ctx, req.ID, req.Version)
+	if err != nil {
+		return PurgeUserResponse{Fault: errs.From(fmt.Errorf("purge: %w", err))}
+	}
+	u.audit(gr, AuditPurge, usr.ID.String(), usr, nil)
+
+	return PurgeUserResponse{}
+}
+
+// PurgeDeleted permanently removes the users deleted longer than retention
+// ago every interval, until ctx is done. Deleted users can be restored until
+// then. A retention or interval that is not positive takes its default.
+func PurgeDeleted(ctx context.Context, log *zap.SugaredLogger, storer Storer, retention time.Duration, interval time.Duration) {
+	if retention <= 0 {
+		retention = defaultDeletedRetention
+	}
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := storer.PurgeDeleted(ctx, time.Now().UTC().Add(-retention))
+		switch {
+		case err != nil:
+			log.Errorw("purgedeleted", "ERROR", err)
+		case n > 0:
+			log.Infow("purgedeleted", "users", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RestoreUserRequest is the request object for UserService.RestoreUser.
+type RestoreUserRequest struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r RestoreUserRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkID(&fe, "id", r.ID)
+	return fe.Err()
+}
+
+// RestoreUserResponse is the response object for UserService.RestoreUser.
+type RestoreUserResponse struct {
+	User User `json:"user"`
+	errs.Fault
+}
+
+// PurgeUserRequest is the request object for UserService.PurgeUser.
+type PurgeUserRequest struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r PurgeUserRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkID(&fe, "id", r.ID)
+	return fe.Err()
+}
+
+// PurgeUserResponse is the response object for UserService.PurgeUser.
+type PurgeUserResponse struct {
+	errs.Fault
+}
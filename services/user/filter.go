@@ -0,0 +1,48 @@
+package user
+
+import (
+	"time"
+
+	"github.com/kjvonly/service/services/errs"
+)
+
+// ErrInvalidQuery is returned when a user query is malformed.
+var ErrInvalidQuery = errs.New(errs.InvalidArgument, "invalid query")
+
+// QueryFilter holds the available fields a query can be filtered on. Nil
+// fields are not filtered on, except for Deleted: deleted users are only
+// returned when it is true, and then only they are.
+type QueryFilter struct {
+	Name             *string    `json:"name"`
+	Email            *string    `json:"email"`
+	Role             *string    `json:"role"`
+	Department       *string    `json:"department"`
+	Enabled          *bool      `json:"enabled"`
+	StartCreatedDate *time.Time `json:"startCreatedDate"`
+	EndCreatedDate   *time.Time `json:"endCreatedDate"`
+	Deleted          *bool      `json:"deleted"`
+}
+
+// check adds the errors of the fields of the filter that do not hold
+// usable values.
+func (qf QueryFilter) check(fe *errs.FieldErrors) {
+	if qf.Name != nil {
+		checkLength(fe, "filter.name", *qf.Name, maxNameLength)
+	}
+
+	if qf.Email != nil {
+		checkEmail(fe, "filter.email", *qf.Email)
+	}
+
+	if qf.Role != nil {
+		checkRoleName(fe, "filter.role", *qf.Role)
+	}
+
+	if qf.Department != nil {
+		checkLength(fe, "filter.department", *qf.Department, maxDepartmentLength)
+	}
+
+	if qf.StartCreatedDate != nil && qf.EndCreatedDate != nil && qf.EndCreatedDate.Before(*qf.StartCreatedDate) {
+		fe.Add("filter.endCreatedDate", "must not be before startCreatedDate")
+	}
+}
@@ -21,6 +21,58 @@ func (h UserServicer) AuthenticateHandler(r server.GenericRequest, b []byte) (an
 
 	return h.Authenticate(hr, r), nil
 } 
+// ChangePasswordHandler validates input data prior to calling ChangePassword
+func (h UserServicer) ChangePasswordHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr ChangePasswordRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.ChangePassword(hr, r), nil
+} 
+// ConfirmMFAHandler validates input data prior to calling ConfirmMFA
+func (h UserServicer) ConfirmMFAHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr ConfirmMFARequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.ConfirmMFA(hr, r), nil
+} 
+// CreateAPIKeyHandler validates input data prior to calling CreateAPIKey
+func (h UserServicer) CreateAPIKeyHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr CreateAPIKeyRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.CreateAPIKey(hr, r), nil
+} 
+// CreateRoleHandler validates input data prior to calling CreateRole
+func (h UserServicer) CreateRoleHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr CreateRoleRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.CreateRole(hr, r), nil
+} 
 // CreateUserHandler validates input data prior to calling CreateUser
 func (h UserServicer) CreateUserHandler(r server.GenericRequest, b []byte) (any, error) {
 	var hr CreateUserRequest
@@ -34,6 +86,19 @@ func (h UserServicer) CreateUserHandler(r server.GenericRequest, b []byte) (any,
 
 	return h.CreateUser(hr, r), nil
 } 
+// DeleteRoleHandler validates input data prior to calling DeleteRole
+func (h UserServicer) DeleteRoleHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr DeleteRoleRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.DeleteRole(hr, r), nil
+} 
 // DeleteUserHandler validates input data prior to calling DeleteUser
 func (h UserServicer) DeleteUserHandler(r server.GenericRequest, b []byte) (any, error) {
 	var hr DeleteUserRequest
@@ -47,6 +112,84 @@ func (h UserServicer) DeleteUserHandler(r server.GenericRequest, b []byte) (any,
 
 	return h.DeleteUser(hr, r), nil
 } 
+// EnrollMFAHandler validates input data prior to calling EnrollMFA
+func (h UserServicer) EnrollMFAHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr EnrollMFARequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.EnrollMFA(hr, r), nil
+} 
+// GetMeHandler validates input data prior to calling GetMe
+func (h UserServicer) GetMeHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr GetMeRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.GetMe(hr, r), nil
+} 
+// ListAPIKeysHandler validates input data prior to calling ListAPIKeys
+func (h UserServicer) ListAPIKeysHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr ListAPIKeysRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.ListAPIKeys(hr, r), nil
+} 
+// LogoutHandler validates input data prior to calling Logout
+func (h UserServicer) LogoutHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr LogoutRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.Logout(hr, r), nil
+} 
+// PurgeUserHandler validates input data prior to calling PurgeUser
+func (h UserServicer) PurgeUserHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr PurgeUserRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.PurgeUser(hr, r), nil
+} 
+// QueryRolesHandler validates input data prior to calling QueryRoles
+func (h UserServicer) QueryRolesHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr QueryRolesRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.QueryRoles(hr, r), nil
+} 
 // QueryUserHandler validates input data prior to calling QueryUser
 func (h UserServicer) QueryUserHandler(r server.GenericRequest, b []byte) (any, error) {
 	var hr QueryUserRequest
@@ -86,6 +229,136 @@ func (h UserServicer) QueryUserByIDHandler(r server.GenericRequest, b []byte) (a
 
 	return h.QueryUserByID(hr, r), nil
 } 
+// RefreshTokenHandler validates input data prior to calling RefreshToken
+func (h UserServicer) RefreshTokenHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr RefreshTokenRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.RefreshToken(hr, r), nil
+} 
+// RequestPasswordResetHandler validates input data prior to calling RequestPasswordReset
+func (h UserServicer) RequestPasswordResetHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr RequestPasswordResetRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.RequestPasswordReset(hr, r), nil
+} 
+// ResendVerificationHandler validates input data prior to calling ResendVerification
+func (h UserServicer) ResendVerificationHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr ResendVerificationRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.ResendVerification(hr, r), nil
+} 
+// ResetPasswordHandler validates input data prior to calling ResetPassword
+func (h UserServicer) ResetPasswordHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr ResetPasswordRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.ResetPassword(hr, r), nil
+} 
+// RestoreUserHandler validates input data prior to calling RestoreUser
+func (h UserServicer) RestoreUserHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr RestoreUserRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.RestoreUser(hr, r), nil
+} 
+// RevokeAPIKeyHandler validates input data prior to calling RevokeAPIKey
+func (h UserServicer) RevokeAPIKeyHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr RevokeAPIKeyRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.RevokeAPIKey(hr, r), nil
+} 
+// RevokeAllSessionsHandler validates input data prior to calling RevokeAllSessions
+func (h UserServicer) RevokeAllSessionsHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr RevokeAllSessionsRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.RevokeAllSessions(hr, r), nil
+} 
+// UnlockUserHandler validates input data prior to calling UnlockUser
+func (h UserServicer) UnlockUserHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr UnlockUserRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.UnlockUser(hr, r), nil
+} 
+// UpdateMeHandler validates input data prior to calling UpdateMe
+func (h UserServicer) UpdateMeHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr UpdateMeRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.UpdateMe(hr, r), nil
+} 
+// UpdateRoleHandler validates input data prior to calling UpdateRole
+func (h UserServicer) UpdateRoleHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr UpdateRoleRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.UpdateRole(hr, r), nil
+} 
 // UpdateUserHandler validates input data prior to calling UpdateUser
 func (h UserServicer) UpdateUserHandler(r server.GenericRequest, b []byte) (any, error) {
 	var hr UpdateUserRequest
@@ -98,4 +371,30 @@ func (h UserServicer) UpdateUserHandler(r server.GenericRequest, b []byte) (any,
 	}
 
 	return h.UpdateUser(hr, r), nil
+} 
+// VerifyEmailHandler validates input data prior to calling VerifyEmail
+func (h UserServicer) VerifyEmailHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr VerifyEmailRequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.VerifyEmail(hr, r), nil
+} 
+// VerifyMFAHandler validates input data prior to calling VerifyMFA
+func (h UserServicer) VerifyMFAHandler(r server.GenericRequest, b []byte) (any, error) {
+	var hr VerifyMFARequest
+	if err := json.Unmarshal(b, &hr); err != nil {
+		return nil, fmt.Errorf("Unmarshalling data: %w", err)
+	}
+
+	if err := validate.Check(hr); err != nil {
+		return nil, fmt.Errorf("validating data: %w", err)
+	}
+
+	return h.VerifyMFA(hr, r), nil
 }
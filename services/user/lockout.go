@@ -0,0 +1,58 @@
+package user
+
+import (
+	"time"
+
+	"github.com/kjvonly/service/services/errs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Set of errors returned when a user cannot sign in.
+var (
+	ErrAuthenticationFailure = errs.New(errs.Unauthenticated, "authentication failed")
+	ErrAccountDisabled       = errs.New(errs.PermissionDenied, "account disabled")
+	ErrAccountLocked         = errs.New(errs.PermissionDenied, "account locked")
+)
+
+// dummyHash is compared against when no user has the email given to
+// Authenticate, so the response takes as long as for a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// Failed logins beyond maxFailedLogins lock the account for lockoutBase,
+// doubling with every further failure up to maxLockout.
+const (
+	maxFailedLogins = 5
+	lockoutBase     = time.Minute
+	maxLockout      = 24 * time.Hour
+)
+
+// Locked reports whether the account is locked out at now.
+func (u User) Locked(now time.Time) bool {
+	return now.Before(u.LockedUntil)
+}
+
+// Lockout returns the time an account is locked until once failedLogins
+// logins have failed in a row at now, or the zero time when it stays
+// unlocked. Stores call it as they count a failed login, so concurrent
+// failures all count towards the lockout.
+func Lockout(failedLogins int, now time.Time) time.Time {
+	if failedLogins < maxFailedLogins {
+		return time.Time{}
+	}
+
+	window := lockoutBase
+	for i := maxFailedLogins; i < failedLogins && window < maxLockout; i++ {
+		window *= 2
+	}
+	if window > maxLockout {
+		window = maxLockout
+	}
+
+	return now.Add(window)
+}
+
+// unlock clears the failed logins and any lockout of the account.
+func (u *User) unlock() {
+	u.FailedLogins = 0
+	u.LockedUntil = time.Time{}
+}
@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// File keeps mail instead of delivering it. Every message is written to its
+// own .eml file in a directory. The body is never logged since the links
+// it carries grant access to accounts.
+type File struct {
+	log  *zap.SugaredLogger
+	dir  string
+	from mail.Address
+}
+
+// NewFile constructs a mailer keeping messages in dir. An empty dir drops
+// them, logging only their recipient and subject.
+func NewFile(log *zap.SugaredLogger, dir string, from mail.Address) *File {
+	return &File{
+		log:  log,
+		dir:  dir,
+		from: from,
+	}
+}
+
+// Send keeps msg.
+func (f *File) Send(ctx context.Context, msg Message) error {
+	if f.dir == "" {
+		f.log.Infow("mailer: dropped", "to", msg.To.String(), "subject", msg.Subject)
+		return nil
+	}
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return fmt.Errorf("create mail dir: %w", err)
+	}
+
+	file, err := os.CreateTemp(f.dir, "*.eml")
+	if err != nil {
+		return fmt.Errorf("create mail file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(format(f.from, msg, time.Now())); err != nil {
+		return fmt.Errorf("write mail file: %w", err)
+	}
+
+	f.log.Infow("mailer: send", "to", msg.To.String(), "subject", msg.Subject, "file", file.Name())
+	return nil
+}
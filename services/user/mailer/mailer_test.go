@@ -0,0 +1,209 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+var (
+	from = mail.Address{Name: "KJV Only", Address: "no-reply@example.com"}
+	msg  = Message{
+		To:      mail.Address{Address: "john@example.com"},
+		Subject: "Réinitialiser",
+		Body:    "Reset your password:\nhttp://localhost/reset-password?token=secret\n",
+	}
+)
+
+func Test_Format(t *testing.T) {
+	now := time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to render messages.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen formatting a message.", testID)
+		{
+			got := string(format(from, msg, now))
+			want := "From: \"KJV Only\" <no-reply@example.com>\r\n" +
+				"To: <john@example.com>\r\n" +
+				"Subject: =?utf-8?q?R=C3=A9initialiser?=\r\n" +
+				"Date: Fri, 01 Mar 2019 12:00:00 +0000\r\n" +
+				"MIME-Version: 1.0\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\n" +
+				"\r\n" +
+				"Reset your password:\r\nhttp://localhost/reset-password?token=secret\r\n"
+			if got != want {
+				t.Fatalf("\t%s\tTest %d:\tShould render headers and CRLF body : got %q.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould render headers and CRLF body.", success, testID)
+		}
+	}
+}
+
+func Test_File(t *testing.T) {
+	ctx := context.Background()
+
+	t.Log("Given the need to keep mail during development.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen keeping mail in a directory.", testID)
+		{
+			core, logs := observer.New(zap.InfoLevel)
+			dir := filepath.Join(t.TempDir(), "mail")
+			f := NewFile(zap.New(core).Sugar(), dir, from)
+
+			if err := f.Send(ctx, msg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the message : %v.", failed, testID, err)
+			}
+			files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould write one file : got %v, %v.", failed, testID, files, err)
+			}
+			b, err := os.ReadFile(files[0])
+			if err != nil || !strings.Contains(string(b), "token=secret") {
+				t.Fatalf("\t%s\tTest %d:\tShould write the message to the file : got %q, %v.", failed, testID, b, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write the message to the file.", success, testID)
+
+			checkBodyNotLogged(t, testID, logs)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen no directory is configured.", testID)
+		{
+			core, logs := observer.New(zap.InfoLevel)
+			f := NewFile(zap.New(core).Sugar(), "", from)
+
+			if err := f.Send(ctx, msg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould drop the message : %v.", failed, testID, err)
+			}
+			if logs.Len() != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould log the dropped message : got %d entries.", failed, testID, logs.Len())
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the dropped message.", success, testID)
+
+			checkBodyNotLogged(t, testID, logs)
+		}
+	}
+}
+
+func Test_SMTP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan string, 1)
+	go serveSMTP(l, received)
+
+	addr := l.Addr().(*net.TCPAddr)
+	s := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: from})
+
+	t.Log("Given the need to deliver mail through an SMTP server.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sending a message.", testID)
+		{
+			if err := s.Send(context.Background(), msg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver the message : %v.", failed, testID, err)
+			}
+
+			select {
+			case data := <-received:
+				if !strings.Contains(data, "To: <john@example.com>") || !strings.Contains(data, "token=secret") {
+					t.Fatalf("\t%s\tTest %d:\tShould deliver the rendered message : got %q.", failed, testID, data)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould deliver the message to the server.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver the message.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the context is cancelled.", testID)
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := s.Send(ctx, msg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not deliver the message.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not deliver the message.", success, testID)
+		}
+	}
+}
+
+// checkBodyNotLogged fails the test when an entry in logs carries the body
+// of msg or the token in it.
+func checkBodyNotLogged(t *testing.T, testID int, logs *observer.ObservedLogs) {
+	t.Helper()
+
+	for _, e := range logs.All() {
+		for k, v := range e.ContextMap() {
+			if s, ok := v.(string); k == "body" || ok && strings.Contains(s, "token=secret") {
+				t.Fatalf("\t%s\tTest %d:\tShould not log the body : got %s=%v.", failed, testID, k, v)
+			}
+		}
+	}
+	t.Logf("\t%s\tTest %d:\tShould not log the body.", success, testID)
+}
+
+// serveSMTP accepts a single connection on l and speaks just enough SMTP
+// to take one message, which it sends on received.
+func serveSMTP(l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			received <- data.String()
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig holds the settings needed to deliver mail through an SMTP
+// server.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     mail.Address
+}
+
+// SMTP delivers mail through an SMTP server.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP constructs a mailer delivering through the server in cfg.
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	return &SMTP{cfg: cfg}
+}
+
+// Send delivers msg. The server is authenticated with only when a username
+// is configured. Since net/smtp takes no context, a cancelled context only
+// stops delivery before it starts.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var a smtp.Auth
+	if s.cfg.Username != "" {
+		a = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	body := format(s.cfg.From, msg, time.Now())
+	if err := smtp.SendMail(addr, a, s.cfg.From.Address, []string{msg.To.Address}, body); err != nil {
+		return fmt.Errorf("sendmail: %w", err)
+	}
+
+	return nil
+}
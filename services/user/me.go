@@ -0,0 +1,221 @@
+package user
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxPreferences           = 50
+	maxPreferenceKeyLength   = 64
+	maxPreferenceValueLength = 1024
+)
+
+// GetMe implements UserRpcService. It returns the user the caller's token
+// was issued to.
+func (u UserServicer) GetMe(req GetMeRequest, gr server.GenericRequest) GetMeResponse {
+	usr, err := u.storer.QueryByID(gr.Ctx, gr.Claims.Subject)
+	if err != nil {
+		return GetMeResponse{Fault: errs.From(fmt.Errorf("query: id[%s]: %w", gr.Claims.Subject, err))}
+	}
+	return GetMeResponse{User: usr}
+}
+
+// UpdateMe implements UserRpcService. Users can only change their own
+// profile; roles, email and whether they are enabled are left to admins.
+// API keys cannot change it.
+func (u UserServicer) UpdateMe(req UpdateMeRequest, gr server.GenericRequest) UpdateMeResponse {
+	if err := req.Validate(); err != nil {
+		return UpdateMeResponse{Fault: errs.From(err)}
+	}
+
+	if isAPIKeyClaims(gr.Claims) {
+		return UpdateMeResponse{Fault: errs.From(ErrForbidden)}
+	}
+	um := req.UpdateMe
+
+	usr, err := u.storer.QueryByID(gr.Ctx, gr.Claims.Subject)
+	if err != nil {
+		return UpdateMeResponse{Fault: errs.From(fmt.Errorf("query: id[%s]: %w", gr.Claims.Subject, err))}
+	}
+	if err := usr.checkVersion(req.Version); err != nil {
+		return UpdateMeResponse{Fault: errs.From(err)}
+	}
+	before := usr
+
+	if um.Name != nil {
+		usr.Name = *um.Name
+	}
+	if um.Department != nil {
+		usr.Department = *um.Department
+	}
+	if um.Preferences != nil {
+		usr.Preferences = um.Preferences
+	}
+	usr.DateUpdated = gr.Values.Now
+
+	result, err := u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return UpdateMeResponse{Fault: errs.From(err)}
+	}
+	u.audit(gr, AuditUpdate, result.ID.String(), before, result)
+
+	return UpdateMeResponse{User: result}
+}
+
+// ChangePassword implements UserRpcService. The current password has to be
+// given, and a wrong one counts towards lockout as a failed login would.
+// Every session of the user other than the caller's is revoked.
+func (u UserServicer) ChangePassword(req ChangePasswordRequest, gr server.GenericRequest) ChangePasswordResponse {
+	if err := req.Validate(); err != nil {
+		return ChangePasswordResponse{Fault: errs.From(err)}
+	}
+
+	if isAPIKeyClaims(gr.Claims) {
+		return ChangePasswordResponse{Fault: errs.From(ErrForbidden)}
+	}
+
+	sessionID, err := uuid.Parse(gr.Claims.ID)
+	if err != nil {
+		return ChangePasswordResponse{Fault: errs.From(ErrSessionRevoked)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, gr.Claims.Subject)
+	if err != nil {
+		return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("query: id[%s]: %w", gr.Claims.Subject, err))}
+	}
+
+	now := time.Now().UTC()
+
+	if usr.Locked(now) {
+		return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("%w until %s", ErrAccountLocked, usr.LockedUntil.Format(time.RFC3339)))}
+	}
+
+	before := usr
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(req.CurrentPassword)); err != nil {
+		after, err := u.storer.RecordFailedLogin(gr.Ctx, usr.ID.String(), now)
+		if err != nil {
+			return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("recordfailedlogin: %w", err))}
+		}
+		u.audit(gr, AuditAuthenticateFailed, usr.ID.String(), before, after)
+		return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("comparehashandpassword: %w", ErrAuthenticationFailure))}
+	}
+
+	fe, err := u.checkPassword(gr.Ctx, req.Password, req.PasswordConfirm, usr.Name, usr.Email.Address)
+	if err != nil {
+		return ChangePasswordResponse{Fault: errs.From(err)}
+	}
+	if len(fe) > 0 {
+		return ChangePasswordResponse{Fault: errs.From(fe)}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("generatefrompassword: %w", err))}
+	}
+	usr.PasswordHash = hash
+	usr.unlock()
+	usr.DateUpdated = now
+
+	result, err := u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+	}
+	u.audit(gr, AuditChangePassword, result.ID.String(), before, result)
+
+	if err := u.storer.RevokeOtherSessions(gr.Ctx, result.ID, sessionID, now); err != nil {
+		return ChangePasswordResponse{Fault: errs.From(fmt.Errorf("revokeothersessions: %w", err))}
+	}
+
+	return ChangePasswordResponse{}
+}
+
+// checkPreferences adds the errors of preferences beyond the limits a user
+// can store. Each preference is its own field.
+func checkPreferences(fe *errs.FieldErrors, prefs map[string]string) {
+	if len(prefs) > maxPreferences {
+		fe.Addf("preferences", "at most %d can be set", maxPreferences)
+	}
+
+	names := make([]string, 0, len(prefs))
+	for k := range prefs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		switch {
+		case k == "" || len(k) > maxPreferenceKeyLength:
+			fe.Addf("preferences", "names must be between 1 and %d bytes", maxPreferenceKeyLength)
+		case len(prefs[k]) > maxPreferenceValueLength:
+			fe.Addf("preferences."+k, "must be at most %d bytes", maxPreferenceValueLength)
+		}
+	}
+}
+
+// GetMeRequest is the request object for UserService.GetMe.
+type GetMeRequest struct{}
+
+// GetMeResponse is the response object for UserService.GetMe.
+type GetMeResponse struct {
+	User User `json:"user"`
+	errs.Fault
+}
+
+// UpdateMeRequest is the request object for UserService.UpdateMe. Version
+// is the version of the user the changes were made against.
+type UpdateMeRequest struct {
+	Version  string   `json:"version"`
+	UpdateMe UpdateMe `json:"user"`
+}
+
+// Validate checks the fields of the request hold usable values. Only the
+// fields being changed are checked.
+func (r UpdateMeRequest) Validate() error {
+	um := r.UpdateMe
+
+	var fe errs.FieldErrors
+	if um.Name != nil {
+		checkRequired(&fe, "name", strings.TrimSpace(*um.Name))
+		checkLength(&fe, "name", *um.Name, maxNameLength)
+	}
+	if um.Department != nil {
+		checkLength(&fe, "department", *um.Department, maxDepartmentLength)
+	}
+	checkPreferences(&fe, um.Preferences)
+	return fe.Err()
+}
+
+// UpdateMeResponse is the response object for UserService.UpdateMe.
+type UpdateMeResponse struct {
+	User User `json:"user"`
+	errs.Fault
+}
+
+// ChangePasswordRequest is the request object for UserService.ChangePassword.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+	PasswordConfirm string `json:"password_confirm"`
+}
+
+// Validate checks the fields of the request hold usable values. The new
+// password is checked against the password policy by ChangePassword.
+func (r ChangePasswordRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "current_password", r.CurrentPassword)
+	return fe.Err()
+}
+
+// ChangePasswordResponse is the response object for
+// UserService.ChangePassword.
+type ChangePasswordResponse struct {
+	errs.Fault
+}
@@ -0,0 +1,306 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+	"github.com/kjvonly/service/services/user/totp"
+)
+
+const (
+	mfaPurpose        = "mfa"
+	mfaChallengeTTL   = 5 * time.Minute
+	mfaIssuer         = "kjvonly"
+	mfaSkew           = 1
+	recoveryCodeCount = 10
+	maxMFACodeLength  = 32
+)
+
+// Set of errors returned when handling two-factor authentication.
+var (
+	ErrInvalidMFACode    = errs.New(errs.Unauthenticated, "invalid two-factor code")
+	ErrMFAAlreadyEnabled = errs.New(errs.Conflict, "two-factor authentication already enabled")
+	ErrMFANotEnrolled    = errs.New(errs.Conflict, "two-factor enrollment not started")
+)
+
+// EnrollMFA implements UserRpcService. It starts enrollment of the caller by
+// generating a secret for their authenticator app. Two-factor
+// authentication is only enabled once ConfirmMFA proves the app has it.
+// API keys cannot enroll.
+func (u UserServicer) EnrollMFA(req EnrollMFARequest, gr server.GenericRequest) EnrollMFAResponse {
+	if isAPIKeyClaims(gr.Claims) {
+		return EnrollMFAResponse{Fault: errs.From(ErrForbidden)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, gr.Claims.Subject)
+	if err != nil {
+		return EnrollMFAResponse{Fault: errs.From(fmt.Errorf("querybyid: %w", err))}
+	}
+	if usr.MFAEnabled {
+		return EnrollMFAResponse{Fault: errs.From(ErrMFAAlreadyEnabled)}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return EnrollMFAResponse{Fault: errs.From(err)}
+	}
+
+	usr.MFAPendingSecret = secret
+	usr.DateUpdated = gr.Values.Now
+	if _, err := u.storer.Update(gr.Ctx, usr); err != nil {
+		return EnrollMFAResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+	}
+	u.audit(gr, AuditEnrollMFA, usr.ID.String(), nil, nil)
+
+	return EnrollMFAResponse{
+		Secret: secret,
+		URI:    totp.URI(mfaIssuer, usr.Email.Address, secret),
+	}
+}
+
+// ConfirmMFA implements UserRpcService. A valid code from the enrolled app
+// enables two-factor authentication and returns the recovery codes, which
+// are never shown again. API keys cannot confirm enrollment.
+func (u UserServicer) ConfirmMFA(req ConfirmMFARequest, gr server.GenericRequest) ConfirmMFAResponse {
+	if err := req.Validate(); err != nil {
+		return ConfirmMFAResponse{Fault: errs.From(err)}
+	}
+
+	if isAPIKeyClaims(gr.Claims) {
+		return ConfirmMFAResponse{Fault: errs.From(ErrForbidden)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, gr.Claims.Subject)
+	if err != nil {
+		return ConfirmMFAResponse{Fault: errs.From(fmt.Errorf("querybyid: %w", err))}
+	}
+	if usr.MFAEnabled {
+		return ConfirmMFAResponse{Fault: errs.From(ErrMFAAlreadyEnabled)}
+	}
+	if usr.MFAPendingSecret == "" {
+		return ConfirmMFAResponse{Fault: errs.From(ErrMFANotEnrolled)}
+	}
+
+	step, ok := totp.Validate(usr.MFAPendingSecret, req.Code, time.Now(), mfaSkew)
+	if !ok {
+		return ConfirmMFAResponse{Fault: errs.From(ErrInvalidMFACode)}
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return ConfirmMFAResponse{Fault: errs.From(err)}
+	}
+
+	before := usr
+	usr.MFAEnabled = true
+	usr.MFASecret = usr.MFAPendingSecret
+	usr.MFAPendingSecret = ""
+	usr.MFALastStep = step
+	usr.RecoveryCodes = hashes
+	usr.DateUpdated = gr.Values.Now
+	result, err := u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return ConfirmMFAResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+	}
+	u.audit(gr, AuditEnableMFA, result.ID.String(), before, result)
+
+	return ConfirmMFAResponse{RecoveryCodes: codes}
+}
+
+// VerifyMFA implements UserRpcService. It completes an Authenticate that
+// returned an MFA challenge, using either a code from the authenticator app
+// or one of the recovery codes. Failures count towards the lockout of the
+// account like failed passwords.
+func (u UserServicer) VerifyMFA(req VerifyMFARequest, gr server.GenericRequest) VerifyMFAResponse {
+	if err := req.Validate(); err != nil {
+		return VerifyMFAResponse{Fault: errs.From(err)}
+	}
+
+	now := time.Now().UTC()
+
+	claims, err := parseToken(u.cfg.SigningKey, req.MFAToken, mfaPurpose, now)
+	if err != nil {
+		return VerifyMFAResponse{Fault: errs.From(err)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, claims.UserID)
+	if err != nil || usr.Email.Address != claims.Email || !usr.MFAEnabled {
+		return VerifyMFAResponse{Fault: errs.From(ErrInvalidToken)}
+	}
+
+	if usr.Locked(now) {
+		return VerifyMFAResponse{Fault: errs.From(fmt.Errorf("%w until %s", ErrAccountLocked, usr.LockedUntil.Format(time.RFC3339)))}
+	}
+	if !usr.Enabled {
+		return VerifyMFAResponse{Fault: errs.From(ErrAccountDisabled)}
+	}
+
+	before := usr
+
+	var ok bool
+	switch {
+	case req.Code != "":
+		var step int64
+		step, ok = totp.Validate(usr.MFASecret, req.Code, now, mfaSkew)
+
+		// A code is only good once, so one seen over a shoulder cannot be
+		// replayed within its window.
+		if ok && step > usr.MFALastStep {
+			usr.MFALastStep = step
+		} else {
+			ok = false
+		}
+
+	default:
+		ok = usr.useRecoveryCode(req.RecoveryCode)
+	}
+
+	if !ok {
+		after, err := u.storer.RecordFailedLogin(gr.Ctx, usr.ID.String(), now)
+		if err != nil {
+			return VerifyMFAResponse{Fault: errs.From(fmt.Errorf("recordfailedlogin: %w", err))}
+		}
+		u.audit(gr, AuditAuthenticateFailed, usr.ID.String(), before, after)
+		return VerifyMFAResponse{Fault: errs.From(ErrInvalidMFACode)}
+	}
+
+	usr.unlock()
+	usr, err = u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return VerifyMFAResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+	}
+
+	tkns, err := u.issueTokens(gr, usr, uuid.New(), req.Device, now)
+	if err != nil {
+		return VerifyMFAResponse{Fault: errs.From(err)}
+	}
+	u.audit(gr, AuditAuthenticate, usr.ID.String(), before, usr)
+
+	return VerifyMFAResponse{Tokens: tkns}
+}
+
+// mfaChallenge returns the token a client exchanges, together with a code,
+// for the tokens of usr through VerifyMFA.
+func (u UserServicer) mfaChallenge(usr User, now time.Time) (string, error) {
+	return signToken(u.cfg.SigningKey, signedClaims{
+		Purpose:   mfaPurpose,
+		UserID:    usr.ID.String(),
+		Email:     usr.Email.Address,
+		ExpiresAt: now.Add(mfaChallengeTTL).Unix(),
+	})
+}
+
+// useRecoveryCode removes code from the recovery codes of the user,
+// reporting whether it was one of them.
+func (u *User) useRecoveryCode(code string) bool {
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, h := range u.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCodes returns a set of recovery codes along with the hashes
+// they are stored as.
+func newRecoveryCodes() ([]string, []string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+
+		code := strings.ToLower(enc.EncodeToString(b)) + "-"
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		code += strings.ToLower(enc.EncodeToString(b))
+
+		codes[i] = code
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets recovery codes be typed in any case, with or
+// without the separator.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+// EnrollMFARequest is the request object for UserService.EnrollMFA.
+type EnrollMFARequest struct{}
+
+// EnrollMFAResponse is the response object for UserService.EnrollMFA.
+type EnrollMFAResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	errs.Fault
+}
+
+// ConfirmMFARequest is the request object for UserService.ConfirmMFA.
+type ConfirmMFARequest struct {
+	Code string `json:"code"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r ConfirmMFARequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "code", r.Code)
+	checkLength(&fe, "code", r.Code, maxMFACodeLength)
+	return fe.Err()
+}
+
+// ConfirmMFAResponse is the response object for UserService.ConfirmMFA.
+type ConfirmMFAResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+	errs.Fault
+}
+
+// VerifyMFARequest is the request object for UserService.VerifyMFA. Exactly
+// one of Code and RecoveryCode is given.
+type VerifyMFARequest struct {
+	MFAToken     string `json:"mfaToken"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+	Device       string `json:"device"`
+}
+
+// Validate checks the fields of the request hold usable values. Exactly
+// one of Code and RecoveryCode has to be given.
+func (r VerifyMFARequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "mfaToken", r.MFAToken)
+	checkLength(&fe, "mfaToken", r.MFAToken, maxTokenLength)
+	switch {
+	case r.Code == "" && r.RecoveryCode == "":
+		fe.Add("code", "either a code or a recovery code is required")
+	case r.Code != "" && r.RecoveryCode != "":
+		fe.Add("recoveryCode", "cannot be given with a code")
+	}
+	checkLength(&fe, "code", r.Code, maxMFACodeLength)
+	checkLength(&fe, "recoveryCode", r.RecoveryCode, maxMFACodeLength)
+	checkLength(&fe, "device", r.Device, maxDeviceLength)
+	return fe.Err()
+}
+
+// VerifyMFAResponse is the response object for UserService.VerifyMFA.
+type VerifyMFAResponse struct {
+	Tokens
+	errs.Fault
+}
@@ -9,15 +9,27 @@ import (
 
 // User represents information about an individual user.
 type User struct {
-	ID           uuid.UUID    `json:"id"`
-	Name         string       `json:"name"`
-	Email        mail.Address `json:"email"`
-	Roles        []Role       `json:"roles"`
-	PasswordHash []byte       `json:"password_hash"`
-	Department   string       `json:"department"`
-	Enabled      bool         `json:"enabled"`
-	DateCreated  time.Time    `json:"date_created"`
-	DateUpdated  time.Time    `json:"date_updated"`
+	ID                   uuid.UUID         `json:"id"`
+	Name                 string            `json:"name"`
+	Email                mail.Address      `json:"email"`
+	EmailVerified        bool              `json:"email_verified"`
+	Roles                []Role            `json:"roles"`
+	PasswordHash         []byte            `json:"-"`
+	Department           string            `json:"department"`
+	Preferences          map[string]string `json:"preferences"`
+	Enabled              bool              `json:"enabled"`
+	FailedLogins         int               `json:"failed_logins"`
+	LockedUntil          time.Time         `json:"locked_until"`
+	MFAEnabled           bool              `json:"mfa_enabled"`
+	MFASecret            string            `json:"-"`
+	MFAPendingSecret     string            `json:"-"`
+	MFALastStep          int64             `json:"-"`
+	RecoveryCodes        []string          `json:"-"`
+	DateVerificationSent time.Time         `json:"date_verification_sent"`
+	DateCreated          time.Time         `json:"date_created"`
+	DateUpdated          time.Time         `json:"date_updated"`
+	Version              string            `json:"version"`
+	DateDeleted          *time.Time        `json:"date_deleted"`
 }
 
 // NewUser contains information needed to create a new user.
@@ -30,13 +42,84 @@ type NewUser struct {
 	PasswordConfirm string       `json:"password_confirm"`
 }
 
-// UpdateUser contains information needed to update a user.
+// UpdateUser contains information needed to update a user. A changed Email
+// has to be verified again.
 type UpdateUser struct {
 	Name            *string       `json:"name"`
 	Email           *mail.Address `json:"email"`
 	Roles           []Role        `json:"roles"`
 	Department      *string       `json:"department"`
-	Password        *string       `json:"passowrd"`
+	Password        *string       `json:"password"`
 	PasswordConfirm *string       `json:"password_confirm"`
 	Enabled         *bool         `json:"enabled"`
 }
+
+// UpdateMe contains the changes users can make to their own profile.
+// Preferences replace those already set.
+type UpdateMe struct {
+	Name        *string           `json:"name"`
+	Department  *string           `json:"department"`
+	Preferences map[string]string `json:"preferences"`
+}
+
+// RefreshToken represents a refresh token issued for a session. Only the
+// hash of the token is kept.
+type RefreshToken struct {
+	Hash        string     `json:"hash"`
+	SessionID   uuid.UUID  `json:"session_id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Device      string     `json:"device"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires time.Time  `json:"date_expires"`
+	DateUsed    *time.Time `json:"date_used"`
+	DateRevoked *time.Time `json:"date_revoked"`
+}
+
+// PasswordReset represents a password reset token. Only the hash of the
+// token is kept.
+type PasswordReset struct {
+	Hash        string     `json:"hash"`
+	UserID      uuid.UUID  `json:"user_id"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires time.Time  `json:"date_expires"`
+	DateUsed    *time.Time `json:"date_used"`
+}
+
+// APIKey represents a personal API key. Only the hash of the key is kept;
+// Prefix holds its first characters so users can tell their keys apart.
+type APIKey struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Hash        string     `json:"-"`
+	Scopes      []string   `json:"scopes"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires time.Time  `json:"date_expires"`
+	DateRevoked *time.Time `json:"date_revoked"`
+}
+
+// RoleDefinition represents a role and the permissions it grants. Built in
+// roles are defined in code and cannot be changed.
+type RoleDefinition struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	Builtin     bool      `json:"builtin"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+	Version     string    `json:"version"`
+}
+
+// NewRole contains information needed to create a new role.
+type NewRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRole contains information needed to update a role.
+type UpdateRole struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
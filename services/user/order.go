@@ -0,0 +1,56 @@
+package user
+
+import "github.com/kjvonly/service/services/errs"
+
+// Set of fields users can be ordered by.
+const (
+	OrderByName        = "name"
+	OrderByEmail       = "email"
+	OrderByRoles       = "roles"
+	OrderByDepartment  = "department"
+	OrderByEnabled     = "enabled"
+	OrderByDateCreated = "date_created"
+)
+
+// Set of directions users can be ordered in.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+var orderByFields = map[string]bool{
+	OrderByName:        true,
+	OrderByEmail:       true,
+	OrderByRoles:       true,
+	OrderByDepartment:  true,
+	OrderByEnabled:     true,
+	OrderByDateCreated: true,
+}
+
+// DefaultOrderBy is the order used when a query does not specify one.
+var DefaultOrderBy = OrderBy{Field: OrderByEmail, Direction: ASC}
+
+// OrderBy represents a field used to order by and a direction.
+type OrderBy struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"`
+}
+
+// check adds the errors of an order that does not name a known field and
+// direction. The zero order stands for DefaultOrderBy and an empty
+// direction for ASC.
+func (o OrderBy) check(fe *errs.FieldErrors) {
+	if o == (OrderBy{}) {
+		return
+	}
+
+	if !orderByFields[o.Field] {
+		fe.Addf("orderBy.field", "unknown field %q", o.Field)
+	}
+
+	switch o.Direction {
+	case "", ASC, DESC:
+	default:
+		fe.Addf("orderBy.direction", "must be %s or %s", ASC, DESC)
+	}
+}
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kjvonly/service/services/errs"
+)
+
+// bcryptMaxLength is the most bytes of a password bcrypt uses; the rest
+// would be silently ignored.
+const bcryptMaxLength = 72
+
+// Set of default password policy settings.
+const (
+	defaultPasswordMinLength  = 10
+	defaultPasswordMinClasses = 2
+)
+
+// PasswordPolicy holds the rules new passwords have to follow.
+type PasswordPolicy struct {
+	// MinLength is the fewest characters a password can have.
+	MinLength int
+	// MaxLength is the most bytes a password can have. It cannot exceed the
+	// 72 bytes bcrypt uses.
+	MaxLength int
+	// MinClasses is the fewest character classes, out of lower case, upper
+	// case, digits and symbols, a password has to mix.
+	MinClasses int
+}
+
+// withDefaults returns the policy with unset settings given their defaults.
+func (p PasswordPolicy) withDefaults() PasswordPolicy {
+	if p.MinLength == 0 {
+		p.MinLength = defaultPasswordMinLength
+	}
+	if p.MaxLength == 0 || p.MaxLength > bcryptMaxLength {
+		p.MaxLength = bcryptMaxLength
+	}
+	if p.MinClasses == 0 {
+		p.MinClasses = defaultPasswordMinClasses
+	}
+	return p
+}
+
+// check returns the rules password breaks. The password must not be the
+// name or email of the user it is for, nor the local part of the email.
+func (p PasswordPolicy) check(password string, confirm string, name string, email string) errs.FieldErrors {
+	var fe errs.FieldErrors
+
+	switch {
+	case utf8.RuneCountInString(password) < p.MinLength:
+		fe.Addf("password", "must be at least %d characters", p.MinLength)
+	case len(password) > p.MaxLength:
+		fe.Addf("password", "must be at most %d bytes", p.MaxLength)
+	}
+
+	if n := characterClasses(password); n < p.MinClasses {
+		fe.Addf("password", "must mix at least %d of lower case, upper case, digits and symbols", p.MinClasses)
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	for _, s := range []string{name, email, local} {
+		if s != "" && strings.EqualFold(password, s) {
+			fe.Add("password", "must not be your name or email")
+			break
+		}
+	}
+
+	if password != confirm {
+		fe.Add("password_confirm", "passwords do not match")
+	}
+
+	return fe
+}
+
+// characterClasses counts the character classes password mixes.
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// checkPassword checks password against the password policy and, when one
+// is configured, the breached password list. Broken rules are returned as
+// field errors; the error is only set when the check itself failed.
+func (u UserServicer) checkPassword(ctx context.Context, password string, confirm string, name string, email string) (errs.FieldErrors, error) {
+	fe := u.cfg.PasswordPolicy.check(password, confirm, name, email)
+
+	if u.cfg.BreachedPasswords != nil && len(fe) == 0 {
+		breached, err := u.cfg.BreachedPasswords.Breached(ctx, password)
+		if err != nil {
+			return nil, fmt.Errorf("breached: %w", err)
+		}
+		if breached {
+			fe.Add("password", "has appeared in a data breach, choose another")
+		}
+	}
+
+	return fe, nil
+}
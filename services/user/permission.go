@@ -0,0 +1,193 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"github.com/kjvonly/service/services/audit"
+	"github.com/kjvonly/service/services/bible"
+	"github.com/kjvonly/service/services/errs"
+)
+
+// Set of permissions roles grant. Endpoints are registered with the
+// permission they require, which tokens carry alongside the role names.
+const (
+	PermUserRead  = "user:read"
+	PermUserWrite = "user:write"
+	PermRoleRead  = "role:read"
+	PermRoleWrite = "role:write"
+	PermAuditRead = audit.PermRead
+	PermSearch    = bible.PermSearch
+	// PermAccount allows users to manage their own account: two-factor
+	// authentication, API keys and sessions.
+	PermAccount = "account:manage"
+)
+
+// Permissions lists every permission a role can grant.
+var Permissions = []string{
+	PermUserRead,
+	PermUserWrite,
+	PermRoleRead,
+	PermRoleWrite,
+	PermAuditRead,
+	PermSearch,
+	PermAccount,
+}
+
+// builtinRoles are the permissions of the roles defined in code. They cannot
+// be changed so an admin can never be locked out.
+var builtinRoles = map[string][]string{
+	RoleAdmin.name: Permissions,
+	RoleUser.name:  {PermSearch, PermAccount},
+}
+
+// Set of errors returned when giving roles.
+var (
+	// ErrUnknownRole is returned when a user is given a role that is not
+	// defined.
+	ErrUnknownRole = errs.New(errs.InvalidArgument, "unknown role")
+	// ErrNotGrantable is returned when a caller grants permissions it does
+	// not hold, through a role given to a user or the permissions of a
+	// role.
+	ErrNotGrantable = errs.New(errs.PermissionDenied, "permissions not held by caller")
+)
+
+// validPermission reports whether perm is one of Permissions.
+func validPermission(perm string) bool {
+	return contains(Permissions, perm)
+}
+
+// contains reports whether list holds value.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// grants returns what tokens for a user with roles carry: the names of the
+// roles followed by the permissions they grant. Roles deleted since they
+// were given grant nothing.
+func (u UserServicer) grants(ctx context.Context, roles []Role) ([]string, error) {
+	names := make([]string, 0, len(roles))
+	seen := map[string]bool{}
+	var perms []string
+
+	for _, role := range roles {
+		names = append(names, role.name)
+
+		rolePerms, err := u.permissions(ctx, role)
+		switch {
+		case errors.Is(err, ErrRoleNotFound):
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		for _, p := range rolePerms {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+
+	return append(names, perms...), nil
+}
+
+// permissions returns the permissions role grants, or ErrRoleNotFound when
+// it is not defined.
+func (u UserServicer) permissions(ctx context.Context, role Role) ([]string, error) {
+	if perms, ok := builtinRoles[role.name]; ok {
+		return perms, nil
+	}
+
+	rd, err := u.storer.QueryRole(ctx, role.name)
+	if err != nil {
+		return nil, fmt.Errorf("queryrole: %w", err)
+	}
+	return rd.Permissions, nil
+}
+
+// held returns the permissions claims carry, directly or through the built
+// in roles they name. Tokens list the permissions of stored roles next to
+// their names, so those need no lookup.
+func held(claims auth.Claims) map[string]bool {
+	perms := map[string]bool{}
+	for _, r := range claims.Roles {
+		perms[r] = true
+		for _, p := range builtinRoles[r] {
+			perms[p] = true
+		}
+	}
+	return perms
+}
+
+// checkHeld adds an error for field for each of perms that claims do not
+// carry, so callers cannot grant more than they hold.
+func checkHeld(fe *errs.FieldErrors, field string, claims auth.Claims, perms []string) {
+	have := held(claims)
+	for _, p := range perms {
+		if !have[p] {
+			fe.Addf(field, "grants %q, which you do not hold", p)
+		}
+	}
+}
+
+// checkRoles returns ErrUnknownRole unless every role is defined and
+// ErrNotGrantable unless claims carry every permission the roles grant. The
+// offending roles are listed as errors of the roles field. Role names are
+// expected to have been validated with the request.
+func (u UserServicer) checkRoles(ctx context.Context, claims auth.Claims, roles []Role) error {
+	var unknown, notHeld errs.FieldErrors
+	for i, role := range roles {
+		field := fmt.Sprintf("roles[%d]", i)
+
+		perms, err := u.permissions(ctx, role)
+		switch {
+		case errors.Is(err, ErrRoleNotFound):
+			unknown.Addf(field, "role %q is not defined", role.name)
+			continue
+		case err != nil:
+			return err
+		}
+
+		checkHeld(&notHeld, field, claims, perms)
+	}
+
+	switch {
+	case len(unknown) > 0:
+		return fmt.Errorf("%w: %w", ErrUnknownRole, unknown)
+	case len(notHeld) > 0:
+		return fmt.Errorf("%w: %w", ErrNotGrantable, notHeld)
+	}
+	return nil
+}
+
+// checkTarget returns ErrNotGrantable unless claims carry every permission
+// usr holds. Whoever can change the email or password of a user can take
+// over their account, so callers may only change users holding no more
+// than they do.
+func (u UserServicer) checkTarget(ctx context.Context, claims auth.Claims, usr User) error {
+	have := held(claims)
+	for _, role := range usr.Roles {
+		perms, err := u.permissions(ctx, role)
+		switch {
+		case errors.Is(err, ErrRoleNotFound):
+			continue
+		case err != nil:
+			return err
+		}
+
+		for _, p := range perms {
+			if !have[p] {
+				return fmt.Errorf("%w: user holds %q", ErrNotGrantable, p)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"git.launchpad.net/~man4christ/+git/seed/values"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/audit"
+	"github.com/kjvonly/service/services/errs"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// roleStore serves the roles, users and sessions permission checks look
+// up. Other methods of Storer are not used by them.
+type roleStore struct {
+	Storer
+	roles map[string]RoleDefinition
+	users map[string]User
+}
+
+func (s roleStore) QueryRole(ctx context.Context, name string) (RoleDefinition, error) {
+	rd, ok := s.roles[name]
+	if !ok {
+		return RoleDefinition{}, ErrRoleNotFound
+	}
+	return rd, nil
+}
+
+func (s roleStore) QueryByID(ctx context.Context, id string) (User, error) {
+	usr, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return usr, nil
+}
+
+func (s roleStore) RevokeUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	return nil
+}
+
+func (s roleStore) SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+func Test_Permissions(t *testing.T) {
+	adminID, userID := uuid.NewString(), uuid.NewString()
+	u := UserServicer{
+		log: zap.NewNop().Sugar(),
+		storer: roleStore{
+			roles: map[string]RoleDefinition{
+				"EDITOR": {Name: "EDITOR", Permissions: []string{PermUserRead, PermUserWrite, PermAccount}},
+			},
+			users: map[string]User{
+				adminID: {Roles: []Role{RoleAdmin}},
+				userID:  {Roles: []Role{MustParseRole("EDITOR")}},
+			},
+		},
+		cfg: Config{Audit: audit.NewLogRecorder(zap.NewNop().Sugar())},
+	}
+	ctx := context.Background()
+
+	session := jwt.RegisteredClaims{ID: uuid.NewString(), Subject: uuid.NewString()}
+	user := auth.Claims{RegisteredClaims: session, Roles: []string{RoleUser.name, PermSearch, PermAccount}}
+	editor := auth.Claims{RegisteredClaims: session, Roles: []string{"EDITOR", PermUserRead, PermUserWrite, PermAccount}}
+	admin := auth.Claims{RegisteredClaims: session, Roles: []string{RoleAdmin.name}}
+
+	t.Log("Given the need to only grant permissions callers hold.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen calling endpoints that require a permission.", testID)
+		{
+			var called bool
+			h := u.authorize(PermUserRead, func(gr server.GenericRequest, b []byte) (any, error) {
+				called = true
+				return nil, nil
+			})
+
+			resp, err := h(server.GenericRequest{Ctx: ctx, Claims: user}, nil)
+			f, _ := resp.(errs.Fault)
+			if err != nil || called || f.Code != errs.PermissionDenied {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse a token without the permission : got %+v, %v.", failed, testID, resp, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse a token without the permission.", success, testID)
+
+			for _, claims := range []auth.Claims{editor, admin} {
+				called = false
+				if _, err := h(server.GenericRequest{Ctx: ctx, Claims: claims}, nil); err != nil || !called {
+					t.Fatalf("\t%s\tTest %d:\tShould accept a token with the permission %v : got %v.", failed, testID, claims.Roles, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept a token with the permission.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen giving roles.", testID)
+		{
+			err := u.checkRoles(ctx, editor, []Role{RoleAdmin})
+			var fe errs.FieldErrors
+			if !errors.Is(err, ErrNotGrantable) || !errors.As(err, &fe) || fe[0].Field != "roles[0]" {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse a role granting permissions the caller lacks : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse a role granting permissions the caller lacks.", success, testID)
+
+			if err := u.checkRoles(ctx, editor, []Role{MustParseRole("EDITOR")}); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould give a role granting permissions the caller holds : got %v.", failed, testID, err)
+			}
+			if err := u.checkRoles(ctx, admin, []Role{RoleAdmin, RoleUser}); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould let admins give any role : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould give roles granting permissions the caller holds.", success, testID)
+
+			if err := u.checkRoles(ctx, admin, []Role{MustParseRole("GHOST")}); !errors.Is(err, ErrUnknownRole) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse undefined roles : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse undefined roles.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen changing users.", testID)
+		{
+			if err := u.checkTarget(ctx, editor, User{Roles: []Role{RoleAdmin}}); !errors.Is(err, ErrNotGrantable) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to change a user holding more than the caller : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to change a user holding more than the caller.", success, testID)
+
+			if err := u.checkTarget(ctx, editor, User{Roles: []Role{MustParseRole("EDITOR"), MustParseRole("GONE")}}); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould change a user holding no more than the caller : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change a user holding no more than the caller.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen revoking the sessions of other users.", testID)
+		{
+			gr := func(claims auth.Claims) server.GenericRequest {
+				return server.GenericRequest{Ctx: ctx, Claims: claims, Values: &values.Values{}}
+			}
+
+			if f := u.RevokeAllSessions(RevokeAllSessionsRequest{UserID: userID}, gr(user)).Fault; f.Code != errs.PermissionDenied {
+				t.Fatalf("\t%s\tTest %d:\tShould require user:write : got %+v.", failed, testID, f)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require user:write.", success, testID)
+
+			if f := u.RevokeAllSessions(RevokeAllSessionsRequest{UserID: adminID}, gr(editor)).Fault; f.Code != errs.PermissionDenied {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to log out a user holding more than the caller : got %+v.", failed, testID, f)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to log out a user holding more than the caller.", success, testID)
+
+			if f := u.RevokeAllSessions(RevokeAllSessionsRequest{UserID: userID}, gr(editor)).Fault; f.Code != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould log out a user holding no more than the caller : got %+v.", failed, testID, f)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log out a user holding no more than the caller.", success, testID)
+		}
+	}
+}
+
+func Test_APIKeyAccount(t *testing.T) {
+	// The store is left nil, so reaching it fails the test.
+	u := UserServicer{log: zap.NewNop().Sugar()}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: uuid.NewString(), Subject: uuid.NewString(), Audience: jwt.ClaimStrings{apiKeyAudience}},
+		Roles:            []string{RoleUser.name, PermSearch, PermAccount},
+	}
+	gr := server.GenericRequest{Ctx: context.Background(), Claims: claims}
+	name := "Jane Doe"
+
+	t.Log("Given the need to keep API keys away from account security.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen calling account endpoints with an api key token.", testID)
+		{
+			faults := map[string]errs.Fault{
+				"Logout":            u.Logout(LogoutRequest{}, gr).Fault,
+				"RevokeAllSessions": u.RevokeAllSessions(RevokeAllSessionsRequest{}, gr).Fault,
+				"EnrollMFA":         u.EnrollMFA(EnrollMFARequest{}, gr).Fault,
+				"ConfirmMFA":        u.ConfirmMFA(ConfirmMFARequest{Code: "123456"}, gr).Fault,
+				"UpdateMe":          u.UpdateMe(UpdateMeRequest{UpdateMe: UpdateMe{Name: &name}}, gr).Fault,
+			}
+			for method, f := range faults {
+				if f.Code != errs.PermissionDenied {
+					t.Fatalf("\t%s\tTest %d:\tShould refuse %s : got %+v.", failed, testID, method, f)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse them.", success, testID)
+		}
+	}
+}
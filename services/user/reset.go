@@ -0,0 +1,183 @@
+package user
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/kjvonly/service/services/errs"
+	"github.com/kjvonly/service/services/user/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultResetTokenTTL = time.Hour
+
+// ErrInvalidResetToken is returned when a password reset token is unknown,
+// expired or already used.
+var ErrInvalidResetToken = errs.New(errs.InvalidArgument, "invalid or expired reset token")
+
+// RequestPasswordReset implements UserRpcService. It emails a single use
+// reset link to the user. The response is the same whether or not the email
+// belongs to a user so accounts cannot be discovered through it.
+func (u UserServicer) RequestPasswordReset(req RequestPasswordResetRequest, gr server.GenericRequest) RequestPasswordResetResponse {
+	if err := req.Validate(); err != nil {
+		return RequestPasswordResetResponse{Fault: errs.From(err)}
+	}
+
+	usr, err := u.storer.QueryByEmail(gr.Ctx, req.Email)
+	if err != nil || !usr.Enabled {
+		u.log.Infow("requestpasswordreset: no enabled user", "trace_id", gr.Values.TraceID, "email", req.Email)
+		return RequestPasswordResetResponse{}
+	}
+
+	tkn, err := newToken()
+	if err != nil {
+		return RequestPasswordResetResponse{Fault: errs.From(err)}
+	}
+
+	now := time.Now().UTC()
+	pr := PasswordReset{
+		Hash:        hashToken(tkn),
+		UserID:      usr.ID,
+		DateCreated: now,
+		DateExpires: now.Add(u.cfg.ResetTokenTTL),
+	}
+	if err := u.storer.CreatePasswordReset(gr.Ctx, pr); err != nil {
+		return RequestPasswordResetResponse{Fault: errs.From(fmt.Errorf("createpasswordreset: %w", err))}
+	}
+
+	link, err := withQuery(u.cfg.ResetURL, "token", tkn)
+	if err != nil {
+		return RequestPasswordResetResponse{Fault: errs.From(err)}
+	}
+
+	msg := mailer.Message{
+		To:      usr.Email,
+		Subject: "Reset your kjvonly password",
+		Body: fmt.Sprintf("A password reset was requested for your account.\n\n"+
+			"Follow this link within %s to choose a new password:\n\n%s\n\n"+
+			"If you did not ask to reset your password you can ignore this email.\n",
+			u.cfg.ResetTokenTTL, link),
+	}
+	if err := u.cfg.Mailer.Send(gr.Ctx, msg); err != nil {
+		// Failing here would tell the caller the account exists.
+		u.log.Errorw("requestpasswordreset: send", "trace_id", gr.Values.TraceID, "ERROR", err)
+	}
+
+	return RequestPasswordResetResponse{}
+}
+
+// ResetPassword implements UserRpcService. A valid token sets the new
+// password, clears any lockout and signs the user out everywhere.
+func (u UserServicer) ResetPassword(req ResetPasswordRequest, gr server.GenericRequest) ResetPasswordResponse {
+	if err := req.Validate(); err != nil {
+		return ResetPasswordResponse{Fault: errs.From(err)}
+	}
+
+	now := time.Now().UTC()
+	hash := hashToken(req.Token)
+
+	pr, err := u.storer.QueryPasswordReset(gr.Ctx, hash)
+	if err != nil || pr.DateUsed != nil || !now.Before(pr.DateExpires) {
+		return ResetPasswordResponse{Fault: errs.From(ErrInvalidResetToken)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, pr.UserID.String())
+	if err != nil {
+		return ResetPasswordResponse{Fault: errs.From(fmt.Errorf("querybyid: %w", err))}
+	}
+
+	// The password is checked before the token is used so a refused
+	// password can be corrected with the same link.
+	fe, err := u.checkPassword(gr.Ctx, req.Password, req.PasswordConfirm, usr.Name, usr.Email.Address)
+	if err != nil {
+		return ResetPasswordResponse{Fault: errs.From(err)}
+	}
+	if len(fe) > 0 {
+		return ResetPasswordResponse{Fault: errs.From(fe)}
+	}
+
+	fresh, err := u.storer.UsePasswordReset(gr.Ctx, hash, now)
+	if err != nil {
+		return ResetPasswordResponse{Fault: errs.From(fmt.Errorf("usepasswordreset: %w", err))}
+	}
+	if !fresh {
+		return ResetPasswordResponse{Fault: errs.From(ErrInvalidResetToken)}
+	}
+
+	before := usr
+	pw, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return ResetPasswordResponse{Fault: errs.From(fmt.Errorf("generatefrompassword: %w", err))}
+	}
+	usr.PasswordHash = pw
+	usr.unlock()
+	usr.DateUpdated = now
+
+	result, err := u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return ResetPasswordResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+	}
+	u.audit(gr, AuditResetPassword, result.ID.String(), before, result)
+
+	if err := u.storer.RevokeUserSessions(gr.Ctx, usr.ID, now); err != nil {
+		return ResetPasswordResponse{Fault: errs.From(fmt.Errorf("revokeusersessions: %w", err))}
+	}
+
+	return ResetPasswordResponse{}
+}
+
+// withQuery returns rawURL with key set to value in its query string.
+func withQuery(rawURL string, key string, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// RequestPasswordResetRequest is the request object for
+// UserService.RequestPasswordReset.
+type RequestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r RequestPasswordResetRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkEmail(&fe, "email", r.Email)
+	return fe.Err()
+}
+
+// RequestPasswordResetResponse is the response object for
+// UserService.RequestPasswordReset.
+type RequestPasswordResetResponse struct {
+	errs.Fault
+}
+
+// ResetPasswordRequest is the request object for UserService.ResetPassword.
+type ResetPasswordRequest struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	PasswordConfirm string `json:"passwordConfirm"`
+}
+
+// Validate checks the fields of the request hold usable values. The new
+// password is checked against the password policy by ResetPassword.
+func (r ResetPasswordRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "token", r.Token)
+	checkLength(&fe, "token", r.Token, maxTokenLength)
+	return fe.Err()
+}
+
+// ResetPasswordResponse is the response object for UserService.ResetPassword.
+type ResetPasswordResponse struct {
+	errs.Fault
+}
@@ -1,36 +1,33 @@
 package user
 
-import "errors"
+import "regexp"
 
-// Set of possible roles for a user.
+// Set of built in roles for a user. Other roles are defined at runtime.
 var (
 	RoleAdmin = Role{"ADMIN"}
 	RoleUser  = Role{"USER"}
 )
 
-// Set of known roles.
-var roles = map[string]Role{
-	RoleAdmin.name: RoleAdmin,
-	RoleUser.name:  RoleUser,
-}
-
 // Role represents a role in the system.
 type Role struct {
 	name string
 }
 
-// ParseRole parses the string value and returns a role if one exists.
+// roleName is the form role names take.
+var roleName = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,31}$`)
+
+// ParseRole parses the string value and returns a role if it is a well
+// formed role name. Whether the role is defined is up to the store.
 func ParseRole(value string) (Role, error) {
-	role, exists := roles[value]
-	if !exists {
-		return Role{}, errors.New("invalid role")
+	if !roleName.MatchString(value) {
+		return Role{}, ErrInvalidRole
 	}
 
-	return role, nil
+	return Role{value}, nil
 }
 
-// MustParseRole parses the string value and returns a role if one exists. If
-// an error occurs the function panics.
+// MustParseRole parses the string value and returns a role if it is well
+// formed. If an error occurs the function panics.
 func MustParseRole(value string) Role {
 	role, err := ParseRole(value)
 	if err != nil {
@@ -0,0 +1,249 @@
+package user
+
+import (
+	"fmt"
+	"sort"
+
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/kjvonly/service/services/errs"
+)
+
+const maxRoleDescriptionLength = 256
+
+// Set of errors for managing roles.
+var (
+	ErrRoleNotFound = errs.New(errs.NotFound, "role not found")
+	ErrRoleExists   = errs.New(errs.Conflict, "role already exists")
+	ErrBuiltinRole  = errs.New(errs.InvalidArgument, "built in roles cannot be changed")
+	ErrRoleInUse    = errs.New(errs.Conflict, "role is held by users")
+	ErrInvalidRole  = errs.New(errs.InvalidArgument, "invalid role")
+)
+
+// CreateRole implements UserRpcService. Callers can only grant permissions
+// they hold themselves.
+func (u UserServicer) CreateRole(req CreateRoleRequest, gr server.GenericRequest) CreateRoleResponse {
+	if err := req.Validate(); err != nil {
+		return CreateRoleResponse{Fault: errs.From(err)}
+	}
+
+	nr := req.NewRole
+	if _, ok := builtinRoles[nr.Name]; ok {
+		return CreateRoleResponse{Fault: errs.From(ErrRoleExists)}
+	}
+
+	var fe errs.FieldErrors
+	checkHeld(&fe, "permissions", gr.Claims, nr.Permissions)
+	if len(fe) > 0 {
+		return CreateRoleResponse{Fault: errs.From(fmt.Errorf("%w: %w", ErrNotGrantable, fe))}
+	}
+
+	rd := RoleDefinition{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: nr.Permissions,
+		DateCreated: gr.Values.Now,
+		DateUpdated: gr.Values.Now,
+	}
+	result, err := u.storer.CreateRole(gr.Ctx, rd)
+	if err != nil {
+		return CreateRoleResponse{Fault: errs.From(fmt.Errorf("createrole: %w", err))}
+	}
+	u.audit(gr, AuditCreateRole, result.Name, nil, result)
+
+	return CreateRoleResponse{Role: result}
+}
+
+// UpdateRole implements UserRpcService. Callers can only grant permissions
+// they hold themselves. Tokens already issued keep the permissions they
+// carry until they are refreshed.
+func (u UserServicer) UpdateRole(req UpdateRoleRequest, gr server.GenericRequest) UpdateRoleResponse {
+	if err := req.Validate(); err != nil {
+		return UpdateRoleResponse{Fault: errs.From(err)}
+	}
+	if _, ok := builtinRoles[req.Name]; ok {
+		return UpdateRoleResponse{Fault: errs.From(ErrBuiltinRole)}
+	}
+	if req.Version == "" {
+		return UpdateRoleResponse{Fault: errs.From(ErrVersionRequired)}
+	}
+
+	rd, err := u.storer.QueryRole(gr.Ctx, req.Name)
+	if err != nil {
+		return UpdateRoleResponse{Fault: errs.From(fmt.Errorf("queryrole: name[%s]: %w", req.Name, err))}
+	}
+	before := rd
+
+	ur := req.UpdateRole
+	if ur.Description != nil {
+		rd.Description = *ur.Description
+	}
+	if ur.Permissions != nil {
+		var fe errs.FieldErrors
+		checkHeld(&fe, "permissions", gr.Claims, ur.Permissions)
+		if len(fe) > 0 {
+			return UpdateRoleResponse{Fault: errs.From(fmt.Errorf("%w: %w", ErrNotGrantable, fe))}
+		}
+		rd.Permissions = ur.Permissions
+	}
+	rd.DateUpdated = gr.Values.Now
+	rd.Version = req.Version
+
+	result, err := u.storer.UpdateRole(gr.Ctx, rd)
+	if err != nil {
+		return UpdateRoleResponse{Fault: errs.From(fmt.Errorf("updaterole: %w", err))}
+	}
+	u.audit(gr, AuditUpdateRole, result.Name, before, result)
+
+	return UpdateRoleResponse{Role: result}
+}
+
+// DeleteRole implements UserRpcService. Roles still held by users cannot be
+// deleted.
+func (u UserServicer) DeleteRole(req DeleteRoleRequest, gr server.GenericRequest) DeleteRoleResponse {
+	if err := req.Validate(); err != nil {
+		return DeleteRoleResponse{Fault: errs.From(err)}
+	}
+	if _, ok := builtinRoles[req.Name]; ok {
+		return DeleteRoleResponse{Fault: errs.From(ErrBuiltinRole)}
+	}
+	if req.Version == "" {
+		return DeleteRoleResponse{Fault: errs.From(ErrVersionRequired)}
+	}
+
+	holders, err := u.storer.Count(gr.Ctx, QueryFilter{Role: &req.Name})
+	if err != nil {
+		return DeleteRoleResponse{Fault: errs.From(fmt.Errorf("count: %w", err))}
+	}
+	if holders > 0 {
+		return DeleteRoleResponse{Fault: errs.From(fmt.Errorf("%w: %d users", ErrRoleInUse, holders))}
+	}
+
+	rd, err := u.storer.DeleteRole(gr.Ctx, req.Name, req.Version)
+	if err != nil {
+		return DeleteRoleResponse{Fault: errs.From(fmt.Errorf("deleterole: %w", err))}
+	}
+	u.audit(gr, AuditDeleteRole, rd.Name, rd, nil)
+
+	return DeleteRoleResponse{}
+}
+
+// QueryRoles implements UserRpcService. The built in roles are listed
+// first.
+func (u UserServicer) QueryRoles(req QueryRolesRequest, gr server.GenericRequest) QueryRolesResponse {
+	names := make([]string, 0, len(builtinRoles))
+	for name := range builtinRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	roles := make([]RoleDefinition, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, RoleDefinition{
+			Name:        name,
+			Permissions: builtinRoles[name],
+			Builtin:     true,
+		})
+	}
+
+	stored, err := u.storer.QueryRoles(gr.Ctx)
+	if err != nil {
+		return QueryRolesResponse{Fault: errs.From(fmt.Errorf("queryroles: %w", err))}
+	}
+
+	return QueryRolesResponse{Roles: append(roles, stored...)}
+}
+
+// checkPermissions adds an error for each permission that is not known.
+func checkPermissions(fe *errs.FieldErrors, perms []string) {
+	for i, p := range perms {
+		if !validPermission(p) {
+			fe.Addf(fmt.Sprintf("permissions[%d]", i), "unknown permission %q", p)
+		}
+	}
+}
+
+// invalidRole returns the field errors of a role request as ErrInvalidRole,
+// or nil when there are none.
+func invalidRole(fe errs.FieldErrors) error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidRole, fe)
+}
+
+// CreateRoleRequest is the request object for UserService.CreateRole.
+type CreateRoleRequest struct {
+	NewRole NewRole `json:"role"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r CreateRoleRequest) Validate() error {
+	nr := r.NewRole
+
+	var fe errs.FieldErrors
+	checkRoleName(&fe, "name", nr.Name)
+	checkLength(&fe, "description", nr.Description, maxRoleDescriptionLength)
+	checkPermissions(&fe, nr.Permissions)
+	return invalidRole(fe)
+}
+
+// CreateRoleResponse is the response object for UserService.CreateRole.
+type CreateRoleResponse struct {
+	Role RoleDefinition `json:"role"`
+	errs.Fault
+}
+
+// UpdateRoleRequest is the request object for UserService.UpdateRole.
+// Version is the version of the role the changes were made against.
+type UpdateRoleRequest struct {
+	Name       string     `json:"name"`
+	Version    string     `json:"version"`
+	UpdateRole UpdateRole `json:"role"`
+}
+
+// Validate checks the fields of the request hold usable values. Only the
+// fields being changed are checked.
+func (r UpdateRoleRequest) Validate() error {
+	ur := r.UpdateRole
+
+	var fe errs.FieldErrors
+	checkRoleName(&fe, "name", r.Name)
+	if ur.Description != nil {
+		checkLength(&fe, "description", *ur.Description, maxRoleDescriptionLength)
+	}
+	checkPermissions(&fe, ur.Permissions)
+	return invalidRole(fe)
+}
+
+// UpdateRoleResponse is the response object for UserService.UpdateRole.
+type UpdateRoleResponse struct {
+	Role RoleDefinition `json:"role"`
+	errs.Fault
+}
+
+// DeleteRoleRequest is the request object for UserService.DeleteRole.
+type DeleteRoleRequest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r DeleteRoleRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRoleName(&fe, "name", r.Name)
+	return invalidRole(fe)
+}
+
+// DeleteRoleResponse is the response object for UserService.DeleteRole.
+type DeleteRoleResponse struct {
+	errs.Fault
+}
+
+// QueryRolesRequest is the request object for UserService.QueryRoles.
+type QueryRolesRequest struct{}
+
+// QueryRolesResponse is the response object for UserService.QueryRoles.
+type QueryRolesResponse struct {
+	Roles []RoleDefinition `json:"roles"`
+	errs.Fault
+}
@@ -0,0 +1,308 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+)
+
+const (
+	tokenTTL        = time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+	maxDeviceLength = 128
+)
+
+// Set of errors returned when handling sessions.
+var (
+	ErrInvalidRefreshToken = errs.New(errs.Unauthenticated, "invalid refresh token")
+	ErrRefreshTokenReused  = errs.New(errs.Unauthenticated, "refresh token reused, session revoked")
+	ErrSessionRevoked      = errs.New(errs.Unauthenticated, "session revoked")
+	ErrForbidden           = errs.New(errs.PermissionDenied, "attempted action is not allowed")
+)
+
+// RefreshToken implements UserRpcService. The presented token is exchanged
+// for a new access token and a new refresh token. A refresh token can only
+// be used once; presenting it again revokes the whole session since it means
+// the token was stolen.
+func (u UserServicer) RefreshToken(req RefreshTokenRequest, gr server.GenericRequest) RefreshTokenResponse {
+	if err := req.Validate(); err != nil {
+		return RefreshTokenResponse{Fault: errs.From(err)}
+	}
+
+	now := time.Now().UTC()
+	hash := hashToken(req.RefreshToken)
+
+	rt, err := u.storer.QueryRefreshToken(gr.Ctx, hash)
+	if err != nil {
+		u.log.Infow("refreshtoken: query", "trace_id", gr.Values.TraceID, "ERROR", err)
+		return RefreshTokenResponse{Fault: errs.From(ErrInvalidRefreshToken)}
+	}
+
+	switch {
+	case rt.DateRevoked != nil:
+		return RefreshTokenResponse{Fault: errs.From(ErrSessionRevoked)}
+	case !now.Before(rt.DateExpires):
+		return RefreshTokenResponse{Fault: errs.From(ErrInvalidRefreshToken)}
+	}
+
+	fresh, err := u.storer.UseRefreshToken(gr.Ctx, hash, now)
+	if err != nil {
+		return RefreshTokenResponse{Fault: errs.From(fmt.Errorf("userefreshtoken: %w", err))}
+	}
+	if !fresh {
+		if err := u.storer.RevokeSession(gr.Ctx, rt.SessionID, now); err != nil {
+			return RefreshTokenResponse{Fault: errs.From(fmt.Errorf("revokesession: %w", err))}
+		}
+		u.log.Infow("refreshtoken: reuse detected", "trace_id", gr.Values.TraceID, "session_id", rt.SessionID, "user_id", rt.UserID)
+		return RefreshTokenResponse{Fault: errs.From(ErrRefreshTokenReused)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, rt.UserID.String())
+	if err != nil {
+		return RefreshTokenResponse{Fault: errs.From(fmt.Errorf("querybyid: %w", err))}
+	}
+	if !usr.Enabled {
+		return RefreshTokenResponse{Fault: errs.From(ErrAccountDisabled)}
+	}
+
+	tkns, err := u.issueTokens(gr, usr, rt.SessionID, rt.Device, now)
+	if err != nil {
+		return RefreshTokenResponse{Fault: errs.From(err)}
+	}
+
+	return RefreshTokenResponse{Tokens: tkns}
+}
+
+// Logout implements UserRpcService. It revokes the session the caller's
+// token belongs to. Tokens of API keys belong to no session; the key has to
+// be revoked instead.
+func (u UserServicer) Logout(req LogoutRequest, gr server.GenericRequest) LogoutResponse {
+	if isAPIKeyClaims(gr.Claims) {
+		return LogoutResponse{Fault: errs.From(fmt.Errorf("%w: api keys cannot log out, revoke the key instead", ErrForbidden))}
+	}
+
+	sessionID, err := uuid.Parse(gr.Claims.ID)
+	if err != nil {
+		return LogoutResponse{Fault: errs.From(ErrSessionRevoked)}
+	}
+
+	if err := u.storer.RevokeSession(gr.Ctx, sessionID, time.Now().UTC()); err != nil {
+		return LogoutResponse{Fault: errs.From(fmt.Errorf("revokesession: %w", err))}
+	}
+
+	if userID, err := uuid.Parse(gr.Claims.Subject); err == nil {
+		u.audit(gr, AuditLogout, userID.String(), nil, nil)
+	}
+
+	return LogoutResponse{}
+}
+
+// RevokeAllSessions implements UserRpcService. Users may revoke their own
+// sessions; revoking the sessions of another user requires PermUserWrite
+// and every permission they hold. API keys cannot revoke sessions.
+func (u UserServicer) RevokeAllSessions(req RevokeAllSessionsRequest, gr server.GenericRequest) RevokeAllSessionsResponse {
+	if err := req.Validate(); err != nil {
+		return RevokeAllSessionsResponse{Fault: errs.From(err)}
+	}
+
+	if isAPIKeyClaims(gr.Claims) {
+		return RevokeAllSessionsResponse{Fault: errs.From(ErrForbidden)}
+	}
+
+	userID := req.UserID
+	if userID == "" {
+		userID = gr.Claims.Subject
+	}
+
+	if userID != gr.Claims.Subject {
+		if !held(gr.Claims)[PermUserWrite] {
+			return RevokeAllSessionsResponse{Fault: errs.From(ErrForbidden)}
+		}
+
+		target, err := u.storer.QueryByID(gr.Ctx, userID)
+		if err != nil {
+			return RevokeAllSessionsResponse{Fault: errs.From(fmt.Errorf("query: id[%s]: %w", userID, err))}
+		}
+		if err := u.checkTarget(gr.Ctx, gr.Claims, target); err != nil {
+			return RevokeAllSessionsResponse{Fault: errs.From(err)}
+		}
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return RevokeAllSessionsResponse{Fault: errs.From(fmt.Errorf("parse user id: %w", err))}
+	}
+
+	if err := u.storer.RevokeUserSessions(gr.Ctx, id, time.Now().UTC()); err != nil {
+		return RevokeAllSessionsResponse{Fault: errs.From(fmt.Errorf("revokeusersessions: %w", err))}
+	}
+	u.audit(gr, AuditRevokeSessions, id.String(), nil, nil)
+
+	return RevokeAllSessionsResponse{}
+}
+
+// authorize wraps the handler of an authenticated endpoint so requests made
+// without perm or with the token of a revoked session are refused. Refusals
+// are responses carrying a fault, so they are served with the status of its
+// code.
+func (u UserServicer) authorize(perm string, h func(server.GenericRequest, []byte) (any, error)) func(server.GenericRequest, []byte) (any, error) {
+	return func(gr server.GenericRequest, b []byte) (any, error) {
+		if !held(gr.Claims)[perm] {
+			return errs.From(ErrForbidden), nil
+		}
+
+		sessionID, err := uuid.Parse(gr.Claims.ID)
+		if err != nil {
+			return errs.From(ErrSessionRevoked), nil
+		}
+
+		revoked, err := u.storer.SessionRevoked(gr.Ctx, sessionID)
+		if err != nil {
+			return errs.From(fmt.Errorf("sessionrevoked: %w", err)), nil
+		}
+		if revoked {
+			return errs.From(ErrSessionRevoked), nil
+		}
+
+		return h(gr, b)
+	}
+}
+
+// issueTokens generates an access token for usr in the given session along
+// with the refresh token that renews it.
+func (u UserServicer) issueTokens(gr server.GenericRequest, usr User, sessionID uuid.UUID, device string, now time.Time) (Tokens, error) {
+	roles, err := u.grants(gr.Ctx, usr.Roles)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID.String(),
+			Subject:   usr.ID.String(),
+			Issuer:    "kjvonly",
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles: roles,
+	}
+
+	tkn, err := u.auth.GenerateToken(claims)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("generatetoken: %w", err)
+	}
+
+	refresh, err := newToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	rt := RefreshToken{
+		Hash:        hashToken(refresh),
+		SessionID:   sessionID,
+		UserID:      usr.ID,
+		Device:      device,
+		DateCreated: now,
+		DateExpires: now.Add(refreshTokenTTL),
+	}
+	if err := u.storer.CreateRefreshToken(gr.Ctx, rt); err != nil {
+		return Tokens{}, fmt.Errorf("createrefreshtoken: %w", err)
+	}
+
+	return Tokens{
+		Token:        tkn,
+		ExpiresAt:    now.Add(tokenTTL),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// newToken returns a random, URL safe token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash a token is stored and looked up by.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hasRole reports whether the claims carry role, which may also be a
+// permission.
+func hasRole(claims auth.Claims, role string) bool {
+	for _, r := range claims.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokens holds the credentials issued when a session is started or renewed.
+type Tokens struct {
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken"`
+}
+
+// RefreshTokenRequest is the request object for UserService.RefreshToken.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r RefreshTokenRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "refreshToken", r.RefreshToken)
+	checkLength(&fe, "refreshToken", r.RefreshToken, maxTokenLength)
+	return fe.Err()
+}
+
+// RefreshTokenResponse is the response object for UserService.RefreshToken.
+type RefreshTokenResponse struct {
+	Tokens
+	errs.Fault
+}
+
+// LogoutRequest is the request object for UserService.Logout.
+type LogoutRequest struct{}
+
+// LogoutResponse is the response object for UserService.Logout.
+type LogoutResponse struct {
+	errs.Fault
+}
+
+// RevokeAllSessionsRequest is the request object for
+// UserService.RevokeAllSessions. An empty UserID revokes the caller's own
+// sessions.
+type RevokeAllSessionsRequest struct {
+	UserID string `json:"userId"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r RevokeAllSessionsRequest) Validate() error {
+	var fe errs.FieldErrors
+	if r.UserID != "" {
+		checkID(&fe, "userId", r.UserID)
+	}
+	return fe.Err()
+}
+
+// RevokeAllSessionsResponse is the response object for
+// UserService.RevokeAllSessions.
+type RevokeAllSessionsResponse struct {
+	errs.Fault
+}
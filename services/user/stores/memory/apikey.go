@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreateAPIKey inserts a new API key into the store.
+func (s *Store) CreateAPIKey(ctx context.Context, ak user.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ak.Scopes = append([]string(nil), ak.Scopes...)
+	s.apiKeys[ak.Hash] = ak
+	return nil
+}
+
+// QueryAPIKey queries an API key by its hash.
+func (s *Store) QueryAPIKey(ctx context.Context, hash string) (user.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ak, exists := s.apiKeys[hash]
+	if !exists {
+		return user.APIKey{}, user.ErrNotFound
+	}
+	ak.Scopes = append([]string(nil), ak.Scopes...)
+	return ak, nil
+}
+
+// QueryAPIKeys queries the API keys of a user, newest first.
+func (s *Store) QueryAPIKeys(ctx context.Context, userID uuid.UUID) ([]user.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []user.APIKey{}
+	for _, ak := range s.apiKeys {
+		if ak.UserID == userID {
+			ak.Scopes = append([]string(nil), ak.Scopes...)
+			keys = append(keys, ak)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].DateCreated.After(keys[j].DateCreated) })
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of a user. It reports false when the user
+// has no such key.
+func (s *Store) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, ak := range s.apiKeys {
+		if ak.ID != id || ak.UserID != userID {
+			continue
+		}
+		if ak.DateRevoked == nil {
+			revoked := now.UTC()
+			ak.DateRevoked = &revoked
+			s.apiKeys[hash] = ak
+		}
+		return true, nil
+	}
+	return false, nil
+}
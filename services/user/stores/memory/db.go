@@ -0,0 +1,221 @@
+// Package memory implements user.Storer in process, so the user service can
+// run in tests and local development without ArangoDB. It follows the
+// semantics of the ArangoDB store, including its errors and versions.
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	log     *zap.SugaredLogger
+	mu      sync.RWMutex
+	rev     int64
+	users   map[uuid.UUID]user.User
+	tokens  map[string]user.RefreshToken
+	resets  map[string]user.PasswordReset
+	apiKeys map[string]user.APIKey
+	roles   map[string]user.RoleDefinition
+}
+
+// NewStore constructs an empty store holding usrs.
+func NewStore(log *zap.SugaredLogger, usrs ...user.User) *Store {
+	s := Store{
+		log:     log,
+		users:   map[uuid.UUID]user.User{},
+		tokens:  map[string]user.RefreshToken{},
+		resets:  map[string]user.PasswordReset{},
+		apiKeys: map[string]user.APIKey{},
+		roles:   map[string]user.RoleDefinition{},
+	}
+
+	for _, usr := range usrs {
+		usr.Version = s.nextRev()
+		s.users[usr.ID] = cloneUser(usr)
+	}
+
+	return &s
+}
+
+// Create inserts a new user into the store.
+func (s *Store) Create(ctx context.Context, usr user.User) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[usr.ID]; exists {
+		return user.User{}, user.ErrUniqueEmail
+	}
+	if s.emailTaken(usr.Email.Address, usr.ID) {
+		return user.User{}, user.ErrUniqueEmail
+	}
+
+	usr.Version = s.nextRev()
+	s.users[usr.ID] = cloneUser(usr)
+
+	return cloneUser(usr), nil
+}
+
+// QueryByID queries a user by id.
+func (s *Store) QueryByID(ctx context.Context, id string) (user.User, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return user.User{}, user.ErrNotFound
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usr, exists := s.users[uid]
+	if !exists || usr.DateDeleted != nil {
+		return user.User{}, user.ErrNotFound
+	}
+	return cloneUser(usr), nil
+}
+
+// QueryByEmail queries a user by email.
+func (s *Store) QueryByEmail(ctx context.Context, email string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, usr := range s.users {
+		if usr.Email.Address == email && usr.DateDeleted == nil {
+			return cloneUser(usr), nil
+		}
+	}
+	return user.User{}, user.ErrNotFound
+}
+
+// Query retrieves a page of users matching filter, ordered by orderBy.
+func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy user.OrderBy, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	less, err := lessFunc(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	usrs := s.filtered(filter)
+	s.mu.RUnlock()
+
+	// Sorting on the id last keeps pages stable when the ordered field
+	// holds duplicates.
+	sort.Slice(usrs, func(i, j int) bool {
+		if less(usrs[i], usrs[j]) {
+			return true
+		}
+		if less(usrs[j], usrs[i]) {
+			return false
+		}
+		return usrs[i].ID.String() < usrs[j].ID.String()
+	})
+
+	offset := (pageNumber - 1) * rowsPerPage
+	if offset >= len(usrs) {
+		return []user.User{}, nil
+	}
+	end := offset + rowsPerPage
+	if end > len(usrs) {
+		end = len(usrs)
+	}
+
+	return usrs[offset:end], nil
+}
+
+// Count returns the total number of users matching filter.
+func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.filtered(filter)), nil
+}
+
+// Update replaces the stored fields of a user. A user carrying a Version is
+// only updated if it is still stored at that version.
+func (s *Store) Update(ctx context.Context, usr user.User) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, exists := s.users[usr.ID]
+	switch {
+	case !exists:
+		return user.User{}, user.ErrNotFound
+	case s.emailTaken(usr.Email.Address, usr.ID):
+		return user.User{}, user.ErrUniqueEmail
+	case usr.Version != "" && usr.Version != cur.Version:
+		return user.User{}, user.ErrVersionConflict
+	}
+
+	usr.Version = s.nextRev()
+	s.users[usr.ID] = cloneUser(usr)
+
+	return cloneUser(usr), nil
+}
+
+// RecordFailedLogin counts a failed login of a user at now and locks the
+// account once too many have failed in a row.
+func (s *Store) RecordFailedLogin(ctx context.Context, id string, now time.Time) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, err := s.atVersion(id, "")
+	if err != nil {
+		return user.User{}, err
+	}
+	if usr.DateDeleted != nil {
+		return user.User{}, user.ErrNotFound
+	}
+
+	usr.FailedLogins++
+	if until := user.Lockout(usr.FailedLogins, now); !until.IsZero() {
+		usr.LockedUntil = until
+	}
+	usr.Version = s.nextRev()
+	s.users[usr.ID] = usr
+
+	return cloneUser(usr), nil
+}
+
+// emailTaken reports whether a user other than id, deleted or not, has
+// email. Callers must hold the lock.
+func (s *Store) emailTaken(email string, id uuid.UUID) bool {
+	for _, usr := range s.users {
+		if usr.ID != id && usr.Email.Address == email {
+			return true
+		}
+	}
+	return false
+}
+
+// nextRev returns a version no document of the store had before. Callers
+// must hold the lock.
+func (s *Store) nextRev() string {
+	s.rev++
+	return strconv.FormatInt(s.rev, 36)
+}
+
+// cloneUser copies usr so callers cannot change what is stored through the
+// slices and maps it shares.
+func cloneUser(usr user.User) user.User {
+	usr.Roles = append([]user.Role(nil), usr.Roles...)
+	usr.PasswordHash = append([]byte(nil), usr.PasswordHash...)
+	usr.RecoveryCodes = append([]string(nil), usr.RecoveryCodes...)
+	if usr.Preferences != nil {
+		prefs := make(map[string]string, len(usr.Preferences))
+		for k, v := range usr.Preferences {
+			prefs[k] = v
+		}
+		usr.Preferences = prefs
+	}
+	if usr.DateDeleted != nil {
+		deleted := *usr.DateDeleted
+		usr.DateDeleted = &deleted
+	}
+	return usr
+}
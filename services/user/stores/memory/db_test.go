@@ -0,0 +1,13 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/kjvonly/service/services/user/stores/memory"
+	"github.com/kjvonly/service/services/user/stores/storetest"
+	"go.uber.org/zap"
+)
+
+func Test_Store(t *testing.T) {
+	storetest.Run(t, memory.NewStore(zap.NewNop().Sugar()))
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+)
+
+// Delete marks a user deleted, provided it is still at version.
+func (s *Store) Delete(ctx context.Context, id string, version string, now time.Time) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, err := s.atVersion(id, version)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	deleted := now.UTC()
+	usr.DateDeleted = &deleted
+	usr.Version = s.nextRev()
+	s.users[usr.ID] = usr
+
+	return cloneUser(usr), nil
+}
+
+// Restore clears the deletion of a user, provided it is still at version.
+func (s *Store) Restore(ctx context.Context, id string, version string) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, err := s.atVersion(id, "")
+	switch {
+	case err != nil:
+		return user.User{}, err
+	case usr.DateDeleted == nil:
+		return user.User{}, user.ErrUserNotDeleted
+	case version != usr.Version:
+		return user.User{}, user.ErrVersionConflict
+	}
+
+	usr.DateDeleted = nil
+	usr.Version = s.nextRev()
+	s.users[usr.ID] = usr
+
+	return cloneUser(usr), nil
+}
+
+// Purge removes a deleted user from the store, provided it is still at
+// version.
+func (s *Store) Purge(ctx context.Context, id string, version string) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, err := s.atVersion(id, "")
+	switch {
+	case err != nil:
+		return user.User{}, err
+	case usr.DateDeleted == nil:
+		return user.User{}, user.ErrUserNotDeleted
+	case version != usr.Version:
+		return user.User{}, user.ErrVersionConflict
+	}
+
+	delete(s.users, usr.ID)
+
+	return usr, nil
+}
+
+// PurgeDeleted removes the users deleted before the given time, returning
+// how many were removed.
+func (s *Store) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for id, usr := range s.users {
+		if usr.DateDeleted != nil && usr.DateDeleted.Before(before) {
+			delete(s.users, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// atVersion returns the user with id, deleted or not, provided it is still
+// at version. Callers must hold the lock.
+func (s *Store) atVersion(id string, version string) (user.User, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return user.User{}, user.ErrNotFound
+	}
+
+	usr, exists := s.users[uid]
+	switch {
+	case !exists:
+		return user.User{}, user.ErrNotFound
+	case version != "" && version != usr.Version:
+		return user.User{}, user.ErrVersionConflict
+	}
+	return usr, nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// filtered returns copies of the users matching filter. Callers must hold
+// the lock.
+func (s *Store) filtered(filter user.QueryFilter) []user.User {
+	usrs := []user.User{}
+	for _, usr := range s.users {
+		if matches(usr, filter) {
+			usrs = append(usrs, cloneUser(usr))
+		}
+	}
+	return usrs
+}
+
+// matches reports whether usr holds every field set in filter.
+func matches(usr user.User, filter user.QueryFilter) bool {
+	if filter.Name != nil && !strings.Contains(strings.ToLower(usr.Name), strings.ToLower(*filter.Name)) {
+		return false
+	}
+
+	if filter.Email != nil && usr.Email.Address != *filter.Email {
+		return false
+	}
+
+	if filter.Role != nil {
+		held := false
+		for _, role := range usr.Roles {
+			if role.Name() == *filter.Role {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+
+	if filter.Department != nil && usr.Department != *filter.Department {
+		return false
+	}
+
+	if filter.Enabled != nil && usr.Enabled != *filter.Enabled {
+		return false
+	}
+
+	if filter.StartCreatedDate != nil && usr.DateCreated.Before(*filter.StartCreatedDate) {
+		return false
+	}
+
+	if filter.EndCreatedDate != nil && usr.DateCreated.After(*filter.EndCreatedDate) {
+		return false
+	}
+
+	deleted := filter.Deleted != nil && *filter.Deleted
+	return (usr.DateDeleted != nil) == deleted
+}
+
+// lessFunc returns the function ordering users by orderBy.
+func lessFunc(orderBy user.OrderBy) (func(a user.User, b user.User) bool, error) {
+	var less func(a user.User, b user.User) bool
+
+	switch orderBy.Field {
+	case user.OrderByName:
+		less = func(a, b user.User) bool { return a.Name < b.Name }
+	case user.OrderByEmail:
+		less = func(a, b user.User) bool { return a.Email.Address < b.Email.Address }
+	case user.OrderByRoles:
+		less = func(a, b user.User) bool { return rolesLess(a.Roles, b.Roles) }
+	case user.OrderByDepartment:
+		less = func(a, b user.User) bool { return a.Department < b.Department }
+	case user.OrderByEnabled:
+		less = func(a, b user.User) bool { return !a.Enabled && b.Enabled }
+	case user.OrderByDateCreated:
+		less = func(a, b user.User) bool { return a.DateCreated.Before(b.DateCreated) }
+	default:
+		return nil, fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	switch orderBy.Direction {
+	case user.ASC:
+		return less, nil
+	case user.DESC:
+		return func(a, b user.User) bool { return less(b, a) }, nil
+	}
+	return nil, fmt.Errorf("direction %q does not exist", orderBy.Direction)
+}
+
+// rolesLess compares roles element by element as ArangoDB compares arrays.
+func rolesLess(a []user.Role, b []user.Role) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Name() != b[i].Name() {
+			return a[i].Name() < b[i].Name()
+		}
+	}
+	return len(a) < len(b)
+}
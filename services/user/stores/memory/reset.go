@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreatePasswordReset inserts a new password reset token into the store.
+func (s *Store) CreatePasswordReset(ctx context.Context, pr user.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resets[pr.Hash] = pr
+	return nil
+}
+
+// QueryPasswordReset queries a password reset token by its hash.
+func (s *Store) QueryPasswordReset(ctx context.Context, hash string) (user.PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pr, exists := s.resets[hash]
+	if !exists {
+		return user.PasswordReset{}, user.ErrNotFound
+	}
+	return pr, nil
+}
+
+// UsePasswordReset marks a password reset token as used. It reports false
+// when the token had already been used.
+func (s *Store) UsePasswordReset(ctx context.Context, hash string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, exists := s.resets[hash]
+	if !exists || pr.DateUsed != nil {
+		return false, nil
+	}
+
+	used := now.UTC()
+	pr.DateUsed = &used
+	s.resets[hash] = pr
+
+	return true, nil
+}
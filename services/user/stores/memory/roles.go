@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreateRole inserts a new role into the store.
+func (s *Store) CreateRole(ctx context.Context, rd user.RoleDefinition) (user.RoleDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.roles[rd.Name]; exists {
+		return user.RoleDefinition{}, user.ErrRoleExists
+	}
+
+	rd.Permissions = append([]string(nil), rd.Permissions...)
+	rd.Version = s.nextRev()
+	s.roles[rd.Name] = rd
+
+	return rd, nil
+}
+
+// QueryRole queries a role by name.
+func (s *Store) QueryRole(ctx context.Context, name string) (user.RoleDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rd, exists := s.roles[name]
+	if !exists {
+		return user.RoleDefinition{}, user.ErrRoleNotFound
+	}
+	rd.Permissions = append([]string(nil), rd.Permissions...)
+	return rd, nil
+}
+
+// QueryRoles queries every stored role ordered by name.
+func (s *Store) QueryRoles(ctx context.Context) ([]user.RoleDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := []user.RoleDefinition{}
+	for _, rd := range s.roles {
+		rd.Permissions = append([]string(nil), rd.Permissions...)
+		roles = append(roles, rd)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	return roles, nil
+}
+
+// UpdateRole replaces the stored fields of a role, provided it is still
+// stored at its Version.
+func (s *Store) UpdateRole(ctx context.Context, rd user.RoleDefinition) (user.RoleDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, exists := s.roles[rd.Name]
+	switch {
+	case !exists:
+		return user.RoleDefinition{}, user.ErrRoleNotFound
+	case rd.Version != cur.Version:
+		return user.RoleDefinition{}, user.ErrVersionConflict
+	}
+
+	rd.Permissions = append([]string(nil), rd.Permissions...)
+	rd.Version = s.nextRev()
+	s.roles[rd.Name] = rd
+
+	return rd, nil
+}
+
+// DeleteRole removes a role from the store, provided it is still stored at
+// version.
+func (s *Store) DeleteRole(ctx context.Context, name string, version string) (user.RoleDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rd, exists := s.roles[name]
+	switch {
+	case !exists:
+		return user.RoleDefinition{}, user.ErrRoleNotFound
+	case version != rd.Version:
+		return user.RoleDefinition{}, user.ErrVersionConflict
+	}
+
+	delete(s.roles, name)
+
+	return rd, nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreateRefreshToken inserts a new refresh token into the store.
+func (s *Store) CreateRefreshToken(ctx context.Context, rt user.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[rt.Hash] = rt
+	return nil
+}
+
+// QueryRefreshToken queries a refresh token by its hash.
+func (s *Store) QueryRefreshToken(ctx context.Context, hash string) (user.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rt, exists := s.tokens[hash]
+	if !exists {
+		return user.RefreshToken{}, user.ErrNotFound
+	}
+	return rt, nil
+}
+
+// UseRefreshToken marks a refresh token as used. It reports false when the
+// token had already been used.
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, exists := s.tokens[hash]
+	if !exists || rt.DateUsed != nil {
+		return false, nil
+	}
+
+	used := now.UTC()
+	rt.DateUsed = &used
+	s.tokens[hash] = rt
+
+	return true, nil
+}
+
+// RevokeSession revokes every refresh token of a session.
+func (s *Store) RevokeSession(ctx context.Context, sessionID uuid.UUID, now time.Time) error {
+	s.revokeTokens(now, func(rt user.RefreshToken) bool {
+		return rt.SessionID == sessionID
+	})
+	return nil
+}
+
+// RevokeUserSessions revokes every refresh token of a user.
+func (s *Store) RevokeUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	s.revokeTokens(now, func(rt user.RefreshToken) bool {
+		return rt.UserID == userID
+	})
+	return nil
+}
+
+// RevokeOtherSessions revokes every refresh token of a user except those of
+// the session kept.
+func (s *Store) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keep uuid.UUID, now time.Time) error {
+	s.revokeTokens(now, func(rt user.RefreshToken) bool {
+		return rt.UserID == userID && rt.SessionID != keep
+	})
+	return nil
+}
+
+// SessionRevoked reports whether a session has been revoked.
+func (s *Store) SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rt := range s.tokens {
+		if rt.SessionID == sessionID && rt.DateRevoked != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// revokeTokens revokes the refresh tokens not yet revoked that match.
+func (s *Store) revokeTokens(now time.Time, match func(rt user.RefreshToken) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revoked := now.UTC()
+	for hash, rt := range s.tokens {
+		if rt.DateRevoked == nil && match(rt) {
+			rt.DateRevoked = &revoked
+			s.tokens[hash] = rt
+		}
+	}
+}
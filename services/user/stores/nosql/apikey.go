@@ -0,0 +1,94 @@
+package nosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+	"go.uber.org/zap"
+)
+
+// ensureAPIKeyIndexes creates the indexes API keys are looked up by, and lets
+// the database drop API keys once they expire.
+func ensureAPIKeyIndexes(ctx context.Context, log *zap.SugaredLogger, col driver.Collection) {
+	if _, _, err := col.EnsurePersistentIndex(ctx, []string{"user_id"}, nil); err != nil {
+		log.Panicf("error creating user index: %s", err)
+	}
+	if _, _, err := col.EnsureTTLIndex(ctx, "expires_at", 0, nil); err != nil {
+		log.Panicf("error creating expiry index: %s", err)
+	}
+}
+
+// CreateAPIKey inserts a new API key into the database.
+func (s *Store) CreateAPIKey(ctx context.Context, ak user.APIKey) error {
+	_, err := s.apiKeys.CreateDocument(ctx, toDBAPIKey(ak))
+	return dbError(err)
+}
+
+// QueryAPIKey queries an API key by its hash.
+func (s *Store) QueryAPIKey(ctx context.Context, hash string) (user.APIKey, error) {
+	var result dbAPIKey
+	if _, err := s.apiKeys.ReadDocument(ctx, hash, &result); err != nil {
+		if driver.IsNotFound(err) {
+			return user.APIKey{}, ErrNotFound
+		}
+		return user.APIKey{}, dbError(err)
+	}
+	return toCoreAPIKey(result), nil
+}
+
+// QueryAPIKeys queries the API keys of a user, newest first.
+func (s *Store) QueryAPIKeys(ctx context.Context, userID uuid.UUID) ([]user.APIKey, error) {
+	query := `FOR k IN @@coll
+	FILTER k.user_id == @user_id
+	SORT k.date_created DESC
+	RETURN k`
+
+	bindvars := map[string]interface{}{
+		"@coll":   apiKeyCollection,
+		"user_id": userID.String(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer c.Close()
+
+	keys := []user.APIKey{}
+	for c.HasMore() {
+		var dbAK dbAPIKey
+		if _, err := c.ReadDocument(ctx, &dbAK); err != nil {
+			return nil, dbError(err)
+		}
+		keys = append(keys, toCoreAPIKey(dbAK))
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of a user. It reports false when the user
+// has no such key.
+func (s *Store) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID, now time.Time) (bool, error) {
+	query := `FOR k IN @@coll
+	FILTER k.id == @id AND k.user_id == @user_id
+	UPDATE k WITH { date_revoked: k.date_revoked == null ? @now : k.date_revoked } IN @@coll
+	RETURN NEW._key`
+
+	bindvars := map[string]interface{}{
+		"@coll":   apiKeyCollection,
+		"id":      id.String(),
+		"user_id": userID.String(),
+		"now":     now.UTC(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return false, dbError(err)
+	}
+	defer c.Close()
+
+	return c.HasMore(), nil
+}
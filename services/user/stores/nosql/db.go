@@ -3,106 +3,304 @@ package nosql
 import (
 	"context"
 	"errors"
-	"fmt"
-	"net/mail"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/arangodb/go-driver"
+	"github.com/kjvonly/service/services/errs"
 	"github.com/kjvonly/service/services/user"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
-const collectionName = "users"
+const (
+	collectionName         = "users"
+	refreshTokenCollection = "refresh_tokens"
+	resetCollection        = "password_resets"
+	apiKeyCollection       = "api_keys"
+	roleCollection         = "roles"
+)
 
 var (
-	ErrNotFound              = errors.New("user not found")
-	ErrUniqueEmail           = errors.New("email is not unique")
-	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrNotFound              = user.ErrNotFound
+	ErrUniqueEmail           = user.ErrUniqueEmail
+	ErrAuthenticationFailure = user.ErrAuthenticationFailure
+	ErrVersionConflict       = user.ErrVersionConflict
 )
 
 type Store struct {
-	db  driver.Database
-	col driver.Collection
-	log *zap.SugaredLogger
+	db      driver.Database
+	col     driver.Collection
+	tokens  driver.Collection
+	resets  driver.Collection
+	apiKeys driver.Collection
+	roles   driver.Collection
+	log     *zap.SugaredLogger
 }
 
 // NewStore constructs the api for data access.
 func NewStore(log *zap.SugaredLogger, db driver.Database) *Store {
-	col, err := db.Collection(context.Background(), "users")
+	ctx := context.Background()
+
+	col, err := db.Collection(ctx, collectionName)
 	if err != nil {
 		log.Panicf("error accessing collection: %s", err)
 	}
-	return &Store{
-		log: log,
-		db:  db,
-		col: col,
+	if err := ensureUserIndexes(ctx, col); err != nil {
+		log.Panicf("error creating user indexes: %s", err)
 	}
-}
 
-// Delete deletes a user from the database
-func (s *Store) Delete(ctx context.Context, email mail.Address) (user.User, error) {
-	var result dbUser
-	ctx = driver.WithReturnOld(ctx, &result)
-	_, err := s.col.RemoveDocument(ctx, email.Address)
-	return toCoreUser(result), err
+	tokens, err := db.Collection(ctx, refreshTokenCollection)
+	if err != nil {
+		log.Panicf("error accessing collection: %s", err)
+	}
+	ensureRefreshTokenIndexes(ctx, log, tokens)
+
+	resets, err := db.Collection(ctx, resetCollection)
+	if err != nil {
+		log.Panicf("error accessing collection: %s", err)
+	}
+	if _, _, err := resets.EnsureTTLIndex(ctx, "expires_at", 0, nil); err != nil {
+		log.Panicf("error creating expiry index: %s", err)
+	}
+
+	apiKeys, err := db.Collection(ctx, apiKeyCollection)
+	if err != nil {
+		log.Panicf("error accessing collection: %s", err)
+	}
+	ensureAPIKeyIndexes(ctx, log, apiKeys)
+
+	roles, err := db.Collection(ctx, roleCollection)
+	if err != nil {
+		log.Panicf("error accessing collection: %s", err)
+	}
+
+	return &Store{
+		log:     log,
+		db:      db,
+		col:     col,
+		tokens:  tokens,
+		resets:  resets,
+		apiKeys: apiKeys,
+		roles:   roles,
+	}
 }
 
 // Create inserts a new user into the database.
 func (s *Store) Create(ctx context.Context, usr user.User) (user.User, error) {
 	var result dbUser
 	ctx = driver.WithReturnNew(ctx, &result)
-	_, err := s.col.CreateDocument(ctx, toDBUser(usr))
-	return toCoreUser(result), err
+	if _, err := s.col.CreateDocument(ctx, toDBUser(usr)); err != nil {
+		if driver.IsConflict(err) {
+			return user.User{}, ErrUniqueEmail
+		}
+		return user.User{}, dbError(err)
+	}
+	return toCoreUser(result), nil
 }
 
 // QueryById queries a user by id.
 func (s *Store) QueryByID(ctx context.Context, id string) (user.User, error) {
 	var result dbUser
+	if _, err := s.col.ReadDocument(ctx, id, &result); err != nil {
+		if driver.IsNotFound(err) {
+			return user.User{}, ErrNotFound
+		}
+		return user.User{}, dbError(err)
+	}
+	if result.DateDeleted != nil {
+		return user.User{}, ErrNotFound
+	}
+	return toCoreUser(result), nil
+}
+
+// QueryById queries a user by email.
+func (s *Store) QueryByEmail(ctx context.Context, email string) (user.User, error) {
 	query := `FOR u IN @@coll
-	FILTER u.user_id == @id
+	FILTER u.email == @email AND u.date_deleted == null
 	LIMIT 1
 	RETURN u`
 
 	bindvars := map[string]interface{}{
 		"@coll": collectionName,
-		"id":    id,
+		"email": email,
 	}
 
 	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
 	defer c.Close()
+
+	var result dbUser
+	if _, err := c.ReadDocument(ctx, &result); err != nil {
+		if driver.IsNoMoreDocuments(err) {
+			return user.User{}, ErrNotFound
+		}
+		return user.User{}, dbError(err)
+	}
+	return toCoreUser(result), nil
+}
+
+// Query retrieves a page of users matching filter, ordered by orderBy.
+func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy user.OrderBy, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	bindvars := map[string]interface{}{
+		"@coll":         collectionName,
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("FOR u IN @@coll")
+	applyFilter(filter, bindvars, &buf)
+
+	sort, err := orderByClause(orderBy)
 	if err != nil {
-		return user.User{}, err
+		return nil, dbError(err)
 	}
-	_, err = c.ReadDocument(ctx, &result)
-	return toCoreUser(result), err
+
+	// Sorting on the key last keeps pages stable when the ordered field
+	// holds duplicates.
+	buf.WriteString("\n\tSORT " + sort + ", u._key ASC")
+	buf.WriteString("\n\tLIMIT @offset, @rows_per_page")
+	buf.WriteString("\n\tRETURN u")
+
+	c, err := s.db.Query(ctx, buf.String(), bindvars)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer c.Close()
+
+	var dbUsrs []dbUser
+	for c.HasMore() {
+		var result dbUser
+		if _, err := c.ReadDocument(ctx, &result); err != nil {
+			return nil, dbError(err)
+		}
+		dbUsrs = append(dbUsrs, result)
+	}
+
+	return toCoreUserSlice(dbUsrs), nil
 }
 
-// QueryById queries a user by email.
-func (s *Store) QueryByEmail(ctx context.Context, email string) (user.User, error) {
-	var result dbUser
-	_, err := s.col.ReadDocument(ctx, email, &result)
-	return toCoreUser(result), err
+// Count returns the total number of users matching filter.
+func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error) {
+	bindvars := map[string]interface{}{
+		"@coll": collectionName,
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("FOR u IN @@coll")
+	applyFilter(filter, bindvars, &buf)
+	buf.WriteString("\n\tCOLLECT WITH COUNT INTO count")
+	buf.WriteString("\n\tRETURN count")
+
+	c, err := s.db.Query(ctx, buf.String(), bindvars)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	defer c.Close()
+
+	var count int
+	if _, err := c.ReadDocument(ctx, &count); err != nil {
+		return 0, dbError(err)
+	}
+
+	return count, nil
 }
 
-// Update updates a user by data.
-func (s *Store) Update(ctx context.Context, updateUser user.UpdateUser) (user.User, error) {
+// Update replaces the stored fields of a user. A user carrying a Version is
+// only updated if it is still stored at that version.
+func (s *Store) Update(ctx context.Context, usr user.User) (user.User, error) {
 	var result dbUser
 	ctx = driver.WithReturnNew(ctx, &result)
-	ctx = driver.WithKeepNull(ctx, false)
-	_, err := s.col.UpdateDocument(ctx, updateUser.Email.Address, updateUser)
-	return toCoreUser(result), err
-
+	if usr.Version != "" {
+		ctx = driver.WithRevision(ctx, usr.Version)
+	}
+	if _, err := s.col.UpdateDocument(ctx, usr.ID.String(), toDBUser(usr)); err != nil {
+		switch {
+		case driver.IsNotFound(err):
+			return user.User{}, ErrNotFound
+		case driver.IsConflict(err):
+			return user.User{}, ErrUniqueEmail
+		case driver.IsPreconditionFailed(err):
+			// Checked after IsConflict since it also matches unique
+			// constraint violations.
+			return user.User{}, ErrVersionConflict
+		}
+		return user.User{}, dbError(err)
+	}
+	return toCoreUser(result), nil
 }
 
-func (s *Store) Authenticate(ctx context.Context, email string, password string) (user.User, error) {
-	usr, err := s.QueryByEmail(ctx, email)
+// RecordFailedLogin counts a failed login of a user at now and locks the
+// account once too many have failed in a row. The count is incremented by
+// the database inside a transaction holding the users collection, so
+// concurrent failures are all counted.
+func (s *Store) RecordFailedLogin(ctx context.Context, id string, now time.Time) (user.User, error) {
+	tid, err := s.db.BeginTransaction(ctx, driver.TransactionCollections{Exclusive: []string{collectionName}}, nil)
 	if err != nil {
-		return user.User{}, fmt.Errorf("query: email[%s]: %w", email, err)
+		return user.User{}, dbError(err)
 	}
+	committed := false
+	defer func() {
+		if !committed {
+			s.db.AbortTransaction(ctx, tid, nil)
+		}
+	}()
+	tctx := driver.WithTransactionID(ctx, tid)
+
+	query := `FOR u IN @@coll
+	FILTER u._key == @key AND u.date_deleted == null
+	UPDATE u WITH { failed_logins: u.failed_logins + 1 } IN @@coll
+	RETURN NEW`
 
-	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
-		return user.User{}, fmt.Errorf("comparehashandpassword: %w", ErrAuthenticationFailure)
+	bindvars := map[string]interface{}{
+		"@coll": collectionName,
+		"key":   id,
 	}
 
-	return usr, nil
+	c, err := s.db.Query(tctx, query, bindvars)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
+	defer c.Close()
+
+	var result dbUser
+	if _, err := c.ReadDocument(tctx, &result); err != nil {
+		if driver.IsNoMoreDocuments(err) {
+			return user.User{}, ErrNotFound
+		}
+		return user.User{}, dbError(err)
+	}
+
+	if until := user.Lockout(result.FailedLogins, now); !until.IsZero() {
+		lock := map[string]interface{}{"locked_until": until.UTC()}
+		if _, err := s.col.UpdateDocument(driver.WithReturnNew(tctx, &result), id, lock); err != nil {
+			return user.User{}, dbError(err)
+		}
+	}
+
+	if err := s.db.CommitTransaction(ctx, tid, nil); err != nil {
+		return user.User{}, dbError(err)
+	}
+	committed = true
+
+	return toCoreUser(result), nil
+}
+
+// dbError returns err as an Unavailable error when ArangoDB could not be
+// reached or has no leader, so callers can tell an outage from a failed
+// query. Errors which already have a code are returned unchanged.
+func dbError(err error) error {
+	if errs.CodeOf(err) != errs.Internal {
+		return err
+	}
+
+	var netErr net.Error
+	if driver.IsResponse(err) || driver.IsArangoErrorWithCode(err, http.StatusServiceUnavailable) || errors.As(err, &netErr) {
+		return errs.Wrap(errs.Unavailable, err)
+	}
+	return err
 }
@@ -0,0 +1,113 @@
+package nosql_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"git.launchpad.net/~man4christ/+git/stem/data/nosql/dbtest"
+	"git.launchpad.net/~man4christ/+git/stem/docker"
+	"github.com/kjvonly/service/services/user/stores/nosql"
+	"github.com/kjvonly/service/services/user/stores/storetest"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Store(t *testing.T) {
+	b, _ := os.ReadFile("../../../../testdata/collections.txt")
+	cols := strings.Split(string(b), "\n")
+
+	test := dbtest.NewIntegration(t, c, "teststore", dbtest.Data{CollectionData: cols})
+	t.Cleanup(test.Teardown)
+
+	storetest.Run(t, nosql.NewStore(test.Log, test.DB))
+}
+
+func Test_MigrateUserKeys(t *testing.T) {
+	b, _ := os.ReadFile("../../../../testdata/collections.txt")
+	cols := strings.Split(string(b), "\n")
+
+	test := dbtest.NewIntegration(t, c, "testmigrate", dbtest.Data{CollectionData: cols})
+	t.Cleanup(test.Teardown)
+
+	ctx := context.Background()
+	col, err := test.DB.Collection(ctx, "users")
+	if err != nil {
+		t.Fatalf("collection: %s", err)
+	}
+
+	t.Log("Given the need to re-key users stored under their email.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen migrating legacy users twice.", testID)
+		{
+			legacy := []map[string]interface{}{
+				{"_key": "john@example.com", "user_id": "5cf37266-3473-4006-984f-9325122678b7", "name": "John"},
+				{"_key": "jane@example.com", "user_id": "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", "name": "Jane"},
+			}
+			if _, _, err := col.CreateDocuments(ctx, legacy); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould store the legacy users : %s.", dbtest.Failed, testID, err)
+			}
+
+			// An earlier run created Jane under her id but did not remove
+			// the legacy document.
+			partial := map[string]interface{}{"_key": "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", "email": "jane@example.com", "name": "Jane"}
+			if _, err := col.CreateDocument(ctx, partial); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould store the partly migrated user : %s.", dbtest.Failed, testID, err)
+			}
+
+			n, err := nosql.MigrateUserKeys(ctx, test.DB)
+			if err != nil || n != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould migrate the legacy users : got %d, %v.", dbtest.Failed, testID, n, err)
+			}
+			for _, key := range []string{"john@example.com", "jane@example.com"} {
+				if exists, err := col.DocumentExists(ctx, key); err != nil || exists {
+					t.Fatalf("\t%s\tTest %d:\tShould remove the legacy user %s : got %v, %v.", dbtest.Failed, testID, key, exists, err)
+				}
+			}
+			var john map[string]interface{}
+			if _, err := col.ReadDocument(ctx, "5cf37266-3473-4006-984f-9325122678b7", &john); err != nil || john["email"] != "john@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould key the user by id : got %v, %v.", dbtest.Failed, testID, john, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould migrate the legacy users.", dbtest.Success, testID)
+
+			if n, err := nosql.MigrateUserKeys(ctx, test.DB); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould leave migrated users alone : got %d, %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould leave migrated users alone.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a legacy user clashes on the email of another user.", testID)
+		{
+			legacy := map[string]interface{}{"_key": "john@example.com", "user_id": "0c3c8a5b-5b1e-4f5f-9a55-4d4b7f3e2c10", "name": "Other John"}
+			if _, err := col.CreateDocument(ctx, legacy); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould store the legacy user : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := nosql.MigrateUserKeys(ctx, test.DB); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail on the clash.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail on the clash.", dbtest.Success, testID)
+
+			if exists, err := col.DocumentExists(ctx, "john@example.com"); err != nil || !exists {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the legacy user : got %v, %v.", dbtest.Failed, testID, exists, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the legacy user.", dbtest.Success, testID)
+		}
+	}
+}
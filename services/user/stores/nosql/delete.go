@@ -0,0 +1,119 @@
+package nosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/kjvonly/service/services/user"
+)
+
+// ErrUserNotDeleted is returned when restoring or purging a user that has
+// not been deleted.
+var ErrUserNotDeleted = user.ErrUserNotDeleted
+
+// Delete marks a user deleted, provided it is still at version.
+func (s *Store) Delete(ctx context.Context, id string, version string, now time.Time) (user.User, error) {
+	patch := map[string]interface{}{
+		"date_deleted": now.UTC(),
+	}
+	return s.patch(ctx, id, version, patch)
+}
+
+// Restore clears the deletion of a user, provided it is still at version.
+func (s *Store) Restore(ctx context.Context, id string, version string) (user.User, error) {
+	if _, err := s.queryDeleted(ctx, id); err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	patch := map[string]interface{}{
+		"date_deleted": nil,
+	}
+	return s.patch(ctx, id, version, patch)
+}
+
+// Purge removes a deleted user from the database, provided it is still at
+// version.
+func (s *Store) Purge(ctx context.Context, id string, version string) (user.User, error) {
+	if _, err := s.queryDeleted(ctx, id); err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	var result dbUser
+	ctx = driver.WithReturnOld(ctx, &result)
+	ctx = driver.WithRevision(ctx, version)
+	if _, err := s.col.RemoveDocument(ctx, id); err != nil {
+		switch {
+		case driver.IsNotFound(err):
+			return user.User{}, ErrNotFound
+		case driver.IsPreconditionFailed(err):
+			return user.User{}, ErrVersionConflict
+		}
+		return user.User{}, dbError(err)
+	}
+	return toCoreUser(result), nil
+}
+
+// PurgeDeleted removes the users deleted before the given time, returning
+// how many were removed.
+func (s *Store) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	query := `FOR u IN @@coll
+	FILTER u.date_deleted != null AND DATE_TIMESTAMP(u.date_deleted) < @before
+	REMOVE u IN @@coll
+	RETURN OLD._key`
+
+	bindvars := map[string]interface{}{
+		"@coll":  collectionName,
+		"before": before.UTC().UnixMilli(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	defer c.Close()
+
+	var n int
+	for c.HasMore() {
+		var key string
+		if _, err := c.ReadDocument(ctx, &key); err != nil {
+			return n, dbError(err)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// queryDeleted queries a deleted user by id.
+func (s *Store) queryDeleted(ctx context.Context, id string) (user.User, error) {
+	var result dbUser
+	if _, err := s.col.ReadDocument(ctx, id, &result); err != nil {
+		if driver.IsNotFound(err) {
+			return user.User{}, ErrNotFound
+		}
+		return user.User{}, dbError(err)
+	}
+	if result.DateDeleted == nil {
+		return user.User{}, ErrUserNotDeleted
+	}
+	return toCoreUser(result), nil
+}
+
+// patch updates the given attributes of a user, provided it is still at
+// version.
+func (s *Store) patch(ctx context.Context, id string, version string, patch map[string]interface{}) (user.User, error) {
+	var result dbUser
+	ctx = driver.WithReturnNew(ctx, &result)
+	ctx = driver.WithRevision(ctx, version)
+	if _, err := s.col.UpdateDocument(ctx, id, patch); err != nil {
+		switch {
+		case driver.IsNotFound(err):
+			return user.User{}, ErrNotFound
+		case driver.IsPreconditionFailed(err):
+			return user.User{}, ErrVersionConflict
+		}
+		return user.User{}, dbError(err)
+	}
+	return toCoreUser(result), nil
+}
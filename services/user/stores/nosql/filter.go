@@ -0,0 +1,59 @@
+package nosql
+
+import (
+	"strings"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// applyFilter adds a FILTER statement to the AQL query for every field set
+// in filter, with the values passed as bind variables.
+func applyFilter(filter user.QueryFilter, bindvars map[string]interface{}, buf *strings.Builder) {
+	var wc []string
+
+	if filter.Name != nil {
+		bindvars["name"] = *filter.Name
+		wc = append(wc, "CONTAINS(LOWER(u.name), LOWER(@name))")
+	}
+
+	if filter.Email != nil {
+		bindvars["email"] = *filter.Email
+		wc = append(wc, "u.email == @email")
+	}
+
+	if filter.Role != nil {
+		bindvars["role"] = *filter.Role
+		wc = append(wc, "@role IN u.roles")
+	}
+
+	if filter.Department != nil {
+		bindvars["department"] = *filter.Department
+		wc = append(wc, "u.department.String == @department")
+	}
+
+	if filter.Enabled != nil {
+		bindvars["enabled"] = *filter.Enabled
+		wc = append(wc, "u.enabled == @enabled")
+	}
+
+	if filter.StartCreatedDate != nil {
+		bindvars["start_date_created"] = filter.StartCreatedDate.UTC().UnixMilli()
+		wc = append(wc, "DATE_TIMESTAMP(u.date_created) >= @start_date_created")
+	}
+
+	if filter.EndCreatedDate != nil {
+		bindvars["end_date_created"] = filter.EndCreatedDate.UTC().UnixMilli()
+		wc = append(wc, "DATE_TIMESTAMP(u.date_created) <= @end_date_created")
+	}
+
+	if filter.Deleted != nil && *filter.Deleted {
+		wc = append(wc, "u.date_deleted != null")
+	} else {
+		wc = append(wc, "u.date_deleted == null")
+	}
+
+	for _, w := range wc {
+		buf.WriteString("\n\tFILTER ")
+		buf.WriteString(w)
+	}
+}
@@ -0,0 +1,97 @@
+package nosql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arangodb/go-driver"
+)
+
+// ensureUserIndexes creates the unique index on the email of users, and the
+// index deleted users are purged by. The email index is sparse so documents
+// still keyed by email can be migrated with it in place.
+func ensureUserIndexes(ctx context.Context, col driver.Collection) error {
+	_, _, err := col.EnsurePersistentIndex(ctx, []string{"email"}, &driver.EnsurePersistentIndexOptions{
+		Name:   "users_email",
+		Unique: true,
+		Sparse: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _, err = col.EnsurePersistentIndex(ctx, []string{"date_deleted"}, &driver.EnsurePersistentIndexOptions{
+		Name:   "users_date_deleted",
+		Sparse: true,
+	})
+	return err
+}
+
+// MigrateUserKeys re-keys users stored under their email by their user id,
+// moving the email into its own attribute. Users already migrated are left
+// alone so it can be run any number of times. It returns the number of users
+// migrated.
+func MigrateUserKeys(ctx context.Context, db driver.Database) (int, error) {
+	col, err := db.Collection(ctx, collectionName)
+	if err != nil {
+		return 0, fmt.Errorf("collection: %w", err)
+	}
+
+	if err := ensureUserIndexes(ctx, col); err != nil {
+		return 0, fmt.Errorf("ensure user indexes: %w", err)
+	}
+
+	query := `FOR u IN @@coll
+	FILTER u.email == null AND u.user_id != null
+	RETURN u`
+
+	bindvars := map[string]interface{}{
+		"@coll": collectionName,
+	}
+
+	c, err := db.Query(ctx, query, bindvars)
+	if err != nil {
+		return 0, fmt.Errorf("query legacy users: %w", err)
+	}
+	defer c.Close()
+
+	var legacy []map[string]interface{}
+	for c.HasMore() {
+		var doc map[string]interface{}
+		if _, err := c.ReadDocument(ctx, &doc); err != nil {
+			return 0, fmt.Errorf("read legacy user: %w", err)
+		}
+		legacy = append(legacy, doc)
+	}
+
+	var migrated int
+	for _, doc := range legacy {
+		email, _ := doc["_key"].(string)
+		id, _ := doc["user_id"].(string)
+
+		for _, k := range []string{"_id", "_key", "_rev", "user_id"} {
+			delete(doc, k)
+		}
+		doc["_key"] = id
+		doc["email"] = email
+
+		// A user already keyed by the id means an earlier run created it but
+		// failed to remove the old one. Any other conflict, such as a clash
+		// on the email, keeps the old document.
+		exists, err := col.DocumentExists(ctx, id)
+		if err != nil {
+			return migrated, fmt.Errorf("user exists[%s]: %w", id, err)
+		}
+		if !exists {
+			if _, err := col.CreateDocument(ctx, doc); err != nil {
+				return migrated, fmt.Errorf("create user[%s]: %w", id, err)
+			}
+		}
+		if _, err := col.RemoveDocument(ctx, email); err != nil && !driver.IsNotFound(err) {
+			return migrated, fmt.Errorf("remove user[%s]: %w", email, err)
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
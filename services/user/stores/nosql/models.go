@@ -12,15 +12,27 @@ import (
 // dbUser represent the structure we need for moving data
 // between the app and the database.
 type dbUser struct {
-	ID           uuid.UUID      `json:"user_id"`
-	Name         string         `json:"name"`
-	Email        string         `json:"_key"`
-	Roles        []string       `json:"roles"`
-	PasswordHash []byte         `json:"password_hash"`
-	Enabled      bool           `json:"enabled"`
-	Department   sql.NullString `json:"department"`
-	DateCreated  time.Time      `json:"date_created"`
-	DateUpdated  time.Time      `json:"date_updated"`
+	ID                   uuid.UUID         `json:"_key"`
+	Name                 string            `json:"name"`
+	Email                string            `json:"email"`
+	EmailVerified        bool              `json:"email_verified"`
+	Roles                []string          `json:"roles"`
+	PasswordHash         []byte            `json:"password_hash"`
+	Enabled              bool              `json:"enabled"`
+	Department           sql.NullString    `json:"department"`
+	Preferences          map[string]string `json:"preferences"`
+	FailedLogins         int               `json:"failed_logins"`
+	LockedUntil          time.Time         `json:"locked_until"`
+	MFAEnabled           bool              `json:"mfa_enabled"`
+	MFASecret            string            `json:"mfa_secret"`
+	MFAPendingSecret     string            `json:"mfa_pending_secret"`
+	MFALastStep          int64             `json:"mfa_last_step"`
+	RecoveryCodes        []string          `json:"recovery_codes"`
+	DateVerificationSent time.Time         `json:"date_verification_sent"`
+	DateCreated          time.Time         `json:"date_created"`
+	DateUpdated          time.Time         `json:"date_updated"`
+	Version              string            `json:"_rev,omitempty"`
+	DateDeleted          *time.Time        `json:"date_deleted"`
 }
 
 func toDBUser(usr user.User) dbUser {
@@ -30,13 +42,29 @@ func toDBUser(usr user.User) dbUser {
 	}
 
 	return dbUser{
-		ID:           usr.ID,
-		Name:         usr.Name,
-		Email:        usr.Email.Address,
-		Roles:        roles,
-		PasswordHash: usr.PasswordHash,
-		DateCreated:  usr.DateCreated.UTC(),
-		DateUpdated:  usr.DateUpdated.UTC(),
+		ID:            usr.ID,
+		Name:          usr.Name,
+		Email:         usr.Email.Address,
+		EmailVerified: usr.EmailVerified,
+		Roles:         roles,
+		PasswordHash:  usr.PasswordHash,
+		Enabled:       usr.Enabled,
+		Department: sql.NullString{
+			String: usr.Department,
+			Valid:  usr.Department != "",
+		},
+		Preferences:          usr.Preferences,
+		FailedLogins:         usr.FailedLogins,
+		LockedUntil:          usr.LockedUntil.UTC(),
+		MFAEnabled:           usr.MFAEnabled,
+		MFASecret:            usr.MFASecret,
+		MFAPendingSecret:     usr.MFAPendingSecret,
+		MFALastStep:          usr.MFALastStep,
+		RecoveryCodes:        usr.RecoveryCodes,
+		DateVerificationSent: usr.DateVerificationSent.UTC(),
+		DateCreated:          usr.DateCreated.UTC(),
+		DateUpdated:          usr.DateUpdated.UTC(),
+		DateDeleted:          usr.DateDeleted,
 	}
 }
 
@@ -51,13 +79,27 @@ func toCoreUser(dbUsr dbUser) user.User {
 	}
 
 	usr := user.User{
-		ID:           dbUsr.ID,
-		Name:         dbUsr.Name,
-		Email:        addr,
-		Roles:        roles,
-		PasswordHash: dbUsr.PasswordHash,
-		DateCreated:  dbUsr.DateCreated.In(time.Local),
-		DateUpdated:  dbUsr.DateUpdated.In(time.Local),
+		ID:                   dbUsr.ID,
+		Name:                 dbUsr.Name,
+		Email:                addr,
+		EmailVerified:        dbUsr.EmailVerified,
+		Roles:                roles,
+		PasswordHash:         dbUsr.PasswordHash,
+		Department:           dbUsr.Department.String,
+		Preferences:          dbUsr.Preferences,
+		Enabled:              dbUsr.Enabled,
+		FailedLogins:         dbUsr.FailedLogins,
+		LockedUntil:          dbUsr.LockedUntil.In(time.Local),
+		MFAEnabled:           dbUsr.MFAEnabled,
+		MFASecret:            dbUsr.MFASecret,
+		MFAPendingSecret:     dbUsr.MFAPendingSecret,
+		MFALastStep:          dbUsr.MFALastStep,
+		RecoveryCodes:        dbUsr.RecoveryCodes,
+		DateVerificationSent: dbUsr.DateVerificationSent.In(time.Local),
+		DateCreated:          dbUsr.DateCreated.In(time.Local),
+		DateUpdated:          dbUsr.DateUpdated.In(time.Local),
+		Version:              dbUsr.Version,
+		DateDeleted:          dbUsr.DateDeleted,
 	}
 
 	return usr
@@ -70,3 +112,155 @@ func toCoreUserSlice(dbUsers []dbUser) []user.User {
 	}
 	return usrs
 }
+
+// dbRefreshToken represent the structure we need for moving refresh tokens
+// between the app and the database. ExpiresAt duplicates DateExpires as a
+// unix timestamp for the TTL index.
+type dbRefreshToken struct {
+	Hash        string     `json:"_key"`
+	SessionID   uuid.UUID  `json:"session_id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Device      string     `json:"device"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires time.Time  `json:"date_expires"`
+	ExpiresAt   int64      `json:"expires_at"`
+	DateUsed    *time.Time `json:"date_used"`
+	DateRevoked *time.Time `json:"date_revoked"`
+}
+
+func toDBRefreshToken(rt user.RefreshToken) dbRefreshToken {
+	return dbRefreshToken{
+		Hash:        rt.Hash,
+		SessionID:   rt.SessionID,
+		UserID:      rt.UserID,
+		Device:      rt.Device,
+		DateCreated: rt.DateCreated.UTC(),
+		DateExpires: rt.DateExpires.UTC(),
+		ExpiresAt:   rt.DateExpires.Unix(),
+		DateUsed:    rt.DateUsed,
+		DateRevoked: rt.DateRevoked,
+	}
+}
+
+func toCoreRefreshToken(dbRT dbRefreshToken) user.RefreshToken {
+	return user.RefreshToken{
+		Hash:        dbRT.Hash,
+		SessionID:   dbRT.SessionID,
+		UserID:      dbRT.UserID,
+		Device:      dbRT.Device,
+		DateCreated: dbRT.DateCreated.In(time.Local),
+		DateExpires: dbRT.DateExpires.In(time.Local),
+		DateUsed:    dbRT.DateUsed,
+		DateRevoked: dbRT.DateRevoked,
+	}
+}
+
+// dbPasswordReset represent the structure we need for moving password reset
+// tokens between the app and the database. ExpiresAt duplicates DateExpires
+// as a unix timestamp for the TTL index.
+type dbPasswordReset struct {
+	Hash        string     `json:"_key"`
+	UserID      uuid.UUID  `json:"user_id"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires time.Time  `json:"date_expires"`
+	ExpiresAt   int64      `json:"expires_at"`
+	DateUsed    *time.Time `json:"date_used"`
+}
+
+func toDBPasswordReset(pr user.PasswordReset) dbPasswordReset {
+	return dbPasswordReset{
+		Hash:        pr.Hash,
+		UserID:      pr.UserID,
+		DateCreated: pr.DateCreated.UTC(),
+		DateExpires: pr.DateExpires.UTC(),
+		ExpiresAt:   pr.DateExpires.Unix(),
+		DateUsed:    pr.DateUsed,
+	}
+}
+
+func toCorePasswordReset(dbPR dbPasswordReset) user.PasswordReset {
+	return user.PasswordReset{
+		Hash:        dbPR.Hash,
+		UserID:      dbPR.UserID,
+		DateCreated: dbPR.DateCreated.In(time.Local),
+		DateExpires: dbPR.DateExpires.In(time.Local),
+		DateUsed:    dbPR.DateUsed,
+	}
+}
+
+// dbAPIKey represent the structure we need for moving API keys between the
+// app and the database. ExpiresAt duplicates DateExpires as a unix timestamp
+// for the TTL index.
+type dbAPIKey struct {
+	Hash        string     `json:"_key"`
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Scopes      []string   `json:"scopes"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires time.Time  `json:"date_expires"`
+	ExpiresAt   int64      `json:"expires_at"`
+	DateRevoked *time.Time `json:"date_revoked"`
+}
+
+func toDBAPIKey(ak user.APIKey) dbAPIKey {
+	return dbAPIKey{
+		Hash:        ak.Hash,
+		ID:          ak.ID,
+		UserID:      ak.UserID,
+		Name:        ak.Name,
+		Prefix:      ak.Prefix,
+		Scopes:      ak.Scopes,
+		DateCreated: ak.DateCreated.UTC(),
+		DateExpires: ak.DateExpires.UTC(),
+		ExpiresAt:   ak.DateExpires.Unix(),
+		DateRevoked: ak.DateRevoked,
+	}
+}
+
+func toCoreAPIKey(dbAK dbAPIKey) user.APIKey {
+	return user.APIKey{
+		ID:          dbAK.ID,
+		UserID:      dbAK.UserID,
+		Name:        dbAK.Name,
+		Prefix:      dbAK.Prefix,
+		Hash:        dbAK.Hash,
+		Scopes:      dbAK.Scopes,
+		DateCreated: dbAK.DateCreated.In(time.Local),
+		DateExpires: dbAK.DateExpires.In(time.Local),
+		DateRevoked: dbAK.DateRevoked,
+	}
+}
+
+// dbRole represent the structure we need for moving roles between the app
+// and the database. Roles are keyed by name.
+type dbRole struct {
+	Name        string    `json:"_key"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+	Version     string    `json:"_rev,omitempty"`
+}
+
+func toDBRole(rd user.RoleDefinition) dbRole {
+	return dbRole{
+		Name:        rd.Name,
+		Description: rd.Description,
+		Permissions: rd.Permissions,
+		DateCreated: rd.DateCreated.UTC(),
+		DateUpdated: rd.DateUpdated.UTC(),
+	}
+}
+
+func toCoreRole(dbR dbRole) user.RoleDefinition {
+	return user.RoleDefinition{
+		Name:        dbR.Name,
+		Description: dbR.Description,
+		Permissions: dbR.Permissions,
+		DateCreated: dbR.DateCreated.In(time.Local),
+		DateUpdated: dbR.DateUpdated.In(time.Local),
+		Version:     dbR.Version,
+	}
+}
@@ -0,0 +1,33 @@
+package nosql
+
+import (
+	"fmt"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// orderByFields maps the fields users can be ordered by to the attributes
+// holding them in the database.
+var orderByFields = map[string]string{
+	user.OrderByName:        "u.name",
+	user.OrderByEmail:       "u.email",
+	user.OrderByRoles:       "u.roles",
+	user.OrderByDepartment:  "u.department.String",
+	user.OrderByEnabled:     "u.enabled",
+	user.OrderByDateCreated: "u.date_created",
+}
+
+// orderByClause returns the AQL SORT expression for orderBy. Only known
+// fields and directions are accepted since neither can be a bind variable.
+func orderByClause(orderBy user.OrderBy) (string, error) {
+	by, exists := orderByFields[orderBy.Field]
+	if !exists {
+		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	if orderBy.Direction != user.ASC && orderBy.Direction != user.DESC {
+		return "", fmt.Errorf("direction %q does not exist", orderBy.Direction)
+	}
+
+	return by + " " + orderBy.Direction, nil
+}
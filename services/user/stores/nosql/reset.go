@@ -0,0 +1,50 @@
+package nosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreatePasswordReset inserts a new password reset token into the database.
+func (s *Store) CreatePasswordReset(ctx context.Context, pr user.PasswordReset) error {
+	_, err := s.resets.CreateDocument(ctx, toDBPasswordReset(pr))
+	return dbError(err)
+}
+
+// QueryPasswordReset queries a password reset token by its hash.
+func (s *Store) QueryPasswordReset(ctx context.Context, hash string) (user.PasswordReset, error) {
+	var result dbPasswordReset
+	if _, err := s.resets.ReadDocument(ctx, hash, &result); err != nil {
+		if driver.IsNotFound(err) {
+			return user.PasswordReset{}, ErrNotFound
+		}
+		return user.PasswordReset{}, dbError(err)
+	}
+	return toCorePasswordReset(result), nil
+}
+
+// UsePasswordReset marks a password reset token as used. It reports false
+// when the token had already been used.
+func (s *Store) UsePasswordReset(ctx context.Context, hash string, now time.Time) (bool, error) {
+	query := `FOR r IN @@coll
+	FILTER r._key == @key AND r.date_used == null
+	UPDATE r WITH { date_used: @now } IN @@coll
+	RETURN NEW._key`
+
+	bindvars := map[string]interface{}{
+		"@coll": resetCollection,
+		"key":   hash,
+		"now":   now.UTC(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return false, dbError(err)
+	}
+	defer c.Close()
+
+	return c.HasMore(), nil
+}
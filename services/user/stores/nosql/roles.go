@@ -0,0 +1,100 @@
+package nosql
+
+import (
+	"context"
+
+	"github.com/arangodb/go-driver"
+	"github.com/kjvonly/service/services/user"
+)
+
+// ErrRoleNotFound is returned when a role is not stored.
+var ErrRoleNotFound = user.ErrRoleNotFound
+
+// CreateRole inserts a new role into the database.
+func (s *Store) CreateRole(ctx context.Context, rd user.RoleDefinition) (user.RoleDefinition, error) {
+	var result dbRole
+	ctx = driver.WithReturnNew(ctx, &result)
+	if _, err := s.roles.CreateDocument(ctx, toDBRole(rd)); err != nil {
+		if driver.IsConflict(err) {
+			return user.RoleDefinition{}, user.ErrRoleExists
+		}
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return toCoreRole(result), nil
+}
+
+// QueryRole queries a role by name.
+func (s *Store) QueryRole(ctx context.Context, name string) (user.RoleDefinition, error) {
+	var result dbRole
+	if _, err := s.roles.ReadDocument(ctx, name, &result); err != nil {
+		if driver.IsNotFound(err) {
+			return user.RoleDefinition{}, ErrRoleNotFound
+		}
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return toCoreRole(result), nil
+}
+
+// QueryRoles queries every stored role ordered by name.
+func (s *Store) QueryRoles(ctx context.Context) ([]user.RoleDefinition, error) {
+	query := `FOR r IN @@coll
+	SORT r._key ASC
+	RETURN r`
+
+	bindvars := map[string]interface{}{
+		"@coll": roleCollection,
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer c.Close()
+
+	roles := []user.RoleDefinition{}
+	for c.HasMore() {
+		var result dbRole
+		if _, err := c.ReadDocument(ctx, &result); err != nil {
+			return nil, dbError(err)
+		}
+		roles = append(roles, toCoreRole(result))
+	}
+
+	return roles, nil
+}
+
+// UpdateRole replaces the stored fields of a role, provided it is still
+// stored at its Version.
+func (s *Store) UpdateRole(ctx context.Context, rd user.RoleDefinition) (user.RoleDefinition, error) {
+	var result dbRole
+	ctx = driver.WithReturnNew(ctx, &result)
+	ctx = driver.WithRevision(ctx, rd.Version)
+	if _, err := s.roles.UpdateDocument(ctx, rd.Name, toDBRole(rd)); err != nil {
+		switch {
+		case driver.IsNotFound(err):
+			return user.RoleDefinition{}, ErrRoleNotFound
+		case driver.IsPreconditionFailed(err):
+			return user.RoleDefinition{}, ErrVersionConflict
+		}
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return toCoreRole(result), nil
+}
+
+// DeleteRole removes a role from the database, provided it is still stored
+// at version.
+func (s *Store) DeleteRole(ctx context.Context, name string, version string) (user.RoleDefinition, error) {
+	var result dbRole
+	ctx = driver.WithReturnOld(ctx, &result)
+	ctx = driver.WithRevision(ctx, version)
+	if _, err := s.roles.RemoveDocument(ctx, name); err != nil {
+		switch {
+		case driver.IsNotFound(err):
+			return user.RoleDefinition{}, ErrRoleNotFound
+		case driver.IsPreconditionFailed(err):
+			return user.RoleDefinition{}, ErrVersionConflict
+		}
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return toCoreRole(result), nil
+}
@@ -0,0 +1,146 @@
+package nosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/arangodb/go-driver"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+	"go.uber.org/zap"
+)
+
+// ensureRefreshTokenIndexes creates the indexes sessions are looked up by,
+// and lets the database drop refresh tokens once they expire.
+func ensureRefreshTokenIndexes(ctx context.Context, log *zap.SugaredLogger, col driver.Collection) {
+	if _, _, err := col.EnsurePersistentIndex(ctx, []string{"session_id"}, nil); err != nil {
+		log.Panicf("error creating session index: %s", err)
+	}
+	if _, _, err := col.EnsurePersistentIndex(ctx, []string{"user_id"}, nil); err != nil {
+		log.Panicf("error creating user index: %s", err)
+	}
+	if _, _, err := col.EnsureTTLIndex(ctx, "expires_at", 0, nil); err != nil {
+		log.Panicf("error creating expiry index: %s", err)
+	}
+}
+
+// CreateRefreshToken inserts a new refresh token into the database.
+func (s *Store) CreateRefreshToken(ctx context.Context, rt user.RefreshToken) error {
+	_, err := s.tokens.CreateDocument(ctx, toDBRefreshToken(rt))
+	return dbError(err)
+}
+
+// QueryRefreshToken queries a refresh token by its hash.
+func (s *Store) QueryRefreshToken(ctx context.Context, hash string) (user.RefreshToken, error) {
+	var result dbRefreshToken
+	if _, err := s.tokens.ReadDocument(ctx, hash, &result); err != nil {
+		if driver.IsNotFound(err) {
+			return user.RefreshToken{}, ErrNotFound
+		}
+		return user.RefreshToken{}, dbError(err)
+	}
+	return toCoreRefreshToken(result), nil
+}
+
+// UseRefreshToken marks a refresh token as used. It reports false when the
+// token had already been used.
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, now time.Time) (bool, error) {
+	query := `FOR t IN @@coll
+	FILTER t._key == @key AND t.date_used == null
+	UPDATE t WITH { date_used: @now } IN @@coll
+	RETURN NEW._key`
+
+	bindvars := map[string]interface{}{
+		"@coll": refreshTokenCollection,
+		"key":   hash,
+		"now":   now.UTC(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return false, dbError(err)
+	}
+	defer c.Close()
+
+	return c.HasMore(), nil
+}
+
+// RevokeSession revokes every refresh token of a session.
+func (s *Store) RevokeSession(ctx context.Context, sessionID uuid.UUID, now time.Time) error {
+	query := `FOR t IN @@coll
+	FILTER t.session_id == @session_id AND t.date_revoked == null
+	UPDATE t WITH { date_revoked: @now } IN @@coll`
+
+	bindvars := map[string]interface{}{
+		"@coll":      refreshTokenCollection,
+		"session_id": sessionID.String(),
+		"now":        now.UTC(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return dbError(err)
+	}
+	return c.Close()
+}
+
+// RevokeUserSessions revokes every refresh token of a user.
+func (s *Store) RevokeUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	query := `FOR t IN @@coll
+	FILTER t.user_id == @user_id AND t.date_revoked == null
+	UPDATE t WITH { date_revoked: @now } IN @@coll`
+
+	bindvars := map[string]interface{}{
+		"@coll":   refreshTokenCollection,
+		"user_id": userID.String(),
+		"now":     now.UTC(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return dbError(err)
+	}
+	return c.Close()
+}
+
+// RevokeOtherSessions revokes every refresh token of a user except those of
+// the session kept.
+func (s *Store) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keep uuid.UUID, now time.Time) error {
+	query := `FOR t IN @@coll
+	FILTER t.user_id == @user_id AND t.session_id != @keep AND t.date_revoked == null
+	UPDATE t WITH { date_revoked: @now } IN @@coll`
+
+	bindvars := map[string]interface{}{
+		"@coll":   refreshTokenCollection,
+		"user_id": userID.String(),
+		"keep":    keep.String(),
+		"now":     now.UTC(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return dbError(err)
+	}
+	return c.Close()
+}
+
+// SessionRevoked reports whether a session has been revoked.
+func (s *Store) SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	query := `FOR t IN @@coll
+	FILTER t.session_id == @session_id AND t.date_revoked != null
+	LIMIT 1
+	RETURN t._key`
+
+	bindvars := map[string]interface{}{
+		"@coll":      refreshTokenCollection,
+		"session_id": sessionID.String(),
+	}
+
+	c, err := s.db.Query(ctx, query, bindvars)
+	if err != nil {
+		return false, dbError(err)
+	}
+	defer c.Close()
+
+	return c.HasMore(), nil
+}
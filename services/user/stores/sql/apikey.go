@@ -0,0 +1,70 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreateAPIKey inserts a new API key into the database.
+func (s *Store) CreateAPIKey(ctx context.Context, ak user.APIKey) error {
+	const query = `INSERT INTO api_keys (` + apiKeyColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		ak.Hash, ak.ID, ak.UserID, ak.Name, ak.Prefix, encodeJSON(ak.Scopes),
+		ak.DateCreated.UTC(), ak.DateExpires.UTC(), toNullTime(ak.DateRevoked),
+	)
+	return dbError(err)
+}
+
+// QueryAPIKey queries an API key by its hash.
+func (s *Store) QueryAPIKey(ctx context.Context, hash string) (user.APIKey, error) {
+	const query = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE hash = $1`
+
+	ak, err := scanAPIKey(s.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.APIKey{}, ErrNotFound
+		}
+		return user.APIKey{}, dbError(err)
+	}
+	return ak, nil
+}
+
+// QueryAPIKeys queries the API keys of a user, newest first.
+func (s *Store) QueryAPIKeys(ctx context.Context, userID uuid.UUID) ([]user.APIKey, error) {
+	const query = `SELECT ` + apiKeyColumns + ` FROM api_keys
+	WHERE user_id = $1
+	ORDER BY date_created DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	keys := []user.APIKey{}
+	for rows.Next() {
+		ak, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		keys = append(keys, ak)
+	}
+
+	return keys, dbError(rows.Err())
+}
+
+// RevokeAPIKey revokes an API key of a user. It reports false when the user
+// has no such key.
+func (s *Store) RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID, now time.Time) (bool, error) {
+	const query = `UPDATE api_keys SET date_revoked = COALESCE(date_revoked, $1)
+	WHERE id = $2 AND user_id = $3`
+
+	return s.changed(ctx, query, now.UTC(), id, userID)
+}
@@ -0,0 +1,284 @@
+// Package sql implements user.Storer on a relational database through
+// database/sql. The queries run on Postgres and on SQLite, which the tests
+// use. Call Migrate before using a Store to bring the schema up to date.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjvonly/service/services/errs"
+	"github.com/kjvonly/service/services/user"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Queries number their parameters $1, $2 and so on, which both Postgres and
+// SQLite accept. SQLite binds them in the order they first appear, so the
+// numbers have to appear in ascending order.
+
+var (
+	ErrNotFound        = user.ErrNotFound
+	ErrUniqueEmail     = user.ErrUniqueEmail
+	ErrVersionConflict = user.ErrVersionConflict
+)
+
+type Store struct {
+	db  *sql.DB
+	log *zap.SugaredLogger
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sql.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new user into the database.
+func (s *Store) Create(ctx context.Context, usr user.User) (user.User, error) {
+	const query = `INSERT INTO users (` + userColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, 1)
+	RETURNING ` + userColumns
+
+	d := toDBUser(usr)
+	row := s.db.QueryRowContext(ctx, query,
+		d.ID, d.Name, d.Email, d.EmailVerified, d.Roles, d.PasswordHash, d.Enabled, d.Department,
+		d.Preferences, d.FailedLogins, d.LockedUntil, d.MFAEnabled, d.MFASecret, d.MFAPendingSecret,
+		d.MFALastStep, d.RecoveryCodes, d.DateVerificationSent, d.DateCreated, d.DateUpdated, d.DateDeleted,
+	)
+
+	result, err := scanUser(row)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return user.User{}, ErrUniqueEmail
+		}
+		return user.User{}, dbError(err)
+	}
+	return result, nil
+}
+
+// QueryById queries a user by id.
+func (s *Store) QueryByID(ctx context.Context, id string) (user.User, error) {
+	const query = `SELECT ` + userColumns + ` FROM users
+	WHERE id = $1 AND date_deleted IS NULL`
+
+	return s.queryUser(ctx, query, id)
+}
+
+// QueryById queries a user by email.
+func (s *Store) QueryByEmail(ctx context.Context, email string) (user.User, error) {
+	const query = `SELECT ` + userColumns + ` FROM users
+	WHERE email = $1 AND date_deleted IS NULL`
+
+	return s.queryUser(ctx, query, email)
+}
+
+// Query retrieves a page of users matching filter, ordered by orderBy.
+func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy user.OrderBy, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	sort, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	var args []interface{}
+	buf := strings.Builder{}
+	buf.WriteString("SELECT " + userColumns + " FROM users")
+	applyFilter(filter, &args, &buf)
+
+	// Sorting on the id last keeps pages stable when the ordered field
+	// holds duplicates.
+	buf.WriteString("\n\tORDER BY " + sort + ", id ASC")
+	buf.WriteString("\n\tLIMIT " + bind(&args, rowsPerPage) + " OFFSET " + bind(&args, (pageNumber-1)*rowsPerPage))
+
+	rows, err := s.db.QueryContext(ctx, buf.String(), args...)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	usrs := []user.User{}
+	for rows.Next() {
+		usr, err := scanUser(rows)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		usrs = append(usrs, usr)
+	}
+
+	return usrs, dbError(rows.Err())
+}
+
+// Count returns the total number of users matching filter.
+func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error) {
+	var args []interface{}
+	buf := strings.Builder{}
+	buf.WriteString("SELECT COUNT(*) FROM users")
+	applyFilter(filter, &args, &buf)
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, buf.String(), args...).Scan(&count); err != nil {
+		return 0, dbError(err)
+	}
+
+	return count, nil
+}
+
+// Update replaces the stored fields of a user. A user carrying a Version is
+// only updated if it is still stored at that version.
+func (s *Store) Update(ctx context.Context, usr user.User) (user.User, error) {
+	d := toDBUser(usr)
+	args := []interface{}{
+		d.Name, d.Email, d.EmailVerified, d.Roles, d.PasswordHash, d.Enabled, d.Department,
+		d.Preferences, d.FailedLogins, d.LockedUntil, d.MFAEnabled, d.MFASecret, d.MFAPendingSecret,
+		d.MFALastStep, d.RecoveryCodes, d.DateVerificationSent, d.DateCreated, d.DateUpdated, d.DateDeleted,
+		d.ID,
+	}
+
+	query := `UPDATE users SET
+	name = $1, email = $2, email_verified = $3, roles = $4, password_hash = $5, enabled = $6,
+	department = $7, preferences = $8, failed_logins = $9, locked_until = $10, mfa_enabled = $11,
+	mfa_secret = $12, mfa_pending_secret = $13, mfa_last_step = $14, recovery_codes = $15,
+	date_verification_sent = $16, date_created = $17, date_updated = $18, date_deleted = $19,
+	version = version + 1
+	WHERE id = $20`
+
+	if usr.Version != "" {
+		version, err := parseVersion(usr.Version)
+		if err != nil {
+			return user.User{}, dbError(err)
+		}
+		query += " AND version = " + bind(&args, version)
+	}
+	query += "\n\tRETURNING " + userColumns
+
+	result, err := scanUser(s.db.QueryRowContext(ctx, query, args...))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return user.User{}, s.missing(ctx, usr.ID.String())
+	case isUniqueViolation(err):
+		return user.User{}, ErrUniqueEmail
+	case err != nil:
+		return user.User{}, dbError(err)
+	}
+	return result, nil
+}
+
+// RecordFailedLogin counts a failed login of a user at now and locks the
+// account once too many have failed in a row. The count is incremented by
+// the database, which holds the row until the lockout is written.
+func (s *Store) RecordFailedLogin(ctx context.Context, id string, now time.Time) (user.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
+	defer tx.Rollback()
+
+	const count = `UPDATE users SET failed_logins = failed_logins + 1, version = version + 1
+	WHERE id = $1 AND date_deleted IS NULL
+	RETURNING ` + userColumns
+
+	usr, err := scanUser(tx.QueryRowContext(ctx, count, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, ErrNotFound
+		}
+		return user.User{}, dbError(err)
+	}
+
+	if until := user.Lockout(usr.FailedLogins, now); !until.IsZero() {
+		const lock = `UPDATE users SET locked_until = $1 WHERE id = $2`
+		if _, err := tx.ExecContext(ctx, lock, until.UTC(), id); err != nil {
+			return user.User{}, dbError(err)
+		}
+		usr.LockedUntil = until.In(time.Local)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return user.User{}, dbError(err)
+	}
+	return usr, nil
+}
+
+// queryUser queries the single user selected by query.
+func (s *Store) queryUser(ctx context.Context, query string, args ...interface{}) (user.User, error) {
+	usr, err := scanUser(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, ErrNotFound
+		}
+		return user.User{}, dbError(err)
+	}
+	return usr, nil
+}
+
+// missing explains why a statement guarded by a version matched no user:
+// either the user does not exist or it is at another version.
+func (s *Store) missing(ctx context.Context, id string) error {
+	var exists bool
+	const query = `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return dbError(err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+// bind appends value to args and returns the parameter referring to it.
+func bind(args *[]interface{}, value interface{}) string {
+	*args = append(*args, value)
+	return "$" + strconv.Itoa(len(*args))
+}
+
+// parseVersion parses a version given by a caller. A version that cannot be
+// parsed cannot be stored, so it is reported as a conflict.
+func parseVersion(version string) (int64, error) {
+	v, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return 0, ErrVersionConflict
+	}
+	return v, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+// The SQLite driver needs cgo to expose its error type, so its violations
+// are recognised by their message.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Name() == "unique_violation"
+	}
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// dbError returns err as an Unavailable error when the connection to the
+// database failed, so callers can tell an outage from a failed query. Errors
+// which already have a code are returned unchanged.
+func dbError(err error) error {
+	if errs.CodeOf(err) != errs.Internal {
+		return err
+	}
+
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &pqErr):
+		// Class 08 holds the connection exceptions and 57P03 is returned
+		// while the server is starting up.
+		if pqErr.Code.Class() == "08" || pqErr.Code == "57P03" {
+			return errs.Wrap(errs.Unavailable, err)
+		}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.As(err, &netErr):
+		return errs.Wrap(errs.Unavailable, err)
+	}
+	return err
+}
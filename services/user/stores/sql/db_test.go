@@ -0,0 +1,55 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/kjvonly/service/services/errs"
+	userSQL "github.com/kjvonly/service/services/user/stores/sql"
+	"github.com/kjvonly/service/services/user/stores/storetest"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+func Test_Store(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Migrating twice checks applied migrations are skipped.
+	for i := 0; i < 2; i++ {
+		if err := userSQL.Migrate(context.Background(), db); err != nil {
+			t.Fatalf("migrating database: %s", err)
+		}
+	}
+
+	storetest.Run(t, userSQL.NewStore(zap.NewNop().Sugar(), db))
+}
+
+func Test_Unavailable(t *testing.T) {
+	// Listening and closing again yields a port nothing answers on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %s", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	db, err := sql.Open("postgres", fmt.Sprintf("postgres://user:pass@%s/users?sslmode=disable", addr))
+	if err != nil {
+		t.Fatalf("opening database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := userSQL.NewStore(zap.NewNop().Sugar(), db)
+	if _, err := s.QueryByEmail(context.Background(), "user@example.com"); errs.CodeOf(err) != errs.Unavailable {
+		t.Fatalf("querying an unreachable database: got %v (%s), want %s", err, errs.CodeOf(err), errs.Unavailable)
+	}
+}
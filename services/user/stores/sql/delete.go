@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// ErrUserNotDeleted is returned when restoring or purging a user that has
+// not been deleted.
+var ErrUserNotDeleted = user.ErrUserNotDeleted
+
+// Delete marks a user deleted, provided it is still at version.
+func (s *Store) Delete(ctx context.Context, id string, version string, now time.Time) (user.User, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	const query = `UPDATE users SET date_deleted = $1, version = version + 1
+	WHERE id = $2 AND version = $3
+	RETURNING ` + userColumns
+
+	return s.versioned(ctx, id, query, now.UTC(), id, v)
+}
+
+// Restore clears the deletion of a user, provided it is still at version.
+func (s *Store) Restore(ctx context.Context, id string, version string) (user.User, error) {
+	if _, err := s.queryDeleted(ctx, id); err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	v, err := parseVersion(version)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	const query = `UPDATE users SET date_deleted = NULL, version = version + 1
+	WHERE id = $1 AND version = $2
+	RETURNING ` + userColumns
+
+	return s.versioned(ctx, id, query, id, v)
+}
+
+// Purge removes a deleted user from the database, provided it is still at
+// version.
+func (s *Store) Purge(ctx context.Context, id string, version string) (user.User, error) {
+	if _, err := s.queryDeleted(ctx, id); err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	v, err := parseVersion(version)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
+
+	const query = `DELETE FROM users
+	WHERE id = $1 AND version = $2
+	RETURNING ` + userColumns
+
+	return s.versioned(ctx, id, query, id, v)
+}
+
+// PurgeDeleted removes the users deleted before the given time, returning
+// how many were removed.
+func (s *Store) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	const query = `DELETE FROM users
+	WHERE date_deleted IS NOT NULL AND date_deleted < $1`
+
+	res, err := s.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, dbError(err)
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), dbError(err)
+}
+
+// queryDeleted queries a deleted user by id.
+func (s *Store) queryDeleted(ctx context.Context, id string) (user.User, error) {
+	const query = `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	usr, err := s.queryUser(ctx, query, id)
+	if err != nil {
+		return user.User{}, dbError(err)
+	}
+	if usr.DateDeleted == nil {
+		return user.User{}, ErrUserNotDeleted
+	}
+	return usr, nil
+}
+
+// versioned runs query, a statement on the user with id guarded by its
+// version, returning the user it changed.
+func (s *Store) versioned(ctx context.Context, id string, query string, args ...interface{}) (user.User, error) {
+	usr, err := scanUser(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, s.missing(ctx, id)
+		}
+		return user.User{}, dbError(err)
+	}
+	return usr, nil
+}
@@ -0,0 +1,57 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// likeEscaper escapes the characters LIKE treats specially, so a value is
+// matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// applyFilter adds a WHERE clause to the query for every field set in
+// filter, with the values appended to args as parameters.
+func applyFilter(filter user.QueryFilter, args *[]interface{}, buf *strings.Builder) {
+	var wc []string
+
+	if filter.Name != nil {
+		name := "%" + likeEscaper.Replace(strings.ToLower(*filter.Name)) + "%"
+		wc = append(wc, `LOWER(name) LIKE `+bind(args, name)+` ESCAPE '\'`)
+	}
+
+	if filter.Email != nil {
+		wc = append(wc, "email = "+bind(args, *filter.Email))
+	}
+
+	if filter.Role != nil {
+		// Roles are stored as a JSON list of names.
+		role := `%"` + likeEscaper.Replace(*filter.Role) + `"%`
+		wc = append(wc, `roles LIKE `+bind(args, role)+` ESCAPE '\'`)
+	}
+
+	if filter.Department != nil {
+		wc = append(wc, "department = "+bind(args, *filter.Department))
+	}
+
+	if filter.Enabled != nil {
+		wc = append(wc, "enabled = "+bind(args, *filter.Enabled))
+	}
+
+	if filter.StartCreatedDate != nil {
+		wc = append(wc, "date_created >= "+bind(args, filter.StartCreatedDate.UTC()))
+	}
+
+	if filter.EndCreatedDate != nil {
+		wc = append(wc, "date_created <= "+bind(args, filter.EndCreatedDate.UTC()))
+	}
+
+	if filter.Deleted != nil && *filter.Deleted {
+		wc = append(wc, "date_deleted IS NOT NULL")
+	} else {
+		wc = append(wc, "date_deleted IS NULL")
+	}
+
+	buf.WriteString("\n\tWHERE ")
+	buf.WriteString(strings.Join(wc, "\n\tAND "))
+}
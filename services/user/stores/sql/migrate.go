@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate brings the schema up to date by running, in order, every migration
+// not yet recorded in the schema_migrations table. Migrations are named
+// NNNN_description.sql and each runs in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	const create = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version      INTEGER PRIMARY KEY,
+	date_applied TIMESTAMP NOT NULL
+)`
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		prefix, _, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s: version: %w", base, err)
+		}
+
+		if err := migrate(ctx, db, version, name); err != nil {
+			return fmt.Errorf("migration %s: %w", base, err)
+		}
+	}
+
+	return nil
+}
+
+// migrate runs the migration held in file unless version has already been
+// applied.
+func migrate(ctx context.Context, db *sql.DB, version int, file string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	const exists = `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`
+	if err := tx.QueryRowContext(ctx, exists, version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	b, err := migrations.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, string(b)); err != nil {
+		return err
+	}
+
+	const record = `INSERT INTO schema_migrations (version, date_applied) VALUES ($1, $2)`
+	if _, err := tx.ExecContext(ctx, record, version, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
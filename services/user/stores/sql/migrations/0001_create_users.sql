@@ -0,0 +1,73 @@
+-- Lists are stored as JSON text and times as UTC timestamps so the schema
+-- works on both Postgres and SQLite.
+
+CREATE TABLE users (
+	id                     TEXT PRIMARY KEY,
+	name                   TEXT NOT NULL,
+	email                  TEXT NOT NULL UNIQUE,
+	email_verified         BOOLEAN NOT NULL,
+	roles                  TEXT NOT NULL,
+	password_hash          TEXT NOT NULL,
+	enabled                BOOLEAN NOT NULL,
+	department             TEXT,
+	preferences            TEXT NOT NULL,
+	failed_logins          INTEGER NOT NULL,
+	locked_until           TIMESTAMP NOT NULL,
+	mfa_enabled            BOOLEAN NOT NULL,
+	mfa_secret             TEXT NOT NULL,
+	mfa_pending_secret     TEXT NOT NULL,
+	mfa_last_step          BIGINT NOT NULL,
+	recovery_codes         TEXT NOT NULL,
+	date_verification_sent TIMESTAMP NOT NULL,
+	date_created           TIMESTAMP NOT NULL,
+	date_updated           TIMESTAMP NOT NULL,
+	date_deleted           TIMESTAMP,
+	version                BIGINT NOT NULL
+);
+
+CREATE INDEX users_date_deleted ON users (date_deleted);
+
+CREATE TABLE refresh_tokens (
+	hash         TEXT PRIMARY KEY,
+	session_id   TEXT NOT NULL,
+	user_id      TEXT NOT NULL,
+	device       TEXT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_expires TIMESTAMP NOT NULL,
+	date_used    TIMESTAMP,
+	date_revoked TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_session_id ON refresh_tokens (session_id);
+CREATE INDEX refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE TABLE password_resets (
+	hash         TEXT PRIMARY KEY,
+	user_id      TEXT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_expires TIMESTAMP NOT NULL,
+	date_used    TIMESTAMP
+);
+
+CREATE TABLE api_keys (
+	hash         TEXT PRIMARY KEY,
+	id           TEXT NOT NULL UNIQUE,
+	user_id      TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	scopes       TEXT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_expires TIMESTAMP NOT NULL,
+	date_revoked TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE roles (
+	name         TEXT PRIMARY KEY,
+	description  TEXT NOT NULL,
+	permissions  TEXT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_updated TIMESTAMP NOT NULL,
+	version      BIGINT NOT NULL
+);
@@ -0,0 +1,243 @@
+package sql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+)
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// userColumns lists the columns of a user in the order scanUser reads them.
+const userColumns = `id, name, email, email_verified, roles, password_hash, enabled, department,
+	preferences, failed_logins, locked_until, mfa_enabled, mfa_secret, mfa_pending_secret,
+	mfa_last_step, recovery_codes, date_verification_sent, date_created, date_updated,
+	date_deleted, version`
+
+// dbUser represent the structure we need for moving data
+// between the app and the database.
+type dbUser struct {
+	ID                   uuid.UUID
+	Name                 string
+	Email                string
+	EmailVerified        bool
+	Roles                string
+	PasswordHash         string
+	Enabled              bool
+	Department           sql.NullString
+	Preferences          string
+	FailedLogins         int
+	LockedUntil          time.Time
+	MFAEnabled           bool
+	MFASecret            string
+	MFAPendingSecret     string
+	MFALastStep          int64
+	RecoveryCodes        string
+	DateVerificationSent time.Time
+	DateCreated          time.Time
+	DateUpdated          time.Time
+	DateDeleted          sql.NullTime
+	Version              int64
+}
+
+func toDBUser(usr user.User) dbUser {
+	roles := make([]string, len(usr.Roles))
+	for i, role := range usr.Roles {
+		roles[i] = role.Name()
+	}
+
+	return dbUser{
+		ID:            usr.ID,
+		Name:          usr.Name,
+		Email:         usr.Email.Address,
+		EmailVerified: usr.EmailVerified,
+		Roles:         encodeJSON(roles),
+		PasswordHash:  string(usr.PasswordHash),
+		Enabled:       usr.Enabled,
+		Department: sql.NullString{
+			String: usr.Department,
+			Valid:  usr.Department != "",
+		},
+		Preferences:          encodeJSON(usr.Preferences),
+		FailedLogins:         usr.FailedLogins,
+		LockedUntil:          usr.LockedUntil.UTC(),
+		MFAEnabled:           usr.MFAEnabled,
+		MFASecret:            usr.MFASecret,
+		MFAPendingSecret:     usr.MFAPendingSecret,
+		MFALastStep:          usr.MFALastStep,
+		RecoveryCodes:        encodeJSON(usr.RecoveryCodes),
+		DateVerificationSent: usr.DateVerificationSent.UTC(),
+		DateCreated:          usr.DateCreated.UTC(),
+		DateUpdated:          usr.DateUpdated.UTC(),
+		DateDeleted:          toNullTime(usr.DateDeleted),
+	}
+}
+
+// scanUser reads a row selected with userColumns.
+func scanUser(row scanner) (user.User, error) {
+	var dbUsr dbUser
+	err := row.Scan(
+		&dbUsr.ID, &dbUsr.Name, &dbUsr.Email, &dbUsr.EmailVerified, &dbUsr.Roles, &dbUsr.PasswordHash,
+		&dbUsr.Enabled, &dbUsr.Department, &dbUsr.Preferences, &dbUsr.FailedLogins, &dbUsr.LockedUntil,
+		&dbUsr.MFAEnabled, &dbUsr.MFASecret, &dbUsr.MFAPendingSecret, &dbUsr.MFALastStep,
+		&dbUsr.RecoveryCodes, &dbUsr.DateVerificationSent, &dbUsr.DateCreated, &dbUsr.DateUpdated,
+		&dbUsr.DateDeleted, &dbUsr.Version,
+	)
+	if err != nil {
+		return user.User{}, err
+	}
+	return toCoreUser(dbUsr)
+}
+
+func toCoreUser(dbUsr dbUser) (user.User, error) {
+	addr := mail.Address{
+		Address: dbUsr.Email,
+	}
+
+	var names []string
+	if err := json.Unmarshal([]byte(dbUsr.Roles), &names); err != nil {
+		return user.User{}, err
+	}
+	roles := make([]user.Role, len(names))
+	for i, value := range names {
+		roles[i] = user.MustParseRole(value)
+	}
+
+	var prefs map[string]string
+	if err := json.Unmarshal([]byte(dbUsr.Preferences), &prefs); err != nil {
+		return user.User{}, err
+	}
+
+	var codes []string
+	if err := json.Unmarshal([]byte(dbUsr.RecoveryCodes), &codes); err != nil {
+		return user.User{}, err
+	}
+
+	usr := user.User{
+		ID:                   dbUsr.ID,
+		Name:                 dbUsr.Name,
+		Email:                addr,
+		EmailVerified:        dbUsr.EmailVerified,
+		Roles:                roles,
+		PasswordHash:         []byte(dbUsr.PasswordHash),
+		Department:           dbUsr.Department.String,
+		Preferences:          prefs,
+		Enabled:              dbUsr.Enabled,
+		FailedLogins:         dbUsr.FailedLogins,
+		LockedUntil:          dbUsr.LockedUntil.In(time.Local),
+		MFAEnabled:           dbUsr.MFAEnabled,
+		MFASecret:            dbUsr.MFASecret,
+		MFAPendingSecret:     dbUsr.MFAPendingSecret,
+		MFALastStep:          dbUsr.MFALastStep,
+		RecoveryCodes:        codes,
+		DateVerificationSent: dbUsr.DateVerificationSent.In(time.Local),
+		DateCreated:          dbUsr.DateCreated.In(time.Local),
+		DateUpdated:          dbUsr.DateUpdated.In(time.Local),
+		Version:              strconv.FormatInt(dbUsr.Version, 10),
+		DateDeleted:          fromNullTime(dbUsr.DateDeleted),
+	}
+
+	return usr, nil
+}
+
+// refreshTokenColumns lists the columns of a refresh token in the order
+// scanRefreshToken reads them.
+const refreshTokenColumns = `hash, session_id, user_id, device, date_created, date_expires, date_used, date_revoked`
+
+func scanRefreshToken(row scanner) (user.RefreshToken, error) {
+	var rt user.RefreshToken
+	var used, revoked sql.NullTime
+	if err := row.Scan(&rt.Hash, &rt.SessionID, &rt.UserID, &rt.Device, &rt.DateCreated, &rt.DateExpires, &used, &revoked); err != nil {
+		return user.RefreshToken{}, err
+	}
+	rt.DateCreated = rt.DateCreated.In(time.Local)
+	rt.DateExpires = rt.DateExpires.In(time.Local)
+	rt.DateUsed = fromNullTime(used)
+	rt.DateRevoked = fromNullTime(revoked)
+	return rt, nil
+}
+
+// passwordResetColumns lists the columns of a password reset token in the
+// order scanPasswordReset reads them.
+const passwordResetColumns = `hash, user_id, date_created, date_expires, date_used`
+
+func scanPasswordReset(row scanner) (user.PasswordReset, error) {
+	var pr user.PasswordReset
+	var used sql.NullTime
+	if err := row.Scan(&pr.Hash, &pr.UserID, &pr.DateCreated, &pr.DateExpires, &used); err != nil {
+		return user.PasswordReset{}, err
+	}
+	pr.DateCreated = pr.DateCreated.In(time.Local)
+	pr.DateExpires = pr.DateExpires.In(time.Local)
+	pr.DateUsed = fromNullTime(used)
+	return pr, nil
+}
+
+// apiKeyColumns lists the columns of an API key in the order scanAPIKey
+// reads them.
+const apiKeyColumns = `hash, id, user_id, name, prefix, scopes, date_created, date_expires, date_revoked`
+
+func scanAPIKey(row scanner) (user.APIKey, error) {
+	var ak user.APIKey
+	var scopes string
+	var revoked sql.NullTime
+	if err := row.Scan(&ak.Hash, &ak.ID, &ak.UserID, &ak.Name, &ak.Prefix, &scopes, &ak.DateCreated, &ak.DateExpires, &revoked); err != nil {
+		return user.APIKey{}, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &ak.Scopes); err != nil {
+		return user.APIKey{}, err
+	}
+	ak.DateCreated = ak.DateCreated.In(time.Local)
+	ak.DateExpires = ak.DateExpires.In(time.Local)
+	ak.DateRevoked = fromNullTime(revoked)
+	return ak, nil
+}
+
+// roleColumns lists the columns of a role in the order scanRole reads them.
+const roleColumns = `name, description, permissions, date_created, date_updated, version`
+
+func scanRole(row scanner) (user.RoleDefinition, error) {
+	var rd user.RoleDefinition
+	var perms string
+	var version int64
+	if err := row.Scan(&rd.Name, &rd.Description, &perms, &rd.DateCreated, &rd.DateUpdated, &version); err != nil {
+		return user.RoleDefinition{}, err
+	}
+	if err := json.Unmarshal([]byte(perms), &rd.Permissions); err != nil {
+		return user.RoleDefinition{}, err
+	}
+	rd.DateCreated = rd.DateCreated.In(time.Local)
+	rd.DateUpdated = rd.DateUpdated.In(time.Local)
+	rd.Version = strconv.FormatInt(version, 10)
+	return rd, nil
+}
+
+// encodeJSON encodes the lists and maps stored as JSON text. They only hold
+// strings, so encoding cannot fail.
+func encodeJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func fromNullTime(nt sql.NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
+	}
+	t := nt.Time.In(time.Local)
+	return &t
+}
@@ -0,0 +1,33 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// orderByFields maps the fields users can be ordered by to the columns
+// holding them in the database.
+var orderByFields = map[string]string{
+	user.OrderByName:        "name",
+	user.OrderByEmail:       "email",
+	user.OrderByRoles:       "roles",
+	user.OrderByDepartment:  "COALESCE(department, '')",
+	user.OrderByEnabled:     "enabled",
+	user.OrderByDateCreated: "date_created",
+}
+
+// orderByClause returns the ORDER BY expression for orderBy. Only known
+// fields and directions are accepted since neither can be a parameter.
+func orderByClause(orderBy user.OrderBy) (string, error) {
+	by, exists := orderByFields[orderBy.Field]
+	if !exists {
+		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	if orderBy.Direction != user.ASC && orderBy.Direction != user.DESC {
+		return "", fmt.Errorf("direction %q does not exist", orderBy.Direction)
+	}
+
+	return by + " " + orderBy.Direction, nil
+}
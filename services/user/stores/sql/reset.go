@@ -0,0 +1,44 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreatePasswordReset inserts a new password reset token into the database.
+func (s *Store) CreatePasswordReset(ctx context.Context, pr user.PasswordReset) error {
+	const query = `INSERT INTO password_resets (` + passwordResetColumns + `)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		pr.Hash, pr.UserID, pr.DateCreated.UTC(), pr.DateExpires.UTC(), toNullTime(pr.DateUsed),
+	)
+	return dbError(err)
+}
+
+// QueryPasswordReset queries a password reset token by its hash.
+func (s *Store) QueryPasswordReset(ctx context.Context, hash string) (user.PasswordReset, error) {
+	const query = `SELECT ` + passwordResetColumns + ` FROM password_resets WHERE hash = $1`
+
+	pr, err := scanPasswordReset(s.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.PasswordReset{}, ErrNotFound
+		}
+		return user.PasswordReset{}, dbError(err)
+	}
+	return pr, nil
+}
+
+// UsePasswordReset marks a password reset token as used. It reports false
+// when the token had already been used.
+func (s *Store) UsePasswordReset(ctx context.Context, hash string, now time.Time) (bool, error) {
+	const query = `UPDATE password_resets SET date_used = $1
+	WHERE hash = $2 AND date_used IS NULL`
+
+	return s.changed(ctx, query, now.UTC(), hash)
+}
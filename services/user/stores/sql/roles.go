@@ -0,0 +1,120 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/kjvonly/service/services/user"
+)
+
+// ErrRoleNotFound is returned when a role is not stored.
+var ErrRoleNotFound = user.ErrRoleNotFound
+
+// CreateRole inserts a new role into the database.
+func (s *Store) CreateRole(ctx context.Context, rd user.RoleDefinition) (user.RoleDefinition, error) {
+	const query = `INSERT INTO roles (` + roleColumns + `)
+	VALUES ($1, $2, $3, $4, $5, 1)
+	RETURNING ` + roleColumns
+
+	row := s.db.QueryRowContext(ctx, query,
+		rd.Name, rd.Description, encodeJSON(rd.Permissions), rd.DateCreated.UTC(), rd.DateUpdated.UTC(),
+	)
+
+	result, err := scanRole(row)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return user.RoleDefinition{}, user.ErrRoleExists
+		}
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return result, nil
+}
+
+// QueryRole queries a role by name.
+func (s *Store) QueryRole(ctx context.Context, name string) (user.RoleDefinition, error) {
+	const query = `SELECT ` + roleColumns + ` FROM roles WHERE name = $1`
+
+	rd, err := scanRole(s.db.QueryRowContext(ctx, query, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.RoleDefinition{}, ErrRoleNotFound
+		}
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return rd, nil
+}
+
+// QueryRoles queries every stored role ordered by name.
+func (s *Store) QueryRoles(ctx context.Context) ([]user.RoleDefinition, error) {
+	const query = `SELECT ` + roleColumns + ` FROM roles ORDER BY name ASC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	roles := []user.RoleDefinition{}
+	for rows.Next() {
+		rd, err := scanRole(rows)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		roles = append(roles, rd)
+	}
+
+	return roles, dbError(rows.Err())
+}
+
+// UpdateRole replaces the stored fields of a role, provided it is still
+// stored at its Version.
+func (s *Store) UpdateRole(ctx context.Context, rd user.RoleDefinition) (user.RoleDefinition, error) {
+	v, err := parseVersion(rd.Version)
+	if err != nil {
+		return user.RoleDefinition{}, dbError(err)
+	}
+
+	const query = `UPDATE roles SET
+	description = $1, permissions = $2, date_created = $3, date_updated = $4, version = version + 1
+	WHERE name = $5 AND version = $6
+	RETURNING ` + roleColumns
+
+	row := s.db.QueryRowContext(ctx, query,
+		rd.Description, encodeJSON(rd.Permissions), rd.DateCreated.UTC(), rd.DateUpdated.UTC(), rd.Name, v,
+	)
+	return s.versionedRole(ctx, rd.Name, row)
+}
+
+// DeleteRole removes a role from the database, provided it is still stored
+// at version.
+func (s *Store) DeleteRole(ctx context.Context, name string, version string) (user.RoleDefinition, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return user.RoleDefinition{}, dbError(err)
+	}
+
+	const query = `DELETE FROM roles
+	WHERE name = $1 AND version = $2
+	RETURNING ` + roleColumns
+
+	return s.versionedRole(ctx, name, s.db.QueryRowContext(ctx, query, name, v))
+}
+
+// versionedRole reads the role returned by a statement guarded by its
+// version. When none was returned, either the role does not exist or it is
+// at another version.
+func (s *Store) versionedRole(ctx context.Context, name string, row *sql.Row) (user.RoleDefinition, error) {
+	rd, err := scanRole(row)
+	if err == nil {
+		return rd, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return user.RoleDefinition{}, dbError(err)
+	}
+
+	if _, err := s.QueryRole(ctx, name); err != nil {
+		return user.RoleDefinition{}, dbError(err)
+	}
+	return user.RoleDefinition{}, ErrVersionConflict
+}
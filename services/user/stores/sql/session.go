@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+)
+
+// CreateRefreshToken inserts a new refresh token into the database.
+func (s *Store) CreateRefreshToken(ctx context.Context, rt user.RefreshToken) error {
+	const query = `INSERT INTO refresh_tokens (` + refreshTokenColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		rt.Hash, rt.SessionID, rt.UserID, rt.Device, rt.DateCreated.UTC(), rt.DateExpires.UTC(),
+		toNullTime(rt.DateUsed), toNullTime(rt.DateRevoked),
+	)
+	return dbError(err)
+}
+
+// QueryRefreshToken queries a refresh token by its hash.
+func (s *Store) QueryRefreshToken(ctx context.Context, hash string) (user.RefreshToken, error) {
+	const query = `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE hash = $1`
+
+	rt, err := scanRefreshToken(s.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.RefreshToken{}, ErrNotFound
+		}
+		return user.RefreshToken{}, dbError(err)
+	}
+	return rt, nil
+}
+
+// UseRefreshToken marks a refresh token as used. It reports false when the
+// token had already been used.
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, now time.Time) (bool, error) {
+	const query = `UPDATE refresh_tokens SET date_used = $1
+	WHERE hash = $2 AND date_used IS NULL`
+
+	return s.changed(ctx, query, now.UTC(), hash)
+}
+
+// RevokeSession revokes every refresh token of a session.
+func (s *Store) RevokeSession(ctx context.Context, sessionID uuid.UUID, now time.Time) error {
+	const query = `UPDATE refresh_tokens SET date_revoked = $1
+	WHERE session_id = $2 AND date_revoked IS NULL`
+
+	_, err := s.db.ExecContext(ctx, query, now.UTC(), sessionID)
+	return dbError(err)
+}
+
+// RevokeUserSessions revokes every refresh token of a user.
+func (s *Store) RevokeUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	const query = `UPDATE refresh_tokens SET date_revoked = $1
+	WHERE user_id = $2 AND date_revoked IS NULL`
+
+	_, err := s.db.ExecContext(ctx, query, now.UTC(), userID)
+	return dbError(err)
+}
+
+// RevokeOtherSessions revokes every refresh token of a user except those of
+// the session kept.
+func (s *Store) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keep uuid.UUID, now time.Time) error {
+	const query = `UPDATE refresh_tokens SET date_revoked = $1
+	WHERE user_id = $2 AND session_id <> $3 AND date_revoked IS NULL`
+
+	_, err := s.db.ExecContext(ctx, query, now.UTC(), userID, keep)
+	return dbError(err)
+}
+
+// SessionRevoked reports whether a session has been revoked.
+func (s *Store) SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	const query = `SELECT EXISTS (
+		SELECT 1 FROM refresh_tokens WHERE session_id = $1 AND date_revoked IS NOT NULL
+	)`
+
+	var revoked bool
+	if err := s.db.QueryRowContext(ctx, query, sessionID).Scan(&revoked); err != nil {
+		return false, dbError(err)
+	}
+	return revoked, nil
+}
+
+// changed runs query and reports whether it changed any row.
+func (s *Store) changed(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, dbError(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, dbError(err)
+	}
+	return n > 0, nil
+}
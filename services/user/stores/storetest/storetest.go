@@ -0,0 +1,487 @@
+// Package storetest holds the tests every user.Storer has to pass, so the
+// user service behaves the same whichever store it runs on.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/user"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// Run tests storer against the semantics the user service relies on. The
+// store must be empty.
+func Run(t *testing.T, storer user.Storer) {
+	t.Run("users", func(t *testing.T) { testUsers(t, storer) })
+	t.Run("lockout", func(t *testing.T) { testLockout(t, storer) })
+	t.Run("delete", func(t *testing.T) { testDelete(t, storer) })
+	t.Run("sessions", func(t *testing.T) { testSessions(t, storer) })
+	t.Run("resets", func(t *testing.T) { testResets(t, storer) })
+	t.Run("apikeys", func(t *testing.T) { testAPIKeys(t, storer) })
+	t.Run("roles", func(t *testing.T) { testRoles(t, storer) })
+}
+
+// newUser returns a user with email that can be created.
+func newUser(t *testing.T, name string, email string, password string) user.User {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("generating password hash: %s", err)
+	}
+
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	return user.User{
+		ID:           uuid.New(),
+		Name:         name,
+		Email:        mail.Address{Address: email},
+		Roles:        []user.Role{user.RoleUser},
+		PasswordHash: hash,
+		Department:   "Translation",
+		Enabled:      true,
+		DateCreated:  now,
+		DateUpdated:  now,
+	}
+}
+
+func testUsers(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+
+	t.Log("Given the need to store users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating and querying users.", testID)
+		{
+			usr, err := storer.Create(ctx, newUser(t, "John Doe", "john@example.com", "Gophers4Ever"))
+			if err != nil || usr.Version == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user with a version : got %+v, %v.", failed, testID, usr, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a user with a version.", success, testID)
+
+			got, err := storer.QueryByID(ctx, usr.ID.String())
+			if err != nil || got.Email.Address != usr.Email.Address || got.Version != usr.Version || got.Department != usr.Department {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query a user by id : got %+v, %v.", failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query a user by id.", success, testID)
+
+			got, err = storer.QueryByEmail(ctx, "john@example.com")
+			if err != nil || got.ID != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query a user by email : got %+v, %v.", failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query a user by email.", success, testID)
+
+			if err := bcrypt.CompareHashAndPassword(got.PasswordHash, []byte("Gophers4Ever")); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the password hash : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the password hash.", success, testID)
+
+			if _, err := storer.QueryByID(ctx, uuid.NewString()); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown id : got %v.", failed, testID, err)
+			}
+			if _, err := storer.QueryByEmail(ctx, "nobody@example.com"); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown email : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not find unknown users.", success, testID)
+
+			if _, err := storer.Create(ctx, newUser(t, "Jane Doe", "john@example.com", "Gophers4Ever")); !errors.Is(err, user.ErrUniqueEmail) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse a taken email : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse a taken email.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen updating users.", testID)
+		{
+			usr, err := storer.QueryByEmail(ctx, "john@example.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query a user by email : %v.", failed, testID, err)
+			}
+			jane, err := storer.Create(ctx, newUser(t, "Jane Doe", "jane@example.com", "Gophers4Ever"))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user : %v.", failed, testID, err)
+			}
+
+			stale := usr
+			usr.Name = "Johnny Doe"
+			updated, err := storer.Update(ctx, usr)
+			if err != nil || updated.Name != "Johnny Doe" || updated.Version == usr.Version {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update a user to a new version : got %+v, %v.", failed, testID, updated, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update a user to a new version.", success, testID)
+
+			if _, err := storer.Update(ctx, stale); !errors.Is(err, user.ErrVersionConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to update a stale version : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to update a stale version.", success, testID)
+
+			jane.Email = mail.Address{Address: "john@example.com"}
+			if _, err := storer.Update(ctx, jane); !errors.Is(err, user.ErrUniqueEmail) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to update to a taken email : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to update to a taken email.", success, testID)
+
+			if _, err := storer.Update(ctx, newUser(t, "Nobody", "nobody@example.com", "Gophers4Ever")); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not update an unknown user : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not update an unknown user.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen querying pages of users.", testID)
+		{
+			name := "doe"
+			filter := user.QueryFilter{Name: &name}
+			orderBy := user.OrderBy{Field: user.OrderByName, Direction: user.DESC}
+
+			usrs, err := storer.Query(ctx, filter, orderBy, 1, 1)
+			if err != nil || len(usrs) != 1 || usrs[0].Name != "Johnny Doe" {
+				t.Fatalf("\t%s\tTest %d:\tShould return the first page in order : got %+v, %v.", failed, testID, usrs, err)
+			}
+			usrs, err = storer.Query(ctx, filter, orderBy, 2, 1)
+			if err != nil || len(usrs) != 1 || usrs[0].Name != "Jane Doe" {
+				t.Fatalf("\t%s\tTest %d:\tShould return the second page in order : got %+v, %v.", failed, testID, usrs, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return pages in order.", success, testID)
+
+			role := user.RoleAdmin.Name()
+			if n, err := storer.Count(ctx, user.QueryFilter{Role: &role}); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould filter on roles : got %d, %v.", failed, testID, n, err)
+			}
+			if n, err := storer.Count(ctx, filter); err != nil || n != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould count matching users : got %d, %v.", failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould count matching users.", success, testID)
+		}
+	}
+}
+
+func testLockout(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+	now := time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to count failed logins.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen logins keep failing.", testID)
+		{
+			usr, err := storer.Create(ctx, newUser(t, "Locked Out", "locked@example.com", "Gophers4Ever"))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user : %v.", failed, testID, err)
+			}
+
+			for i := 1; i < 5; i++ {
+				got, err := storer.RecordFailedLogin(ctx, usr.ID.String(), now)
+				if err != nil || got.FailedLogins != i || got.Locked(now) {
+					t.Fatalf("\t%s\tTest %d:\tShould count failed login %d : got %d, locked %t, %v.", failed, testID, i, got.FailedLogins, got.Locked(now), err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould count failed logins.", success, testID)
+
+			got, err := storer.RecordFailedLogin(ctx, usr.ID.String(), now)
+			if err != nil || got.FailedLogins != 5 || !got.LockedUntil.Equal(user.Lockout(5, now)) || got.Version == usr.Version {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the account : got %+v, %v.", failed, testID, got, err)
+			}
+			stored, err := storer.QueryByID(ctx, usr.ID.String())
+			if err != nil || stored.FailedLogins != 5 || !stored.LockedUntil.Equal(got.LockedUntil) || stored.Version != got.Version {
+				t.Fatalf("\t%s\tTest %d:\tShould store the lockout : got %+v, %v.", failed, testID, stored, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the account.", success, testID)
+
+			if _, err := storer.RecordFailedLogin(ctx, uuid.NewString(), now); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not count failed logins of an unknown user : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not count failed logins of an unknown user.", success, testID)
+		}
+	}
+}
+
+func testDelete(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	t.Log("Given the need to delete users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen deleting, restoring and purging a user.", testID)
+		{
+			usr, err := storer.Create(ctx, newUser(t, "Deleted Doe", "deleted@example.com", "Gophers4Ever"))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user : %v.", failed, testID, err)
+			}
+
+			if _, err := storer.Restore(ctx, usr.ID.String(), usr.Version); !errors.Is(err, user.ErrUserNotDeleted) {
+				t.Fatalf("\t%s\tTest %d:\tShould only restore deleted users : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only restore deleted users.", success, testID)
+
+			deleted, err := storer.Delete(ctx, usr.ID.String(), usr.Version, now)
+			if err != nil || deleted.DateDeleted == nil || deleted.Version == usr.Version {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a user : got %+v, %v.", failed, testID, deleted, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete a user.", success, testID)
+
+			if _, err := storer.QueryByID(ctx, usr.ID.String()); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find a deleted user : got %v.", failed, testID, err)
+			}
+			yes := true
+			if n, err := storer.Count(ctx, user.QueryFilter{Deleted: &yes}); err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould count deleted users when asked : got %d, %v.", failed, testID, n, err)
+			}
+			if _, err := storer.Create(ctx, newUser(t, "Other Doe", "deleted@example.com", "Gophers4Ever")); !errors.Is(err, user.ErrUniqueEmail) {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the email of a deleted user : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould hide deleted users but keep their email.", success, testID)
+
+			if _, err := storer.Restore(ctx, usr.ID.String(), usr.Version); !errors.Is(err, user.ErrVersionConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to restore a stale version : got %v.", failed, testID, err)
+			}
+			restored, err := storer.Restore(ctx, usr.ID.String(), deleted.Version)
+			if err != nil || restored.DateDeleted != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore a user : got %+v, %v.", failed, testID, restored, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to restore a user.", success, testID)
+
+			if _, err := storer.Purge(ctx, usr.ID.String(), restored.Version); !errors.Is(err, user.ErrUserNotDeleted) {
+				t.Fatalf("\t%s\tTest %d:\tShould only purge deleted users : got %v.", failed, testID, err)
+			}
+			deleted, err = storer.Delete(ctx, usr.ID.String(), restored.Version, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a user : %v.", failed, testID, err)
+			}
+			if _, err := storer.Purge(ctx, usr.ID.String(), deleted.Version); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to purge a deleted user : %v.", failed, testID, err)
+			}
+			if _, err := storer.Restore(ctx, usr.ID.String(), deleted.Version); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find a purged user : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to purge a deleted user.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen purging users deleted long ago.", testID)
+		{
+			old, err := storer.Create(ctx, newUser(t, "Old Doe", "old@example.com", "Gophers4Ever"))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user : %v.", failed, testID, err)
+			}
+			recent, err := storer.Create(ctx, newUser(t, "Recent Doe", "recent@example.com", "Gophers4Ever"))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user : %v.", failed, testID, err)
+			}
+			if _, err := storer.Delete(ctx, old.ID.String(), old.Version, now.Add(-48*time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a user : %v.", failed, testID, err)
+			}
+			if _, err := storer.Delete(ctx, recent.ID.String(), recent.Version, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a user : %v.", failed, testID, err)
+			}
+
+			n, err := storer.PurgeDeleted(ctx, now.Add(-24*time.Hour))
+			if err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould purge only users deleted before the cutoff : got %d, %v.", failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould purge only users deleted before the cutoff.", success, testID)
+		}
+	}
+}
+
+func testSessions(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	userID := uuid.New()
+	kept, other := uuid.New(), uuid.New()
+
+	t.Log("Given the need to track sessions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using and revoking refresh tokens.", testID)
+		{
+			for _, rt := range []user.RefreshToken{
+				{Hash: "kept", SessionID: kept, UserID: userID, DateCreated: now, DateExpires: now.Add(time.Hour)},
+				{Hash: "other", SessionID: other, UserID: userID, DateCreated: now, DateExpires: now.Add(time.Hour)},
+			} {
+				if err := storer.CreateRefreshToken(ctx, rt); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create a refresh token : %v.", failed, testID, err)
+				}
+			}
+
+			rt, err := storer.QueryRefreshToken(ctx, "kept")
+			if err != nil || rt.SessionID != kept || rt.DateUsed != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query a refresh token : got %+v, %v.", failed, testID, rt, err)
+			}
+			if _, err := storer.QueryRefreshToken(ctx, "unknown"); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown refresh token : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query a refresh token.", success, testID)
+
+			if fresh, err := storer.UseRefreshToken(ctx, "kept", now); err != nil || !fresh {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to use a refresh token : got %t, %v.", failed, testID, fresh, err)
+			}
+			if fresh, err := storer.UseRefreshToken(ctx, "kept", now); err != nil || fresh {
+				t.Fatalf("\t%s\tTest %d:\tShould report a reused refresh token : got %t, %v.", failed, testID, fresh, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only use a refresh token once.", success, testID)
+
+			if err := storer.RevokeOtherSessions(ctx, userID, kept, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke other sessions : %v.", failed, testID, err)
+			}
+			keptRevoked, err := storer.SessionRevoked(ctx, kept)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check a session : %v.", failed, testID, err)
+			}
+			otherRevoked, err := storer.SessionRevoked(ctx, other)
+			if err != nil || keptRevoked || !otherRevoked {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke only the other sessions : got kept %t, other %t, %v.", failed, testID, keptRevoked, otherRevoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke only the other sessions.", success, testID)
+
+			if err := storer.RevokeUserSessions(ctx, userID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke every session : %v.", failed, testID, err)
+			}
+			if revoked, err := storer.SessionRevoked(ctx, kept); err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke every session of the user : got %t, %v.", failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke every session of the user.", success, testID)
+		}
+	}
+}
+
+func testResets(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	t.Log("Given the need to reset passwords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a password reset token.", testID)
+		{
+			pr := user.PasswordReset{Hash: "reset", UserID: uuid.New(), DateCreated: now, DateExpires: now.Add(time.Hour)}
+			if err := storer.CreatePasswordReset(ctx, pr); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a reset token : %v.", failed, testID, err)
+			}
+
+			got, err := storer.QueryPasswordReset(ctx, "reset")
+			if err != nil || got.UserID != pr.UserID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query a reset token : got %+v, %v.", failed, testID, got, err)
+			}
+			if _, err := storer.QueryPasswordReset(ctx, "unknown"); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown reset token : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query a reset token.", success, testID)
+
+			if fresh, err := storer.UsePasswordReset(ctx, "reset", now); err != nil || !fresh {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to use a reset token : got %t, %v.", failed, testID, fresh, err)
+			}
+			if fresh, err := storer.UsePasswordReset(ctx, "reset", now); err != nil || fresh {
+				t.Fatalf("\t%s\tTest %d:\tShould report a reused reset token : got %t, %v.", failed, testID, fresh, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only use a reset token once.", success, testID)
+		}
+	}
+}
+
+func testAPIKeys(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	userID := uuid.New()
+
+	t.Log("Given the need to keep API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating, listing and revoking API keys.", testID)
+		{
+			older := user.APIKey{ID: uuid.New(), UserID: userID, Name: "older", Hash: "older", Scopes: []string{"USER"}, DateCreated: now.Add(-time.Hour), DateExpires: now.Add(time.Hour)}
+			newer := user.APIKey{ID: uuid.New(), UserID: userID, Name: "newer", Hash: "newer", Scopes: []string{"USER"}, DateCreated: now, DateExpires: now.Add(time.Hour)}
+			for _, ak := range []user.APIKey{older, newer} {
+				if err := storer.CreateAPIKey(ctx, ak); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create an API key : %v.", failed, testID, err)
+				}
+			}
+
+			got, err := storer.QueryAPIKey(ctx, "older")
+			if err != nil || got.ID != older.ID || len(got.Scopes) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query an API key : got %+v, %v.", failed, testID, got, err)
+			}
+			if _, err := storer.QueryAPIKey(ctx, "unknown"); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown API key : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query an API key.", success, testID)
+
+			keys, err := storer.QueryAPIKeys(ctx, userID)
+			if err != nil || len(keys) != 2 || keys[0].ID != newer.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould list API keys newest first : got %+v, %v.", failed, testID, keys, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list API keys newest first.", success, testID)
+
+			if ok, err := storer.RevokeAPIKey(ctx, uuid.New(), older.ID, now); err != nil || ok {
+				t.Fatalf("\t%s\tTest %d:\tShould not revoke the API key of another user : got %t, %v.", failed, testID, ok, err)
+			}
+			if ok, err := storer.RevokeAPIKey(ctx, userID, older.ID, now); err != nil || !ok {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke an API key : got %t, %v.", failed, testID, ok, err)
+			}
+			if got, err := storer.QueryAPIKey(ctx, "older"); err != nil || got.DateRevoked == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould mark the API key revoked : got %+v, %v.", failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke an API key.", success, testID)
+		}
+	}
+}
+
+func testRoles(t *testing.T, storer user.Storer) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	t.Log("Given the need to define roles.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating, updating and deleting a role.", testID)
+		{
+			rd := user.RoleDefinition{Name: "AUDITOR", Permissions: []string{user.PermAuditRead}, DateCreated: now, DateUpdated: now}
+			created, err := storer.CreateRole(ctx, rd)
+			if err != nil || created.Version == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a role with a version : got %+v, %v.", failed, testID, created, err)
+			}
+			if _, err := storer.CreateRole(ctx, rd); !errors.Is(err, user.ErrRoleExists) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse a taken role name : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a role.", success, testID)
+
+			if _, err := storer.QueryRole(ctx, "UNKNOWN"); !errors.Is(err, user.ErrRoleNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown role : got %v.", failed, testID, err)
+			}
+			roles, err := storer.QueryRoles(ctx)
+			if err != nil || len(roles) != 1 || roles[0].Name != "AUDITOR" {
+				t.Fatalf("\t%s\tTest %d:\tShould list stored roles : got %+v, %v.", failed, testID, roles, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query roles.", success, testID)
+
+			created.Permissions = append(created.Permissions, user.PermUserRead)
+			updated, err := storer.UpdateRole(ctx, created)
+			if err != nil || len(updated.Permissions) != 2 || updated.Version == created.Version {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update a role : got %+v, %v.", failed, testID, updated, err)
+			}
+			if _, err := storer.UpdateRole(ctx, created); !errors.Is(err, user.ErrVersionConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to update a stale role : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update a role.", success, testID)
+
+			if _, err := storer.DeleteRole(ctx, "AUDITOR", created.Version); !errors.Is(err, user.ErrVersionConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to delete a stale role : got %v.", failed, testID, err)
+			}
+			if _, err := storer.DeleteRole(ctx, "AUDITOR", updated.Version); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a role : %v.", failed, testID, err)
+			}
+			if _, err := storer.QueryRole(ctx, "AUDITOR"); !errors.Is(err, user.ErrRoleNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not find a deleted role : got %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete a role.", success, testID)
+		}
+	}
+}
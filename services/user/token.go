@@ -0,0 +1,74 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kjvonly/service/services/errs"
+)
+
+// ErrInvalidToken is returned when a signed token is malformed, tampered
+// with or expired.
+var ErrInvalidToken = errs.New(errs.InvalidArgument, "invalid or expired token")
+
+// signedClaims is the payload of a signed token. Purpose keeps a token
+// issued for one flow from being accepted by another.
+type signedClaims struct {
+	Purpose   string `json:"p"`
+	UserID    string `json:"u"`
+	Email     string `json:"e"`
+	ExpiresAt int64  `json:"x"`
+}
+
+// signToken returns claims encoded and signed with key.
+func signToken(key []byte, claims signedClaims) (string, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encode token: %w", err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(key, payload)), nil
+}
+
+// parseToken verifies the signature and expiry of token and returns its
+// claims if they were issued for purpose.
+func parseToken(key []byte, token string, purpose string, now time.Time) (signedClaims, error) {
+	payload, sig, found := strings.Cut(token, ".")
+	if !found {
+		return signedClaims{}, ErrInvalidToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, sign(key, payload)) {
+		return signedClaims{}, ErrInvalidToken
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return signedClaims{}, ErrInvalidToken
+	}
+
+	var claims signedClaims
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return signedClaims{}, ErrInvalidToken
+	}
+
+	if claims.Purpose != purpose || now.Unix() >= claims.ExpiresAt {
+		return signedClaims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// sign returns the HMAC-SHA256 of payload under key.
+func sign(key []byte, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
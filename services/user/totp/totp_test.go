@@ -0,0 +1,57 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_TOTP(t *testing.T) {
+	// The SHA1 test vectors of RFC 6238, truncated to six digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	t.Log("Given the need to generate time-based one-time passwords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen generating codes for the RFC test vectors.", testID)
+		{
+			for unix, want := range vectors {
+				got, err := Code(secret, Step(time.Unix(unix, 0)))
+				if err != nil || got != want {
+					t.Fatalf("\t%s\tTest %d:\tShould generate %s at %d : got %s, %v.", failed, testID, want, unix, got, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould generate the RFC codes.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen validating codes.", testID)
+		{
+			now := time.Unix(1111111111, 0)
+			previous, _ := Code(secret, Step(now)-1)
+			if step, ok := Validate(secret, previous, now, 1); !ok || step != Step(now)-1 {
+				t.Fatalf("\t%s\tTest %d:\tShould allow a step of skew : got %d, %v.", failed, testID, step, ok)
+			}
+			t.Logf("\t%s\tTest %d:\tShould allow a step of skew.", success, testID)
+
+			old, _ := Code(secret, Step(now)-2)
+			if _, ok := Validate(secret, old, now, 1); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould reject codes outside the skew.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject codes outside the skew.", success, testID)
+		}
+	}
+}
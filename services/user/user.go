@@ -2,26 +2,49 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/mail"
+	"strings"
 	"time"
 
 	"git.launchpad.net/~man4christ/+git/seed/auth"
 	"git.launchpad.net/~man4christ/+git/seed/server"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/audit"
+	"github.com/kjvonly/service/services/errs"
+	"github.com/kjvonly/service/services/user/breach"
+	"github.com/kjvonly/service/services/user/mailer"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	defaultRowsPerPage = 10
+	maxRowsPerPage     = 100
+)
+
+// Set of errors every Storer returns for the same conditions.
+var (
+	ErrNotFound    = errs.New(errs.NotFound, "user not found")
+	ErrUniqueEmail = errs.New(errs.Conflict, "email is not unique")
+)
+
+// ErrInvalidEmail is returned when an email cannot be parsed.
+var ErrInvalidEmail = errs.New(errs.InvalidArgument, "invalid email format")
+
 // UserService is an API for creating users for an app.
 type UserService interface {
 	// CreateUser create a user
 	CreateUser(CreateUserRequest, server.GenericRequest) CreateUserResponse
 	// UpdateUser updates a user
 	UpdateUser(UpdateUserRequest, server.GenericRequest) UpdateUserResponse
-	// DeleteUser deletes a user
+	// DeleteUser marks a user deleted
 	DeleteUser(DeleteUserRequest, server.GenericRequest) DeleteUserResponse
+	// RestoreUser restores a deleted user
+	RestoreUser(RestoreUserRequest, server.GenericRequest) RestoreUserResponse
+	// PurgeUser permanently removes a deleted user
+	PurgeUser(PurgeUserRequest, server.GenericRequest) PurgeUserResponse
 	// QueryUser retrieves a list of existing users
 	QueryUser(QueryUserRequest, server.GenericRequest) QueryUserResponse
 	// QueryByID gets the specified user by id
@@ -32,17 +55,84 @@ type UserService interface {
 	// success it returns a Claims User representing this user. The claims can be
 	// used to generate a token for future authentication.
 	Authenticate(AuthenticateRequest, server.GenericRequest) AuthenticateResponse
+	// UnlockUser clears the failed logins and lockout of a user
+	UnlockUser(UnlockUserRequest, server.GenericRequest) UnlockUserResponse
+	// RequestPasswordReset emails a password reset link to a user
+	RequestPasswordReset(RequestPasswordResetRequest, server.GenericRequest) RequestPasswordResetResponse
+	// ResetPassword sets a new password using an emailed reset token
+	ResetPassword(ResetPasswordRequest, server.GenericRequest) ResetPasswordResponse
+	// VerifyEmail marks the email of a user as verified
+	VerifyEmail(VerifyEmailRequest, server.GenericRequest) VerifyEmailResponse
+	// ResendVerification sends a new email verification link to a user
+	ResendVerification(ResendVerificationRequest, server.GenericRequest) ResendVerificationResponse
+	// EnrollMFA starts two-factor enrollment of the caller
+	EnrollMFA(EnrollMFARequest, server.GenericRequest) EnrollMFAResponse
+	// ConfirmMFA enables two-factor authentication once a code is confirmed
+	ConfirmMFA(ConfirmMFARequest, server.GenericRequest) ConfirmMFAResponse
+	// VerifyMFA completes a two-factor authentication
+	VerifyMFA(VerifyMFARequest, server.GenericRequest) VerifyMFAResponse
+	// RefreshToken exchanges a refresh token for a new access token and
+	// refresh token.
+	RefreshToken(RefreshTokenRequest, server.GenericRequest) RefreshTokenResponse
+	// Logout revokes the session of the caller.
+	Logout(LogoutRequest, server.GenericRequest) LogoutResponse
+	// RevokeAllSessions revokes every session of a user.
+	RevokeAllSessions(RevokeAllSessionsRequest, server.GenericRequest) RevokeAllSessionsResponse
+	// CreateAPIKey mints an API key for the caller
+	CreateAPIKey(CreateAPIKeyRequest, server.GenericRequest) CreateAPIKeyResponse
+	// ListAPIKeys lists the API keys of the caller
+	ListAPIKeys(ListAPIKeysRequest, server.GenericRequest) ListAPIKeysResponse
+	// RevokeAPIKey revokes an API key of the caller
+	RevokeAPIKey(RevokeAPIKeyRequest, server.GenericRequest) RevokeAPIKeyResponse
+	// CreateRole defines a role granting a set of permissions
+	CreateRole(CreateRoleRequest, server.GenericRequest) CreateRoleResponse
+	// UpdateRole changes the permissions a role grants
+	UpdateRole(UpdateRoleRequest, server.GenericRequest) UpdateRoleResponse
+	// DeleteRole removes a role no user holds
+	DeleteRole(DeleteRoleRequest, server.GenericRequest) DeleteRoleResponse
+	// QueryRoles lists every role and the permissions it grants
+	QueryRoles(QueryRolesRequest, server.GenericRequest) QueryRolesResponse
+	// GetMe gets the caller
+	GetMe(GetMeRequest, server.GenericRequest) GetMeResponse
+	// UpdateMe updates the profile of the caller
+	UpdateMe(UpdateMeRequest, server.GenericRequest) UpdateMeResponse
+	// ChangePassword changes the password of the caller
+	ChangePassword(ChangePasswordRequest, server.GenericRequest) ChangePasswordResponse
 }
 
 // Storer interface declares the behavior this package needs to perists and
 // retrieve data.
 type Storer interface {
 	Create(ctx context.Context, usr User) (User, error)
-	Delete(ctx context.Context, email mail.Address) (User, error)
+	Delete(ctx context.Context, id string, version string, now time.Time) (User, error)
+	Restore(ctx context.Context, id string, version string) (User, error)
+	Purge(ctx context.Context, id string, version string) (User, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
 	QueryByID(ctx context.Context, id string) (User, error)
 	QueryByEmail(ctx context.Context, email string) (User, error)
-	Update(ctx context.Context, usr UpdateUser) (User, error)
-	Authenticate(ctx context.Context, email string, password string) (User, error)
+	Query(ctx context.Context, filter QueryFilter, orderBy OrderBy, pageNumber int, rowsPerPage int) ([]User, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+	Update(ctx context.Context, usr User) (User, error)
+	RecordFailedLogin(ctx context.Context, id string, now time.Time) (User, error)
+	CreateRefreshToken(ctx context.Context, rt RefreshToken) error
+	QueryRefreshToken(ctx context.Context, hash string) (RefreshToken, error)
+	UseRefreshToken(ctx context.Context, hash string, now time.Time) (bool, error)
+	RevokeSession(ctx context.Context, sessionID uuid.UUID, now time.Time) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID, now time.Time) error
+	RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keep uuid.UUID, now time.Time) error
+	SessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error)
+	CreatePasswordReset(ctx context.Context, pr PasswordReset) error
+	QueryPasswordReset(ctx context.Context, hash string) (PasswordReset, error)
+	UsePasswordReset(ctx context.Context, hash string, now time.Time) (bool, error)
+	CreateAPIKey(ctx context.Context, ak APIKey) error
+	QueryAPIKey(ctx context.Context, hash string) (APIKey, error)
+	QueryAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID uuid.UUID, id uuid.UUID, now time.Time) (bool, error)
+	CreateRole(ctx context.Context, rd RoleDefinition) (RoleDefinition, error)
+	QueryRole(ctx context.Context, name string) (RoleDefinition, error)
+	QueryRoles(ctx context.Context) ([]RoleDefinition, error)
+	UpdateRole(ctx context.Context, rd RoleDefinition) (RoleDefinition, error)
+	DeleteRole(ctx context.Context, name string, version string) (RoleDefinition, error)
 }
 
 // Required to register endpoints with the Server
@@ -52,89 +142,236 @@ type UserRpcService interface {
 	Register(s *server.Server)
 }
 
+// Config holds the settings of the user service.
+type Config struct {
+	// Mailer delivers the emails sent to users.
+	Mailer mailer.Mailer
+	// ResetURL is the page password reset tokens are sent to.
+	ResetURL string
+	// ResetTokenTTL is how long a password reset token stays valid.
+	ResetTokenTTL time.Duration
+	// VerifyURL is the page email verification tokens are sent to.
+	VerifyURL string
+	// SigningKey signs email verification and MFA challenge tokens. It is
+	// required, and has to be shared by every replica so tokens stay valid
+	// across restarts and instances.
+	SigningKey []byte
+	// VerificationTokenTTL is how long an email verification token stays
+	// valid.
+	VerificationTokenTTL time.Duration
+	// VerificationResendGap is the least time between two verification
+	// emails to the same user.
+	VerificationResendGap time.Duration
+	// RequireVerifiedEmail refuses to authenticate users until they have
+	// verified their email.
+	RequireVerifiedEmail bool
+	// Audit records every change made to users. Changes are only logged
+	// when nil.
+	Audit audit.Recorder
+	// PasswordPolicy holds the rules new passwords have to follow. Unset
+	// settings take their defaults.
+	PasswordPolicy PasswordPolicy
+	// BreachedPasswords refuses passwords known to have been breached.
+	// Passwords are not checked when nil.
+	BreachedPasswords breach.Checker
+}
+
 // Implements interface
 type UserServicer struct {
 	log    *zap.SugaredLogger
 	storer Storer
 	auth   auth.Auth
+	cfg    Config
 }
 
 // Authenticate implements UserRpcService
 func (u UserServicer) Authenticate(req AuthenticateRequest, gr server.GenericRequest) AuthenticateResponse {
+	if err := req.Validate(); err != nil {
+		return AuthenticateResponse{Fault: errs.From(err)}
+	}
 
 	addr, err := mail.ParseAddress(req.Username)
 	if err != nil {
-		return AuthenticateResponse{Error: fmt.Errorf("invalid email format").Error()}
+		return AuthenticateResponse{Fault: errs.From(ErrInvalidEmail)}
+	}
 
+	now := time.Now().UTC()
+
+	// An unknown email is answered like a wrong password, after as long a
+	// comparison, so responses do not tell which accounts exist.
+	usr, err := u.storer.QueryByEmail(gr.Ctx, addr.Address)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		u.log.Infow("authenticate: unknown email", "email", addr.Address)
+		return AuthenticateResponse{Fault: errs.From(ErrAuthenticationFailure)}
+	case err != nil:
+		return AuthenticateResponse{Fault: errs.From(fmt.Errorf("query: %w", err))}
 	}
 
-	usr, err := u.storer.Authenticate(gr.Ctx, *&addr.Address, req.Password)
-	if err != nil {
-		return AuthenticateResponse{Error: err.Error()}
+	// A locked account is refused before its password is checked so
+	// guessing cannot continue during the lockout.
+	if usr.Locked(now) {
+		return AuthenticateResponse{Fault: errs.From(fmt.Errorf("%w until %s", ErrAccountLocked, usr.LockedUntil.Format(time.RFC3339)))}
 	}
 
-	// flatten roles
-	roles := make([]string, 0, len(usr.Roles))
-	for _, value := range usr.Roles {
-		roles = append(roles, value.name)
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(req.Password)); err != nil {
+		after, err := u.storer.RecordFailedLogin(gr.Ctx, usr.ID.String(), now)
+		if err != nil {
+			return AuthenticateResponse{Fault: errs.From(fmt.Errorf("recordfailedlogin: %w", err))}
+		}
+		u.audit(gr, AuditAuthenticateFailed, usr.ID.String(), usr, after)
+		return AuthenticateResponse{Fault: errs.From(ErrAuthenticationFailure)}
 	}
 
-	claims := auth.Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   usr.ID.String(),
-			Issuer:    "kjvonly",
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		},
-		Roles: roles,
+	if !usr.Enabled {
+		return AuthenticateResponse{Fault: errs.From(ErrAccountDisabled)}
 	}
 
-	tkn, err := u.auth.GenerateToken(claims)
-	if err != nil {
-		return AuthenticateResponse{Error: fmt.Errorf("generatetoken: %w", err).Error()}
+	if u.cfg.RequireVerifiedEmail && !usr.EmailVerified {
+		return AuthenticateResponse{Fault: errs.From(ErrEmailNotVerified)}
+	}
+
+	if usr.MFAEnabled {
+		challenge, err := u.mfaChallenge(usr, now)
+		if err != nil {
+			return AuthenticateResponse{Fault: errs.From(err)}
+		}
+		return AuthenticateResponse{MFARequired: true, MFAToken: challenge}
+	}
+
+	if usr.FailedLogins > 0 {
+		usr.unlock()
+		if usr, err = u.storer.Update(gr.Ctx, usr); err != nil {
+			return AuthenticateResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+		}
+	}
 
+	tkns, err := u.issueTokens(gr, usr, uuid.New(), req.Device, now)
+	if err != nil {
+		return AuthenticateResponse{Fault: errs.From(err)}
 	}
+	u.audit(gr, AuditAuthenticate, usr.ID.String(), nil, nil)
 
-	return AuthenticateResponse{Token: tkn}
+	return AuthenticateResponse{Tokens: tkns}
 }
 
 // QueryUserByEmail implements UserRpcService
 func (u UserServicer) QueryUserByEmail(req QueryUserByEmailRequest, gr server.GenericRequest) QueryUserByEmailResponse {
+	if err := req.Validate(); err != nil {
+		return QueryUserByEmailResponse{Fault: errs.From(err)}
+	}
+
 	usr, err := u.storer.QueryByEmail(gr.Ctx, req.Email)
 	if err != nil {
-		return QueryUserByEmailResponse{Error: err.Error()}
+		return QueryUserByEmailResponse{Fault: errs.From(err)}
 	}
 	return QueryUserByEmailResponse{User: usr}
 }
 
 // QueryUserByID implements UserRpcService
 func (u UserServicer) QueryUserByID(req QueryUserByIDRequest, gr server.GenericRequest) QueryUserByIDResponse {
+	if err := req.Validate(); err != nil {
+		return QueryUserByIDResponse{Fault: errs.From(err)}
+	}
+
 	usr, err := u.storer.QueryByID(gr.Ctx, req.ID)
 	if err != nil {
-		return QueryUserByIDResponse{Error: err.Error()}
+		return QueryUserByIDResponse{Fault: errs.From(err)}
 	}
 	return QueryUserByIDResponse{User: usr}
 }
 
 // QueryUser implements UserRpcService
-func (UserServicer) QueryUser(QueryUserRequest, server.GenericRequest) QueryUserResponse {
-	panic("unimplemented")
+func (u UserServicer) QueryUser(req QueryUserRequest, gr server.GenericRequest) QueryUserResponse {
+	if err := req.Validate(); err != nil {
+		return QueryUserResponse{Fault: errs.From(err)}
+	}
+
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.RowsPerPage == 0 {
+		req.RowsPerPage = defaultRowsPerPage
+	}
+	if req.OrderBy == (OrderBy{}) {
+		req.OrderBy = DefaultOrderBy
+	}
+	if req.OrderBy.Direction == "" {
+		req.OrderBy.Direction = ASC
+	}
+
+	usrs, err := u.storer.Query(gr.Ctx, req.Filter, req.OrderBy, req.Page, req.RowsPerPage)
+	if err != nil {
+		return QueryUserResponse{Fault: errs.From(fmt.Errorf("query: %w", err))}
+	}
+
+	total, err := u.storer.Count(gr.Ctx, req.Filter)
+	if err != nil {
+		return QueryUserResponse{Fault: errs.From(fmt.Errorf("count: %w", err))}
+	}
+
+	return QueryUserResponse{
+		Users:       usrs,
+		Total:       total,
+		Page:        req.Page,
+		RowsPerPage: req.RowsPerPage,
+	}
 }
 
-// DeleteUser implements UserRpcService
+// DeleteUser implements UserRpcService. Users are only marked deleted, and
+// can be restored with RestoreUser until they are purged. A deleted user
+// keeps their email until then.
 func (u UserServicer) DeleteUser(req DeleteUserRequest, gr server.GenericRequest) DeleteUserResponse {
-	du, err := u.storer.Delete(gr.Ctx, req.User.Email)
+	if err := req.Validate(); err != nil {
+		return DeleteUserResponse{Fault: errs.From(err)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, req.User.ID.String())
 	if err != nil {
-		return DeleteUserResponse{Error: err.Error()}
+		return DeleteUserResponse{Fault: errs.From(err)}
+	}
+	if err := usr.checkVersion(req.User.Version); err != nil {
+		return DeleteUserResponse{Fault: errs.From(err)}
 	}
+
+	now := time.Now().UTC()
+	du, err := u.storer.Delete(gr.Ctx, usr.ID.String(), usr.Version, now)
+	if err != nil {
+		return DeleteUserResponse{Fault: errs.From(err)}
+	}
+	u.audit(gr, AuditDelete, du.ID.String(), usr, du)
+
+	if err := u.storer.RevokeUserSessions(gr.Ctx, du.ID, now); err != nil {
+		return DeleteUserResponse{Fault: errs.From(fmt.Errorf("revokeusersessions: %w", err))}
+	}
+
 	return DeleteUserResponse{User: du}
 }
 
-// CreateUser implements UserRpcService
+// CreateUser implements UserRpcService. Callers can only give roles whose
+// permissions they hold themselves.
 func (u UserServicer) CreateUser(req CreateUserRequest, gr server.GenericRequest) CreateUserResponse {
+	if err := req.Validate(); err != nil {
+		return CreateUserResponse{Fault: errs.From(err)}
+	}
+
+	nu := req.NewUser
+	fe, err := u.checkPassword(gr.Ctx, nu.Password, nu.PasswordConfirm, nu.Name, nu.Email.Address)
+	if err != nil {
+		return CreateUserResponse{Fault: errs.From(err)}
+	}
+	if len(fe) > 0 {
+		return CreateUserResponse{Fault: errs.From(fe)}
+	}
+
+	if err := u.checkRoles(gr.Ctx, gr.Claims, req.NewUser.Roles); err != nil {
+		return CreateUserResponse{Fault: errs.From(err)}
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewUser.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return CreateUserResponse{Error: fmt.Errorf("generatefrompassword: %w", err).Error()}
+		return CreateUserResponse{Fault: errs.From(fmt.Errorf("generatefrompassword: %w", err))}
 	}
 	usr := User{
 		ID:           uuid.New(),
@@ -149,36 +386,196 @@ func (u UserServicer) CreateUser(req CreateUserRequest, gr server.GenericRequest
 	}
 	result, err := u.storer.Create(gr.Ctx, usr)
 	if err != nil {
-		return CreateUserResponse{Error: err.Error()}
+		return CreateUserResponse{Fault: errs.From(err)}
+	}
+	u.audit(gr, AuditCreate, result.ID.String(), nil, result)
+
+	// The user exists either way; a failed email can be resent.
+	if sent, err := u.sendVerification(gr.Ctx, result, time.Now().UTC()); err != nil {
+		u.log.Errorw("createuser: send verification", "trace_id", gr.Values.TraceID, "ERROR", err)
+	} else {
+		result = sent
 	}
+
 	return CreateUserResponse{User: result}
 }
 
-// UpdateUser implements UserRpcService
+// UpdateUser implements UserRpcService. Callers can only change users, and
+// give roles, whose permissions they hold themselves.
 func (u UserServicer) UpdateUser(req UpdateUserRequest, gr server.GenericRequest) UpdateUserResponse {
-	uu, err := u.storer.Update(gr.Ctx, req.UpdateUser)
+	if err := req.Validate(); err != nil {
+		return UpdateUserResponse{Fault: errs.From(err)}
+	}
+	uu := req.UpdateUser
+
+	usr, err := u.storer.QueryByID(gr.Ctx, req.ID)
+	if err != nil {
+		return UpdateUserResponse{Fault: errs.From(fmt.Errorf("query: id[%s]: %w", req.ID, err))}
+	}
+	if err := usr.checkVersion(req.Version); err != nil {
+		return UpdateUserResponse{Fault: errs.From(err)}
+	}
+	if err := u.checkTarget(gr.Ctx, gr.Claims, usr); err != nil {
+		return UpdateUserResponse{Fault: errs.From(err)}
+	}
+	before := usr
+
+	var emailChanged bool
+	if uu.Email != nil && uu.Email.Address != usr.Email.Address {
+		addr, err := mail.ParseAddress(uu.Email.Address)
+		if err != nil {
+			return UpdateUserResponse{Fault: errs.From(ErrInvalidEmail)}
+		}
+		usr.Email = *addr
+		usr.EmailVerified = false
+		emailChanged = true
+	}
+	if uu.Name != nil {
+		usr.Name = *uu.Name
+	}
+	if uu.Roles != nil {
+		if err := u.checkRoles(gr.Ctx, gr.Claims, uu.Roles); err != nil {
+			return UpdateUserResponse{Fault: errs.From(err)}
+		}
+		usr.Roles = uu.Roles
+	}
+	if uu.Department != nil {
+		usr.Department = *uu.Department
+	}
+	if uu.Enabled != nil {
+		usr.Enabled = *uu.Enabled
+	}
+	if uu.Password != nil {
+		var confirm string
+		if uu.PasswordConfirm != nil {
+			confirm = *uu.PasswordConfirm
+		}
+		fe, err := u.checkPassword(gr.Ctx, *uu.Password, confirm, usr.Name, usr.Email.Address)
+		if err != nil {
+			return UpdateUserResponse{Fault: errs.From(err)}
+		}
+		if len(fe) > 0 {
+			return UpdateUserResponse{Fault: errs.From(fe)}
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return UpdateUserResponse{Fault: errs.From(fmt.Errorf("generatefrompassword: %w", err))}
+		}
+		usr.PasswordHash = hash
+	}
+	usr.DateUpdated = gr.Values.Now
+
+	result, err := u.storer.Update(gr.Ctx, usr)
 	if err != nil {
-		return UpdateUserResponse{Error: err.Error()}
+		return UpdateUserResponse{Fault: errs.From(err)}
 	}
-	return UpdateUserResponse{User: uu}
+	u.audit(gr, AuditUpdate, result.ID.String(), before, result)
+
+	// A new email has to be verified again.
+	if emailChanged {
+		if sent, err := u.sendVerification(gr.Ctx, result, time.Now().UTC()); err != nil {
+			u.log.Errorw("updateuser: send verification", "trace_id", gr.Values.TraceID, "ERROR", err)
+		} else {
+			result = sent
+		}
+	}
+
+	// A disabled user keeps no sessions.
+	if !result.Enabled {
+		if err := u.storer.RevokeUserSessions(gr.Ctx, result.ID, time.Now().UTC()); err != nil {
+			return UpdateUserResponse{Fault: errs.From(fmt.Errorf("revokeusersessions: %w", err))}
+		}
+	}
+
+	return UpdateUserResponse{User: result}
 }
 
-// Register implements UserRpcService
+// UnlockUser implements UserRpcService
+func (u UserServicer) UnlockUser(req UnlockUserRequest, gr server.GenericRequest) UnlockUserResponse {
+	if err := req.Validate(); err != nil {
+		return UnlockUserResponse{Fault: errs.From(err)}
+	}
+
+	usr, err := u.storer.QueryByEmail(gr.Ctx, req.Email)
+	if err != nil {
+		return UnlockUserResponse{Fault: errs.From(fmt.Errorf("query: email[%s]: %w", req.Email, err))}
+	}
+
+	before := usr
+	usr.unlock()
+	usr.DateUpdated = gr.Values.Now
+
+	result, err := u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return UnlockUserResponse{Fault: errs.From(err)}
+	}
+	u.audit(gr, AuditUnlock, result.ID.String(), before, result)
+
+	return UnlockUserResponse{User: result}
+}
+
+// Register implements UserRpcService. Endpoints are registered with the
+// permission they require rather than a role.
 func (us UserServicer) Register(s *server.Server) {
-	s.Register("UserService", "CreateUser", server.RPCEndpoint{Roles: []string{auth.RoleAdmin}, Handler: us.CreateUserHandler})
-	s.Register("UserService", "DeleteUser", server.RPCEndpoint{Roles: []string{auth.RoleAdmin}, Handler: us.DeleteUserHandler})
-	s.Register("UserService", "QueryUserByID", server.RPCEndpoint{Roles: []string{auth.RoleAdmin}, Handler: us.QueryUserByIDHandler})
-	s.Register("UserService", "QueryUserByEmail", server.RPCEndpoint{Roles: []string{auth.RoleAdmin}, Handler: us.QueryUserByEmailHandler})
-	s.Register("UserService", "UpdateUser", server.RPCEndpoint{Roles: []string{auth.RoleAdmin}, Handler: us.UpdateUserHandler})
+	s.Register("UserService", "CreateUser", server.RPCEndpoint{Roles: []string{PermUserWrite}, Handler: us.authorize(PermUserWrite, us.CreateUserHandler)})
+	s.Register("UserService", "DeleteUser", server.RPCEndpoint{Roles: []string{PermUserWrite}, Handler: us.authorize(PermUserWrite, us.DeleteUserHandler)})
+	s.Register("UserService", "RestoreUser", server.RPCEndpoint{Roles: []string{PermUserWrite}, Handler: us.authorize(PermUserWrite, us.RestoreUserHandler)})
+	s.Register("UserService", "PurgeUser", server.RPCEndpoint{Roles: []string{PermUserWrite}, Handler: us.authorize(PermUserWrite, us.PurgeUserHandler)})
+	s.Register("UserService", "QueryUser", server.RPCEndpoint{Roles: []string{PermUserRead}, Handler: us.authorize(PermUserRead, us.QueryUserHandler)})
+	s.Register("UserService", "QueryUserByID", server.RPCEndpoint{Roles: []string{PermUserRead}, Handler: us.authorize(PermUserRead, us.QueryUserByIDHandler)})
+	s.Register("UserService", "QueryUserByEmail", server.RPCEndpoint{Roles: []string{PermUserRead}, Handler: us.authorize(PermUserRead, us.QueryUserByEmailHandler)})
+	s.Register("UserService", "UpdateUser", server.RPCEndpoint{Roles: []string{PermUserWrite}, Handler: us.authorize(PermUserWrite, us.UpdateUserHandler)})
+	s.Register("UserService", "UnlockUser", server.RPCEndpoint{Roles: []string{PermUserWrite}, Handler: us.authorize(PermUserWrite, us.UnlockUserHandler)})
 	s.Register("UserService", "Authenticate", server.RPCEndpoint{Roles: []string{}, Handler: us.AuthenticateHandler})
+	s.Register("UserService", "RequestPasswordReset", server.RPCEndpoint{Roles: []string{}, Handler: us.RequestPasswordResetHandler})
+	s.Register("UserService", "ResetPassword", server.RPCEndpoint{Roles: []string{}, Handler: us.ResetPasswordHandler})
+	s.Register("UserService", "VerifyEmail", server.RPCEndpoint{Roles: []string{}, Handler: us.VerifyEmailHandler})
+	s.Register("UserService", "ResendVerification", server.RPCEndpoint{Roles: []string{}, Handler: us.ResendVerificationHandler})
+	s.Register("UserService", "EnrollMFA", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.EnrollMFAHandler)})
+	s.Register("UserService", "ConfirmMFA", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.ConfirmMFAHandler)})
+	s.Register("UserService", "VerifyMFA", server.RPCEndpoint{Roles: []string{}, Handler: us.VerifyMFAHandler})
+	s.Register("UserService", "RefreshToken", server.RPCEndpoint{Roles: []string{}, Handler: us.RefreshTokenHandler})
+	s.Register("UserService", "Logout", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.LogoutHandler)})
+	s.Register("UserService", "RevokeAllSessions", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.RevokeAllSessionsHandler)})
+	s.Register("UserService", "CreateAPIKey", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.CreateAPIKeyHandler)})
+	s.Register("UserService", "ListAPIKeys", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.ListAPIKeysHandler)})
+	s.Register("UserService", "RevokeAPIKey", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.RevokeAPIKeyHandler)})
+	s.Register("UserService", "GetMe", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.GetMeHandler)})
+	s.Register("UserService", "UpdateMe", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.UpdateMeHandler)})
+	s.Register("UserService", "ChangePassword", server.RPCEndpoint{Roles: []string{PermAccount}, Handler: us.authorize(PermAccount, us.ChangePasswordHandler)})
+	s.Register("UserService", "CreateRole", server.RPCEndpoint{Roles: []string{PermRoleWrite}, Handler: us.authorize(PermRoleWrite, us.CreateRoleHandler)})
+	s.Register("UserService", "UpdateRole", server.RPCEndpoint{Roles: []string{PermRoleWrite}, Handler: us.authorize(PermRoleWrite, us.UpdateRoleHandler)})
+	s.Register("UserService", "DeleteRole", server.RPCEndpoint{Roles: []string{PermRoleWrite}, Handler: us.authorize(PermRoleWrite, us.DeleteRoleHandler)})
+	s.Register("UserService", "QueryRoles", server.RPCEndpoint{Roles: []string{PermRoleRead}, Handler: us.authorize(PermRoleRead, us.QueryRolesHandler)})
 }
 
 // Create new UserServicer
-func NewUserServicer(log *zap.SugaredLogger, storer Storer, a auth.Auth) UserRpcService {
+func NewUserServicer(log *zap.SugaredLogger, storer Storer, a auth.Auth, cfg Config) UserRpcService {
+	if cfg.Mailer == nil {
+		cfg.Mailer = mailer.NewFile(log, "", mail.Address{})
+	}
+	if cfg.Audit == nil {
+		cfg.Audit = audit.NewLogRecorder(log)
+	}
+	cfg.PasswordPolicy = cfg.PasswordPolicy.withDefaults()
+	if cfg.ResetTokenTTL == 0 {
+		cfg.ResetTokenTTL = defaultResetTokenTTL
+	}
+	if len(cfg.SigningKey) == 0 {
+		log.Panic("no signing key configured")
+	}
+	if cfg.VerificationTokenTTL == 0 {
+		cfg.VerificationTokenTTL = defaultVerificationTokenTTL
+	}
+	if cfg.VerificationResendGap == 0 {
+		cfg.VerificationResendGap = defaultVerificationResendGap
+	}
+
 	return UserServicer{
 		log:    log,
 		storer: storer,
 		auth:   a,
+		cfg:    cfg,
 	}
 }
 
@@ -187,43 +584,152 @@ type CreateUserRequest struct {
 	NewUser NewUser `json:"newUser"`
 }
 
+// Validate checks the fields of the request hold usable values. The
+// password is checked against the password policy by CreateUser.
+func (r CreateUserRequest) Validate() error {
+	nu := r.NewUser
+
+	var fe errs.FieldErrors
+	checkRequired(&fe, "name", strings.TrimSpace(nu.Name))
+	checkLength(&fe, "name", nu.Name, maxNameLength)
+	checkEmail(&fe, "email", nu.Email.Address)
+	checkRoleNames(&fe, "roles", nu.Roles)
+	checkLength(&fe, "department", nu.Department, maxDepartmentLength)
+	return fe.Err()
+}
+
 // CreateUserResponse is the response object containing a UserService.CreateUser.
 type CreateUserResponse struct {
-	User  User   `json:"user"`
-	Error string `json:"error,omitempty"`
+	User User `json:"user"`
+	errs.Fault
 }
 
+// UpdateUserRequest is the request object for UserService.UpdateUser.
+// Version is the version of the user the changes were made against.
 type UpdateUserRequest struct {
+	ID         string     `json:"id"`
+	Version    string     `json:"version"`
 	UpdateUser UpdateUser `json:"user"`
 }
+
+// Validate checks the fields of the request hold usable values. Only the
+// fields being changed are checked.
+func (r UpdateUserRequest) Validate() error {
+	uu := r.UpdateUser
+
+	var fe errs.FieldErrors
+	checkID(&fe, "id", r.ID)
+	if uu.Name != nil {
+		checkRequired(&fe, "name", strings.TrimSpace(*uu.Name))
+		checkLength(&fe, "name", *uu.Name, maxNameLength)
+	}
+	if uu.Email != nil {
+		checkEmail(&fe, "email", uu.Email.Address)
+	}
+	checkRoleNames(&fe, "roles", uu.Roles)
+	if uu.Department != nil {
+		checkLength(&fe, "department", *uu.Department, maxDepartmentLength)
+	}
+	return fe.Err()
+}
+
+// UpdateUserResponse is the response object for UserService.UpdateUser.
 type UpdateUserResponse struct {
-	User  User   `json:"user"`
-	Error string `json:"error,omitempty"`
+	User User `json:"user"`
+	errs.Fault
+}
+
+// UnlockUserRequest is the request object for UserService.UnlockUser.
+type UnlockUserRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r UnlockUserRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkEmail(&fe, "email", r.Email)
+	return fe.Err()
 }
 
-// DeleteUserRequest is the request object for UserService.DeleteUser.
+// UnlockUserResponse is the response object for UserService.UnlockUser.
+type UnlockUserResponse struct {
+	User User `json:"user"`
+	errs.Fault
+}
+
+// DeleteUserRequest is the request object for UserService.DeleteUser. The
+// ID and Version of User identify the user and the version deleted.
 type DeleteUserRequest struct {
 	User User `json:"user"`
 }
 
+// Validate checks the fields of the request hold usable values.
+func (r DeleteUserRequest) Validate() error {
+	var fe errs.FieldErrors
+	if r.User.ID == uuid.Nil {
+		fe.Add("id", "is required")
+	}
+	return fe.Err()
+}
+
 // DeleteUserResponse is the response object for UserService.DeleteUser.
 type DeleteUserResponse struct {
-	User  User   `json:"user"`
-	Error string `json:"error,omitempty"`
+	User User `json:"user"`
+	errs.Fault
 }
 
-type QueryUserRequest struct{}
-type QueryUserResponse struct{}
+// QueryUserRequest is the request object for UserService.QueryUser.
+type QueryUserRequest struct {
+	Filter      QueryFilter `json:"filter"`
+	OrderBy     OrderBy     `json:"orderBy"`
+	Page        int         `json:"page"`
+	RowsPerPage int         `json:"rowsPerPage"`
+}
+
+// Validate checks the fields of the request hold usable values. Zero
+// values of Page, RowsPerPage and OrderBy stand for their defaults.
+func (r QueryUserRequest) Validate() error {
+	var fe errs.FieldErrors
+	if r.Page < 0 {
+		fe.Add("page", "must be positive")
+	}
+	if r.RowsPerPage < 0 || r.RowsPerPage > maxRowsPerPage {
+		fe.Addf("rowsPerPage", "must be between 1 and %d", maxRowsPerPage)
+	}
+	r.Filter.check(&fe)
+	r.OrderBy.check(&fe)
+
+	if len(fe) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidQuery, fe)
+	}
+	return nil
+}
+
+// QueryUserResponse is the response object for UserService.QueryUser.
+type QueryUserResponse struct {
+	Users       []User `json:"users"`
+	Total       int    `json:"total"`
+	Page        int    `json:"page"`
+	RowsPerPage int    `json:"rowsPerPage"`
+	errs.Fault
+}
 
 // QueryUserByIDRequest is the request object for UserService.QueryUserByID.
 type QueryUserByIDRequest struct {
 	ID string `json:"id"`
 }
 
+// Validate checks the fields of the request hold usable values.
+func (r QueryUserByIDRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkID(&fe, "id", r.ID)
+	return fe.Err()
+}
+
 // QueryUserByIDResponse is the response object for UserService.QueryUserByID.
 type QueryUserByIDResponse struct {
-	User  User   `json:"user"`
-	Error string `json:"error,omitempty"`
+	User User `json:"user"`
+	errs.Fault
 }
 
 // QueryUserByEmailRequest is the request object for UserService.QueryUserByEmail.
@@ -231,18 +737,42 @@ type QueryUserByEmailRequest struct {
 	Email string `json:"email"`
 }
 
+// Validate checks the fields of the request hold usable values.
+func (r QueryUserByEmailRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkEmail(&fe, "email", r.Email)
+	return fe.Err()
+}
+
 // QueryUserByEmailResponse is the response object for UserService.QueryUserByEmail.
 type QueryUserByEmailResponse struct {
-	User  User   `json:"user"`
-	Error string `json:"error,omitempty"`
+	User User `json:"user"`
+	errs.Fault
 }
 
+// AuthenticateRequest is the request object for UserService.Authenticate.
+// Username is the email of the user.
 type AuthenticateRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Device   string `json:"device"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r AuthenticateRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkEmail(&fe, "username", r.Username)
+	checkRequired(&fe, "password", r.Password)
+	checkLength(&fe, "device", r.Device, maxDeviceLength)
+	return fe.Err()
 }
 
+// AuthenticateResponse carries the tokens of the new session, or when the
+// user has two-factor authentication enabled, the MFA challenge to pass to
+// VerifyMFA.
 type AuthenticateResponse struct {
-	Token string `json:"token"`
-	Error string `json:"error,omitempty"`
+	Tokens
+	MFARequired bool   `json:"mfaRequired,omitempty"`
+	MFAToken    string `json:"mfaToken,omitempty"`
+	errs.Fault
 }
@@ -2,56 +2,60 @@ package user_test
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"net/mail"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"git.launchpad.net/~man4christ/+git/seed/auth"
+	"git.launchpad.net/~man4christ/+git/seed/keystore"
 	"git.launchpad.net/~man4christ/+git/seed/server"
 	"git.launchpad.net/~man4christ/+git/seed/values"
-	"git.launchpad.net/~man4christ/+git/stem/data/nosql/dbtest"
-	"git.launchpad.net/~man4christ/+git/stem/docker"
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/audit"
+	auditStore "github.com/kjvonly/service/services/audit/stores/memory"
+	"github.com/kjvonly/service/services/errs"
 	"github.com/kjvonly/service/services/user"
-	"github.com/kjvonly/service/services/user/stores/nosql"
+	"github.com/kjvonly/service/services/user/mailer"
+	"github.com/kjvonly/service/services/user/stores/memory"
+	"github.com/kjvonly/service/services/user/totp"
+	"go.uber.org/zap"
 )
 
-var c *docker.Container
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
 
-func TestMain(m *testing.M) {
-	var err error
-	c, err = dbtest.StartDB()
+func Test_User(t *testing.T) {
+	log := zap.NewNop().Sugar()
+	ks, err := keystore.NewFS(os.DirFS("../../zarf/keys"))
 	if err != nil {
-		fmt.Println(err)
-		return
+		t.Fatalf("opening keystore: %s", err)
 	}
-	defer dbtest.StopDB(c)
-
-	m.Run()
-}
-
-func Test_User(t *testing.T) {
-	b, _ := os.ReadFile("../../testdata/collections.txt")
-	cols := strings.Split(string(b), "\n")
-	b, _ = os.ReadFile("../../testdata/seed.txt")
-	seed := string(b)
-
-	d := dbtest.Data{
-		CollectionData: cols,
-		SeedAql:        seed,
+	authSvc, err := auth.New("54bb2165-71e1-41a6-af3e-7da4a0e1e2c1", ks)
+	if err != nil {
+		t.Fatalf("constructing auth: %s", err)
 	}
+	storer := memory.NewStore(log)
 
-	test := dbtest.NewIntegration(t, c, "testuser", d)
-	log := test.Log
-	db := test.DB
-	teardown := test.Teardown
-	authSvc := test.Auth
-	t.Cleanup(teardown)
-	storer := nosql.NewStore(log, db)
+	auditor := audit.NewAuditServicer(log, auditStore.NewStore(log), storer, *authSvc)
 
-	core := user.NewUserServicer(log, storer, *authSvc)
+	mailDir := t.TempDir()
+	core := user.NewUserServicer(log, storer, *authSvc, user.Config{
+		Mailer:               mailer.NewFile(log, mailDir, mail.Address{Address: "no-reply@example.com"}),
+		ResetURL:             "http://localhost/reset-password",
+		VerifyURL:            "http://localhost/verify-email",
+		SigningKey:           []byte("test signing key"),
+		RequireVerifiedEmail: true,
+		Audit:                auditor,
+	})
 
 	t.Log("Given the need to work with User records.")
 	{
@@ -62,26 +66,75 @@ func Test_User(t *testing.T) {
 			now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
 			email, err := mail.ParseAddress("user@example.com")
 			if err != nil {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to parse email: %s.", dbtest.Failed, testID, err)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse email: %s.", failed, testID, err)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to parse email.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould be able to parse email.", success, testID)
 
 			nu := user.CreateUserRequest{}
 			nu.NewUser.Name = "John Doe"
 			nu.NewUser.Email = *email
 			nu.NewUser.Roles = []user.Role{user.RoleAdmin}
-			nu.NewUser.Password = "gophers"
-			nu.NewUser.PasswordConfirm = "gophers"
+			nu.NewUser.Password = "Gophers4Ever"
+			nu.NewUser.PasswordConfirm = "Gophers4Ever"
 
-			cuUsr := core.CreateUser(nu, server.GenericRequest{
+			bad := nu
+			bad.NewUser.Name = " "
+			bad.NewUser.Email = mail.Address{Address: "not an email"}
+			badUsr := core.CreateUser(bad, server.GenericRequest{
 				Ctx:    ctx,
 				Claims: auth.Claims{},
 				Values: &values.Values{Now: now},
 			})
+			if badUsr.Code != errs.InvalidArgument || len(badUsr.Fields) != 2 || badUsr.Fields[0].Field != "name" || badUsr.Fields[1].Field != "email" {
+				t.Fatalf("\t%s\tTest %d:\tShould list the invalid fields of a request %+v : got %+v.", failed, testID, bad, badUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the invalid fields of a request.", success, testID)
+
+			weak := nu
+			weak.NewUser.Password = "johndoe"
+			weak.NewUser.PasswordConfirm = "johndoe2"
+			wkUsr := core.CreateUser(weak, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+			if !strings.HasPrefix(wkUsr.Error, errs.ErrInvalidFields.Error()) || len(wkUsr.Fields) != 3 || wkUsr.Fields[2].Field != "password_confirm" {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse passwords breaking the policy %+v : got %+v.", failed, testID, weak, wkUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse passwords breaking the policy.", success, testID)
+
+			cuUsr := core.CreateUser(nu, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
 			if cuUsr.User.Name != "John Doe" {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to create user %+v : got %+v.", dbtest.Failed, testID, nu, cuUsr)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user %+v : got %+v.", failed, testID, nu, cuUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create user.", success, testID)
+
+			b, err := json.Marshal(cuUsr)
+			if err != nil || strings.Contains(string(b), "password_hash") {
+				t.Fatalf("\t%s\tTest %d:\tShould not return the password hash : got %s, %v.", failed, testID, b, err)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to create user.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould not return the password hash.", success, testID)
+
+			// audit log
+			target := cuUsr.User.ID.String()
+			action := user.AuditCreate
+			al := auditor.QueryAuditLog(audit.QueryAuditLogRequest{Filter: audit.QueryFilter{Target: &target, Action: &action}}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+
+			if al.Error != "" || al.Total != 1 || al.Entries[0].Changes["name"].After != "John Doe" {
+				t.Fatalf("\t%s\tTest %d:\tShould record the creation in the audit log : got %+v.", failed, testID, al)
+			}
+			if c := al.Entries[0].Changes["password_hash"]; c.After != audit.Redacted {
+				t.Fatalf("\t%s\tTest %d:\tShould redact the password hash in the audit log : got %+v.", failed, testID, c)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the creation in the audit log.", success, testID)
 
 			// query user by id
 			qu := user.QueryUserByIDRequest{ID: cuUsr.User.ID.String()}
@@ -92,9 +145,9 @@ func Test_User(t *testing.T) {
 			})
 
 			if quUsr.User.ID != cuUsr.User.ID && quUsr.User.Email != cuUsr.User.Email {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to query user by id %+v : got %+v.", dbtest.Failed, testID, qu, quUsr)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query user by id %+v : got %+v.", failed, testID, qu, quUsr)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to query user by id.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould be able to query user by id.", success, testID)
 
 			// query user by email
 			que := user.QueryUserByEmailRequest{Email: cuUsr.User.Email.Address}
@@ -105,10 +158,41 @@ func Test_User(t *testing.T) {
 			})
 
 			if queUsr.User.ID != cuUsr.User.ID && queUsr.User.Email != cuUsr.User.Email {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to query user by email %+v : got %+v.", dbtest.Failed, testID, que, queUsr)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query user by email %+v : got %+v.", failed, testID, que, queUsr)
+			}
+
+			t.Logf("\t%s\tTest %d:\tShould be able to query user by email.", success, testID)
+
+			// query users
+			name := "john"
+			qus := user.QueryUserRequest{
+				Filter:      user.QueryFilter{Name: &name},
+				OrderBy:     user.OrderBy{Field: user.OrderByName, Direction: user.DESC},
+				Page:        1,
+				RowsPerPage: 10,
 			}
+			qusUsrs := core.QueryUser(qus, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if qusUsrs.Error != "" || qusUsrs.Total != 1 || len(qusUsrs.Users) != 1 || qusUsrs.Users[0].ID != cuUsr.User.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query users %+v : got %+v.", failed, testID, qus, qusUsrs)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query users.", success, testID)
+
+			qus = user.QueryUserRequest{OrderBy: user.OrderBy{Field: "password_hash", Direction: user.ASC}}
+			qusUsrs = core.QueryUser(qus, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
 
-			t.Logf("\t%s\tTest %d:\tShould be able to query user by email.", dbtest.Success, testID)
+			if !strings.Contains(qusUsrs.Error, user.ErrInvalidQuery.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject unknown order fields %+v : got %+v.", failed, testID, qus, qusUsrs)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject unknown order fields.", success, testID)
 
 			// update user
 			var updateName string = "updated user name"
@@ -116,7 +200,7 @@ func Test_User(t *testing.T) {
 				Email: &cuUsr.User.Email,
 				Name:  &updateName,
 			}
-			uu := user.UpdateUserRequest{UpdateUser: uusr}
+			uu := user.UpdateUserRequest{ID: cuUsr.User.ID.String(), Version: cuUsr.User.Version, UpdateUser: uusr}
 			uuUsr := core.UpdateUser(uu, server.GenericRequest{
 				Ctx:    ctx,
 				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
@@ -124,14 +208,76 @@ func Test_User(t *testing.T) {
 			})
 
 			if uuUsr.User.Email.Address != uu.UpdateUser.Email.Address || uuUsr.User.ID != cuUsr.User.ID {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to update user %+v : got %+v.", dbtest.Failed, testID, uu, uuUsr)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update user %+v : got %+v.", failed, testID, uu, uuUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update user.", success, testID)
+
+			stale := core.UpdateUser(uu, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+
+			if stale.Error != user.ErrVersionConflict.Error() || stale.Code != errs.Conflict {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to update a stale version %+v : got %+v.", failed, testID, uu, stale)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to update user.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould refuse to update a stale version.", success, testID)
+
+			// verify email
+			unverified := core.Authenticate(user.AuthenticateRequest{Username: email.Address, Password: "Gophers4Ever"}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if unverified.Error != user.ErrEmailNotVerified.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould forbid unverified user : got %+v.", failed, testID, unverified)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forbid unverified user.", success, testID)
+
+			rs := core.ResendVerification(user.ResendVerificationRequest{Email: email.Address}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if !strings.HasPrefix(rs.Error, user.ErrVerificationThrottled.Error()) || rs.Code != errs.ResourceExhausted {
+				t.Fatalf("\t%s\tTest %d:\tShould throttle verification emails : got %+v.", failed, testID, rs)
+			}
+			t.Logf("\t%s\tTest %d:\tShould throttle verification emails.", success, testID)
+
+			vtoken, err := mailedToken(mailDir, "http://localhost/verify-email")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould email a verification token : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould email a verification token.", success, testID)
+
+			ve := core.VerifyEmail(user.VerifyEmailRequest{Token: vtoken}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if ve.Error != "" || !ve.Verified {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to verify email : got %+v.", failed, testID, ve)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to verify email.", success, testID)
+
+			ve = core.VerifyEmail(user.VerifyEmailRequest{Token: vtoken + "x"}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if ve.Error != user.ErrInvalidToken.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a tampered token : got %+v.", failed, testID, ve)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a tampered token.", success, testID)
 
 			// authenticat user
 			au := user.AuthenticateRequest{
 				Username: email.Address,
-				Password: "gophers",
+				Password: "Gophers4Ever",
 			}
 
 			auUsr := core.Authenticate(au, server.GenericRequest{
@@ -141,9 +287,281 @@ func Test_User(t *testing.T) {
 			})
 
 			if len(auUsr.Token) == 0 {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate user %+v : got %+v.", dbtest.Failed, testID, au, auUsr)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate user %+v : got %+v.", failed, testID, au, auUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate user.", success, testID)
+
+			// refresh token
+			rt := user.RefreshTokenRequest{RefreshToken: auUsr.RefreshToken}
+			rtTkns := core.RefreshToken(rt, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if rtTkns.Error != "" || len(rtTkns.Token) == 0 || rtTkns.RefreshToken == auUsr.RefreshToken {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to refresh token %+v : got %+v.", failed, testID, rt, rtTkns)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to refresh token.", success, testID)
+
+			reused := core.RefreshToken(rt, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if reused.Error != user.ErrRefreshTokenReused.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould detect refresh token reuse %+v : got %+v.", failed, testID, rt, reused)
+			}
+			t.Logf("\t%s\tTest %d:\tShould detect refresh token reuse.", success, testID)
+
+			rotated := core.RefreshToken(user.RefreshTokenRequest{RefreshToken: rtTkns.RefreshToken}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if rotated.Error != user.ErrSessionRevoked.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the session on reuse : got %+v.", failed, testID, rotated)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the session on reuse.", success, testID)
+
+			// reset password
+			rpr := user.RequestPasswordResetRequest{Email: email.Address}
+			rprResp := core.RequestPasswordReset(rpr, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			token, err := mailedToken(mailDir, "http://localhost/reset-password")
+			if rprResp.Error != "" || err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to email a reset token %+v : got %+v, %v.", failed, testID, rpr, rprResp, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to email a reset token.", success, testID)
+
+			rp := user.ResetPasswordRequest{Token: token, Password: "Gophers4Ever", PasswordConfirm: "Gophers4Ever"}
+			rpResp := core.ResetPassword(rp, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if rpResp.Error != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reset password %+v : got %+v.", failed, testID, rp, rpResp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reset password.", success, testID)
+
+			rpResp = core.ResetPassword(rp, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if rpResp.Error != user.ErrInvalidResetToken.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould not reuse a reset token %+v : got %+v.", failed, testID, rp, rpResp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not reuse a reset token.", success, testID)
+
+			// self service
+			meClaims := auth.Claims{Roles: []string{auth.RoleUser}}
+			meClaims.Subject = cuUsr.User.ID.String()
+			meClaims.ID = uuid.New().String()
+
+			me := core.GetMe(user.GetMeRequest{}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: meClaims,
+				Values: &values.Values{Now: now},
+			})
+
+			if me.Error != "" || me.User.ID != cuUsr.User.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to get own user : got %+v.", failed, testID, me)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to get own user.", success, testID)
+
+			dept := "Translation"
+			um := user.UpdateMeRequest{Version: me.User.Version, UpdateMe: user.UpdateMe{Department: &dept, Preferences: map[string]string{"translation": "KJV"}}}
+			umResp := core.UpdateMe(um, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: meClaims,
+				Values: &values.Values{Now: now},
+			})
+
+			if umResp.Error != "" || umResp.User.Department != dept || umResp.User.Preferences["translation"] != "KJV" || len(umResp.User.Roles) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update own profile %+v : got %+v.", failed, testID, um, umResp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update own profile.", success, testID)
+
+			other := core.Authenticate(au, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+			if other.Error != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate user : got %+v.", failed, testID, other)
+			}
+
+			cp := user.ChangePasswordRequest{CurrentPassword: "wrong password", Password: "Gophers4Ever2", PasswordConfirm: "Gophers4Ever2"}
+			if resp := core.ChangePassword(cp, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: meClaims,
+				Values: &values.Values{Now: now},
+			}); !strings.Contains(resp.Error, user.ErrAuthenticationFailure.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould require the current password %+v : got %+v.", failed, testID, cp, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require the current password.", success, testID)
+
+			for _, cp := range []user.ChangePasswordRequest{
+				{CurrentPassword: "Gophers4Ever", Password: "Gophers4Ever2", PasswordConfirm: "Gophers4Ever2"},
+				{CurrentPassword: "Gophers4Ever2", Password: "Gophers4Ever", PasswordConfirm: "Gophers4Ever"},
+			} {
+				if resp := core.ChangePassword(cp, server.GenericRequest{
+					Ctx:    ctx,
+					Claims: meClaims,
+					Values: &values.Values{Now: now},
+				}); resp.Error != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to change password %+v : got %+v.", failed, testID, cp, resp)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to change password.", success, testID)
+
+			if resp := core.RefreshToken(user.RefreshTokenRequest{RefreshToken: other.RefreshToken}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			}); resp.Error != user.ErrSessionRevoked.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke other sessions on password change : got %+v.", failed, testID, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke other sessions on password change.", success, testID)
+
+			// enroll in two-factor authentication
+			mfaClaims := auth.Claims{Roles: []string{auth.RoleAdmin}}
+			mfaClaims.Subject = cuUsr.User.ID.String()
+
+			en := core.EnrollMFA(user.EnrollMFARequest{}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: mfaClaims,
+				Values: &values.Values{Now: now},
+			})
+
+			if en.Error != "" || en.Secret == "" || !strings.HasPrefix(en.URI, "otpauth://totp/") {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enroll in MFA : got %+v.", failed, testID, en)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to enroll in MFA.", success, testID)
+
+			code, _ := totp.Code(en.Secret, totp.Step(time.Now()))
+			cm := core.ConfirmMFA(user.ConfirmMFARequest{Code: code}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: mfaClaims,
+				Values: &values.Values{Now: now},
+			})
+
+			if cm.Error != "" || len(cm.RecoveryCodes) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to confirm MFA : got %+v.", failed, testID, cm)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to confirm MFA.", success, testID)
+
+			ch := core.Authenticate(au, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if ch.Error != "" || !ch.MFARequired || ch.MFAToken == "" || ch.Token != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould return an MFA challenge : got %+v.", failed, testID, ch)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return an MFA challenge.", success, testID)
+
+			vm := user.VerifyMFARequest{MFAToken: ch.MFAToken, RecoveryCode: cm.RecoveryCodes[0]}
+			vmTkns := core.VerifyMFA(vm, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if vmTkns.Error != "" || len(vmTkns.Token) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to verify MFA with a recovery code : got %+v.", failed, testID, vmTkns)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to verify MFA with a recovery code.", success, testID)
+
+			vmTkns = core.VerifyMFA(vm, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if vmTkns.Error != user.ErrInvalidMFACode.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould not reuse a recovery code : got %+v.", failed, testID, vmTkns)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to authenticate user.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould not reuse a recovery code.", success, testID)
+
+			// api keys
+			ck := user.CreateAPIKeyRequest{Name: "batch", Scopes: []string{auth.RoleAdmin}}
+			ckResp := core.CreateAPIKey(ck, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: mfaClaims,
+				Values: &values.Values{Now: now},
+			})
+
+			if ckResp.Error != "" || !strings.HasPrefix(ckResp.Key, ckResp.APIKey.Prefix) {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an api key %+v : got %+v.", failed, testID, ck, ckResp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create an api key.", success, testID)
+
+			ck = user.CreateAPIKeyRequest{Name: "escalate", Scopes: []string{"SUPERUSER"}}
+			if resp := core.CreateAPIKey(ck, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: mfaClaims,
+				Values: &values.Values{Now: now},
+			}); !strings.Contains(resp.Error, user.ErrInvalidAPIKeyReq.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject scopes beyond the caller's roles %+v : got %+v.", failed, testID, ck, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject scopes beyond the caller's roles.", success, testID)
+
+			lk := core.ListAPIKeys(user.ListAPIKeysRequest{}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: mfaClaims,
+				Values: &values.Values{Now: now},
+			})
+
+			if lk.Error != "" || len(lk.APIKeys) != 1 || lk.APIKeys[0].ID != ckResp.APIKey.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list api keys : got %+v.", failed, testID, lk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to list api keys.", success, testID)
+
+			var bearer string
+			apiKeys := user.NewAPIKeyMiddleware(log, storer, *authSvc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bearer = r.Header.Get("Authorization")
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/BibleSearchService.Search", nil)
+			r.Header.Set(user.APIKeyHeader, ckResp.Key)
+			w := httptest.NewRecorder()
+			apiKeys.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK || !strings.HasPrefix(bearer, "Bearer ") {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate requests with an api key : got %d %q.", failed, testID, w.Code, bearer)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate requests with an api key.", success, testID)
+
+			rk := user.RevokeAPIKeyRequest{ID: ckResp.APIKey.ID.String()}
+			if resp := core.RevokeAPIKey(rk, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: mfaClaims,
+				Values: &values.Values{Now: now},
+			}); resp.Error != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke an api key %+v : got %+v.", failed, testID, rk, resp)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/v1/BibleSearchService.Search", nil)
+			r.Header.Set(user.APIKeyHeader, ckResp.Key)
+			w = httptest.NewRecorder()
+			apiKeys.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse a revoked api key : got %d.", failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse a revoked api key.", success, testID)
 
 			// authenticat user
 			auf := user.AuthenticateRequest{
@@ -157,24 +575,311 @@ func Test_User(t *testing.T) {
 				Values: &values.Values{Now: now},
 			})
 
-			if aufUsr.Error != "comparehashandpassword: authentication failed" {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to forbid failed authenticated user %+v : got %+v.", dbtest.Failed, testID, auf, aufUsr)
+			if aufUsr.Error != user.ErrAuthenticationFailure.Error() || aufUsr.Code != errs.Unauthenticated {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to forbid failed authenticated user %+v : got %+v.", failed, testID, auf, aufUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to forbid failed authenticated user.", success, testID)
+
+			unknown := core.Authenticate(user.AuthenticateRequest{Username: "nobody@example.com", Password: "wrong password"}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+			if unknown.Error != aufUsr.Error || unknown.Code != aufUsr.Code || len(unknown.Fields) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould answer an unknown email like a wrong password : got %+v, want %+v.", failed, testID, unknown.Fault, aufUsr.Fault)
+			}
+			t.Logf("\t%s\tTest %d:\tShould answer an unknown email like a wrong password.", success, testID)
+
+			// lock out user
+			for i := 0; i < 4; i++ {
+				core.Authenticate(auf, server.GenericRequest{
+					Ctx:    ctx,
+					Claims: auth.Claims{},
+					Values: &values.Values{Now: now},
+				})
+			}
+
+			lkUsr := core.Authenticate(au, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if !strings.HasPrefix(lkUsr.Error, user.ErrAccountLocked.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to lock out user %+v : got %+v.", failed, testID, au, lkUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to lock out user.", success, testID)
+
+			// unlock user
+			ul := user.UnlockUserRequest{Email: email.Address}
+			ulUsr := core.UnlockUser(ul, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+
+			if ulUsr.Error != "" || ulUsr.User.FailedLogins != 0 || ulUsr.User.Locked(time.Now()) {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unlock user %+v : got %+v.", failed, testID, ul, ulUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unlock user.", success, testID)
+
+			// disable user
+			cur := core.QueryUserByID(user.QueryUserByIDRequest{ID: cuUsr.User.ID.String()}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+
+			disabled := false
+			dsUsr := core.UpdateUser(user.UpdateUserRequest{ID: cuUsr.User.ID.String(), Version: cur.User.Version, UpdateUser: user.UpdateUser{Enabled: &disabled}}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+			if dsUsr.Error != "" || dsUsr.User.Enabled {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to disable user : got %+v.", failed, testID, dsUsr)
+			}
+
+			dsAuth := core.Authenticate(au, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if dsAuth.Error != user.ErrAccountDisabled.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould forbid disabled user %+v : got %+v.", failed, testID, au, dsAuth)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forbid disabled user.", success, testID)
+
+			// change email
+			renamed := mail.Address{Address: "renamed@example.com"}
+			ceUsr := core.UpdateUser(user.UpdateUserRequest{ID: cuUsr.User.ID.String(), Version: dsUsr.User.Version, UpdateUser: user.UpdateUser{Email: &renamed}}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+
+			if ceUsr.Error != "" || ceUsr.User.ID != cuUsr.User.ID || ceUsr.User.Email.Address != renamed.Address || ceUsr.User.EmailVerified {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to change the email of a user : got %+v.", failed, testID, ceUsr)
+			}
+
+			queUsr = core.QueryUserByEmail(user.QueryUserByEmailRequest{Email: renamed.Address}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+			if queUsr.Error != "" || queUsr.User.ID != cuUsr.User.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould find the user by their new email : got %+v.", failed, testID, queUsr)
+			}
+
+			queUsr = core.QueryUserByEmail(user.QueryUserByEmailRequest{Email: email.Address}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+			if queUsr.Error != user.ErrNotFound.Error() || queUsr.Code != errs.NotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould not find the user by their old email : got %+v.", failed, testID, queUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to change the email of a user.", success, testID)
+
+			if _, err := mailedToken(mailDir, "verify-email"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould send a verification email to the new email : %s.", failed, testID, err)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to forbid failed authenticated user.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould send a verification email to the new email.", success, testID)
 
 			// delete user
 			du := user.DeleteUserRequest{User: cuUsr.User}
+			if resp := core.DeleteUser(du, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			}); resp.Error != user.ErrVersionConflict.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to delete a stale version %+v : got %+v.", failed, testID, du, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to delete a stale version.", success, testID)
+
+			du = user.DeleteUserRequest{User: ceUsr.User}
 			duUsr := core.DeleteUser(du, server.GenericRequest{
 				Ctx:    ctx,
 				Claims: auth.Claims{},
 				Values: &values.Values{Now: now},
 			})
 
-			if duUsr.User.ID != cuUsr.User.ID {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user %+v : got %+v.", dbtest.Failed, testID, du, duUsr)
+			if duUsr.Error != "" || duUsr.User.ID != cuUsr.User.ID || duUsr.User.DateDeleted == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user %+v : got %+v.", failed, testID, du, duUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", success, testID)
+
+			quUsr = core.QueryUserByID(qu, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{},
+				Values: &values.Values{Now: now},
+			})
+
+			if quUsr.Error != user.ErrNotFound.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould not find a deleted user %+v : got %+v.", failed, testID, qu, quUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not find a deleted user.", success, testID)
+
+			// restore user
+			ru := user.RestoreUserRequest{ID: cuUsr.User.ID.String(), Version: duUsr.User.Version}
+			ruUsr := core.RestoreUser(ru, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+
+			if ruUsr.Error != "" || ruUsr.User.DateDeleted != nil || ruUsr.User.Email.Address != renamed.Address {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user %+v : got %+v.", failed, testID, ru, ruUsr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to restore user.", success, testID)
+
+			// purge user
+			pu := user.PurgeUserRequest{ID: cuUsr.User.ID.String(), Version: ruUsr.User.Version}
+			if resp := core.PurgeUser(pu, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			}); !strings.Contains(resp.Error, user.ErrUserNotDeleted.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould only purge deleted users %+v : got %+v.", failed, testID, pu, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only purge deleted users.", success, testID)
+
+			duUsr = core.DeleteUser(user.DeleteUserRequest{User: ruUsr.User}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			})
+			if duUsr.Error != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a restored user : got %+v.", failed, testID, duUsr)
+			}
+
+			pu.Version = duUsr.User.Version
+			if resp := core.PurgeUser(pu, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			}); resp.Error != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to purge a deleted user %+v : got %+v.", failed, testID, pu, resp)
+			}
+
+			if resp := core.RestoreUser(ru, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: auth.Claims{Roles: []string{auth.RoleAdmin}},
+				Values: &values.Values{Now: now},
+			}); !strings.Contains(resp.Error, user.ErrNotFound.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould not restore a purged user %+v : got %+v.", failed, testID, ru, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to purge a deleted user.", success, testID)
+
+			// A purge that is not configured takes the default interval
+			// rather than panicking.
+			purgeCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			user.PurgeDeleted(purgeCtx, log, storer, 0, 0)
+			t.Logf("\t%s\tTest %d:\tShould purge without a configured interval.", success, testID)
+
+			// roles
+			adminClaims := auth.Claims{Roles: []string{auth.RoleAdmin}}
+			cr := user.CreateRoleRequest{NewRole: user.NewRole{Name: "AUDITOR", Permissions: []string{"audit:write"}}}
+			if resp := core.CreateRole(cr, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			}); !strings.Contains(resp.Error, user.ErrInvalidRole.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject unknown permissions %+v : got %+v.", failed, testID, cr, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject unknown permissions.", success, testID)
+
+			cr.NewRole.Permissions = []string{user.PermAuditRead}
+			crResp := core.CreateRole(cr, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			})
+			if crResp.Error != "" || crResp.Role.Version == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a role %+v : got %+v.", failed, testID, cr, crResp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a role.", success, testID)
+
+			urr := user.UpdateRoleRequest{Name: "AUDITOR", Version: crResp.Role.Version, UpdateRole: user.UpdateRole{Permissions: []string{user.PermAuditRead, user.PermUserRead}}}
+			urResp := core.UpdateRole(urr, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			})
+			if urResp.Error != "" || len(urResp.Role.Permissions) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update a role %+v : got %+v.", failed, testID, urr, urResp)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould be able to update a role.", success, testID)
 
+			if resp := core.UpdateRole(user.UpdateRoleRequest{Name: auth.RoleAdmin, Version: "1"}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			}); resp.Error != user.ErrBuiltinRole.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould not change built in roles : got %+v.", failed, testID, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not change built in roles.", success, testID)
+
+			qr := core.QueryRoles(user.QueryRolesRequest{}, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			})
+			if qr.Error != "" || len(qr.Roles) != 3 || !qr.Roles[0].Builtin || qr.Roles[2].Name != "AUDITOR" {
+				t.Fatalf("\t%s\tTest %d:\tShould list built in and stored roles : got %+v.", failed, testID, qr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list built in and stored roles.", success, testID)
+
+			nr := nu
+			nr.NewUser.Roles = []user.Role{user.MustParseRole("UNDEFINED")}
+			if resp := core.CreateUser(nr, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			}); !strings.Contains(resp.Error, user.ErrUnknownRole.Error()) {
+				t.Fatalf("\t%s\tTest %d:\tShould not give users undefined roles : got %+v.", failed, testID, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not give users undefined roles.", success, testID)
+
+			drr := user.DeleteRoleRequest{Name: "AUDITOR", Version: urResp.Role.Version}
+			if resp := core.DeleteRole(drr, server.GenericRequest{
+				Ctx:    ctx,
+				Claims: adminClaims,
+				Values: &values.Values{Now: now},
+			}); resp.Error != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a role %+v : got %+v.", failed, testID, drr, resp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete a role.", success, testID)
 		}
 	}
 }
+
+// mailedToken reads the token of the emailed link to page from the mail
+// in dir, removing the mail once read.
+func mailedToken(dir string, page string) (string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		_, after, found := strings.Cut(string(b), page+"?token=")
+		if !found {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+		return strings.Fields(after)[0], nil
+	}
+
+	return "", errors.New("no mail with token")
+}
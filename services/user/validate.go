@@ -0,0 +1,77 @@
+package user
+
+import (
+	"fmt"
+	"net/mail"
+
+	"github.com/google/uuid"
+	"github.com/kjvonly/service/services/errs"
+)
+
+// Limits on the fields users fill in.
+const (
+	maxNameLength       = 128
+	maxEmailLength      = 254
+	maxDepartmentLength = 128
+	maxTokenLength      = 1024
+)
+
+// checkRequired adds an error for field when value is empty.
+func checkRequired(fe *errs.FieldErrors, field string, value string) {
+	if value == "" {
+		fe.Add(field, "is required")
+	}
+}
+
+// checkLength adds an error for field when value is longer than max bytes.
+func checkLength(fe *errs.FieldErrors, field string, value string, max int) {
+	if len(value) > max {
+		fe.Addf(field, "must be at most %d bytes", max)
+	}
+}
+
+// checkEmail adds an error for field unless email is a single, well formed
+// address.
+func checkEmail(fe *errs.FieldErrors, field string, email string) {
+	switch {
+	case email == "":
+		fe.Add(field, "is required")
+	case len(email) > maxEmailLength:
+		fe.Addf(field, "must be at most %d bytes", maxEmailLength)
+	default:
+		if _, err := mail.ParseAddress(email); err != nil {
+			fe.Add(field, "must be a valid email address")
+		}
+	}
+}
+
+// checkID adds an error for field unless id is a user or key id.
+func checkID(fe *errs.FieldErrors, field string, id string) {
+	if id == "" {
+		fe.Add(field, "is required")
+		return
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		fe.Add(field, "must be a valid id")
+	}
+}
+
+// checkRoleName adds an error for field unless name is a well formed role
+// name.
+func checkRoleName(fe *errs.FieldErrors, field string, name string) {
+	if name == "" {
+		fe.Add(field, "is required")
+		return
+	}
+	if _, err := ParseRole(name); err != nil {
+		fe.Add(field, "must be upper case letters, digits and underscores")
+	}
+}
+
+// checkRoleNames adds an error for each role that is not a well formed
+// role name. Whether the roles are defined is checked against the store.
+func checkRoleNames(fe *errs.FieldErrors, field string, roles []Role) {
+	for i, role := range roles {
+		checkRoleName(fe, fmt.Sprintf("%s[%d]", field, i), role.name)
+	}
+}
@@ -0,0 +1,160 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"git.launchpad.net/~man4christ/+git/seed/server"
+	"github.com/kjvonly/service/services/errs"
+	"github.com/kjvonly/service/services/user/mailer"
+)
+
+const (
+	verifyPurpose                = "verify_email"
+	defaultVerificationTokenTTL  = 48 * time.Hour
+	defaultVerificationResendGap = 5 * time.Minute
+)
+
+// Set of errors returned when verifying email addresses.
+var (
+	ErrEmailNotVerified      = errs.New(errs.PermissionDenied, "email not verified")
+	ErrVerificationThrottled = errs.New(errs.ResourceExhausted, "verification email sent recently")
+)
+
+// VerifyEmail implements UserRpcService. It marks the email of the user the
+// token was issued for as verified, provided the user still has that email.
+// The endpoint is public, so only whether the email is verified is returned.
+func (u UserServicer) VerifyEmail(req VerifyEmailRequest, gr server.GenericRequest) VerifyEmailResponse {
+	if err := req.Validate(); err != nil {
+		return VerifyEmailResponse{Fault: errs.From(err)}
+	}
+
+	claims, err := parseToken(u.cfg.SigningKey, req.Token, verifyPurpose, time.Now())
+	if err != nil {
+		return VerifyEmailResponse{Fault: errs.From(err)}
+	}
+
+	usr, err := u.storer.QueryByID(gr.Ctx, claims.UserID)
+	if err != nil || usr.Email.Address != claims.Email {
+		return VerifyEmailResponse{Fault: errs.From(ErrInvalidToken)}
+	}
+
+	if usr.EmailVerified {
+		return VerifyEmailResponse{Verified: true}
+	}
+
+	before := usr
+	usr.EmailVerified = true
+	usr.DateUpdated = gr.Values.Now
+
+	result, err := u.storer.Update(gr.Ctx, usr)
+	if err != nil {
+		return VerifyEmailResponse{Fault: errs.From(fmt.Errorf("update: %w", err))}
+	}
+	u.audit(gr, AuditVerifyEmail, result.ID.String(), before, result)
+
+	return VerifyEmailResponse{Verified: true}
+}
+
+// ResendVerification implements UserRpcService. A new verification email is
+// sent at most once every VerificationResendGap. Unknown and already
+// verified emails are not reported so accounts cannot be discovered through
+// it.
+func (u UserServicer) ResendVerification(req ResendVerificationRequest, gr server.GenericRequest) ResendVerificationResponse {
+	if err := req.Validate(); err != nil {
+		return ResendVerificationResponse{Fault: errs.From(err)}
+	}
+
+	usr, err := u.storer.QueryByEmail(gr.Ctx, req.Email)
+	if err != nil || usr.EmailVerified {
+		return ResendVerificationResponse{}
+	}
+
+	now := time.Now().UTC()
+	if next := usr.DateVerificationSent.Add(u.cfg.VerificationResendGap); now.Before(next) {
+		return ResendVerificationResponse{Fault: errs.From(fmt.Errorf("%w, retry after %s", ErrVerificationThrottled, next.Format(time.RFC3339)))}
+	}
+
+	if _, err := u.sendVerification(gr.Ctx, usr, now); err != nil {
+		return ResendVerificationResponse{Fault: errs.From(err)}
+	}
+
+	return ResendVerificationResponse{}
+}
+
+// sendVerification emails usr a link to verify their email and records when
+// it was sent, returning the updated user.
+func (u UserServicer) sendVerification(ctx context.Context, usr User, now time.Time) (User, error) {
+	tkn, err := signToken(u.cfg.SigningKey, signedClaims{
+		Purpose:   verifyPurpose,
+		UserID:    usr.ID.String(),
+		Email:     usr.Email.Address,
+		ExpiresAt: now.Add(u.cfg.VerificationTokenTTL).Unix(),
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	link, err := withQuery(u.cfg.VerifyURL, "token", tkn)
+	if err != nil {
+		return User{}, err
+	}
+
+	msg := mailer.Message{
+		To:      usr.Email,
+		Subject: "Verify your kjvonly email",
+		Body: fmt.Sprintf("Welcome to kjvonly.\n\n"+
+			"Follow this link within %s to verify your email:\n\n%s\n",
+			u.cfg.VerificationTokenTTL, link),
+	}
+	if err := u.cfg.Mailer.Send(ctx, msg); err != nil {
+		return User{}, fmt.Errorf("send: %w", err)
+	}
+
+	usr.DateVerificationSent = now
+	usr, err = u.storer.Update(ctx, usr)
+	if err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	return usr, nil
+}
+
+// VerifyEmailRequest is the request object for UserService.VerifyEmail.
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r VerifyEmailRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkRequired(&fe, "token", r.Token)
+	checkLength(&fe, "token", r.Token, maxTokenLength)
+	return fe.Err()
+}
+
+// VerifyEmailResponse is the response object for UserService.VerifyEmail.
+type VerifyEmailResponse struct {
+	Verified bool `json:"verified"`
+	errs.Fault
+}
+
+// ResendVerificationRequest is the request object for
+// UserService.ResendVerification.
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate checks the fields of the request hold usable values.
+func (r ResendVerificationRequest) Validate() error {
+	var fe errs.FieldErrors
+	checkEmail(&fe, "email", r.Email)
+	return fe.Err()
+}
+
+// ResendVerificationResponse is the response object for
+// UserService.ResendVerification.
+type ResendVerificationResponse struct {
+	errs.Fault
+}
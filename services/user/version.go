@@ -0,0 +1,22 @@
+package user
+
+import "github.com/kjvonly/service/services/errs"
+
+// Set of errors returned when a user is changed concurrently.
+var (
+	ErrVersionRequired = errs.New(errs.InvalidArgument, "version is required")
+	ErrVersionConflict = errs.New(errs.Conflict, "user was changed since it was read, reload and retry")
+)
+
+// checkVersion reports whether version, as read by the caller, is still the
+// version of usr. The store checks it again on write, so this only spares
+// the work of a change that is bound to fail.
+func (u User) checkVersion(version string) error {
+	switch {
+	case version == "":
+		return ErrVersionRequired
+	case version != u.Version:
+		return ErrVersionConflict
+	}
+	return nil
+}
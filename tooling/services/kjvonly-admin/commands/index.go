@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kjvonly/service/services/bible/kjv"
+	"github.com/kjvonly/service/services/bible/stores/elasticsearch"
+	"go.uber.org/zap"
+)
+
+// IndexConfig holds the settings needed to build the Elasticsearch index.
+type IndexConfig struct {
+	URL       string
+	Path      string
+	BatchSize int
+	Recreate  bool
+}
+
+// Index creates the kjvonly index and bulk loads the verses of the KJV
+// source file into it, verifying the document count once loaded.
+func Index(ctx context.Context, log *zap.SugaredLogger, cfg IndexConfig) error {
+	if cfg.BatchSize < 1 {
+		return fmt.Errorf("batch size must be positive, got %d", cfg.BatchSize)
+	}
+
+	verses, err := kjv.Load(cfg.Path)
+	if err != nil {
+		return fmt.Errorf("loading verses: %w", err)
+	}
+
+	store := elasticsearch.NewStore(log, cfg.URL)
+
+	if cfg.Recreate {
+		if err := store.DeleteIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := store.CreateIndex(ctx); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(verses); start += cfg.BatchSize {
+		end := start + cfg.BatchSize
+		if end > len(verses) {
+			end = len(verses)
+		}
+
+		if err := store.BulkIndex(ctx, verses[start:end]); err != nil {
+			return fmt.Errorf("indexing verses %d-%d: %w", start+1, end, err)
+		}
+		fmt.Printf("indexed %d/%d verses\n", end, len(verses))
+	}
+
+	if err := store.Refresh(ctx); err != nil {
+		return err
+	}
+
+	count, err := store.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count != len(verses) {
+		return fmt.Errorf("verify: index holds %d verses, source has %d", count, len(verses))
+	}
+
+	fmt.Println("index complete")
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"git.launchpad.net/~man4christ/+git/stem/data/nosql/dbschema"
 	"git.launchpad.net/~man4christ/+git/stem/database"
+	"github.com/kjvonly/service/services/user/stores/nosql"
 )
 
 var ErrHelp = errors.New("provided help")
@@ -49,6 +50,14 @@ func Migrate(ctx context.Context, cfg database.Config, migratePath string) error
 		return fmt.Errorf("migrate database: %w", err)
 	}
 
+	n, err := nosql.MigrateUserKeys(ctx, db)
+	if err != nil {
+		return fmt.Errorf("migrate user keys: %w", err)
+	}
+	if n > 0 {
+		fmt.Printf("re-keyed %d users by id\n", n)
+	}
+
 	fmt.Println("migrations complete")
 	return nil
 }
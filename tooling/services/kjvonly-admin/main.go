@@ -26,6 +26,14 @@ type config struct {
 	Migrate struct {
 		Path string `conf:"default:testdata/collections.txt"`
 	}
+	ES struct {
+		URL string `conf:"default:http://127.0.0.1:9200"`
+	}
+	Index struct {
+		Path      string `conf:"default:testdata/kjv_sample.tsv"`
+		BatchSize int    `conf:"default:1000"`
+		Recreate  bool   `conf:"default:false"`
+	}
 }
 
 func main() {
@@ -85,9 +93,21 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, cfg config) error {
 			return fmt.Errorf("seeding database: %w", err)
 		}
 
+	case "index":
+		icfg := commands.IndexConfig{
+			URL:       cfg.ES.URL,
+			Path:      cfg.Index.Path,
+			BatchSize: cfg.Index.BatchSize,
+			Recreate:  cfg.Index.Recreate,
+		}
+		if err := commands.Index(ctx, log, icfg); err != nil {
+			return fmt.Errorf("indexing verses: %w", err)
+		}
+
 	default:
 		fmt.Println("migrate:    create the schema in the database")
 		fmt.Println("seed:       add data to the database")
+		fmt.Println("index:      create and load the elasticsearch verse index")
 		fmt.Println("provide a command to get more help.")
 		return commands.ErrHelp
 	}
@@ -17,7 +17,7 @@ import (
 func main() {
 	pattern := flag.String("pattern", "github.com/kjvonly/service/services/users", "package file path starting with github.com/kjvonly/")
 	directory := flag.String("directory", "../../../services/user", "package directory")
-	excluded := flag.String("excluded", "UserRpcService", "excluded interfaces")
+	excluded := flag.String("excluded", "UserRpcService", "comma separated excluded interfaces")
 	flag.Parse()
 	err := os.Chdir(filepath.Join(*directory))
 	if err != nil {
@@ -25,7 +25,7 @@ func main() {
 	}
 	patterns := []string{*pattern}
 	p := parser.New(patterns...)
-	p.ExcludeInterfaces = []string{*excluded}
+	p.ExcludeInterfaces = strings.Split(*excluded, ",")
 	p.Verbose = false
 	def, err := p.Parse()
 	if err != nil {